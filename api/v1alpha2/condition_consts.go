@@ -21,6 +21,20 @@ const (
 	// an error while provisioning the cluster load balancer due to configuration not supported by the
 	// the remote server.
 	LoadBalancerProvisioningAbortedReason = "LoadBalancerProvisioningAbortedReason"
+
+	// LoadBalancerConfigTemplateInvalidReason (Severity=Warning) documents a LXCCluster controller detecting
+	// that the custom haproxy configuration template of the cluster load balancer cannot be retrieved, or
+	// fails to render. The load balancer configuration is not updated until the template is fixed.
+	LoadBalancerConfigTemplateInvalidReason = "LoadBalancerConfigTemplateInvalid"
+
+	// WaitingForLoadBalancerAddressReason (Severity=Info) documents a LXCCluster waiting for the IPAM provider
+	// to allocate the control plane endpoint address from the configured address pool.
+	WaitingForLoadBalancerAddressReason = "WaitingForLoadBalancerAddress"
+
+	// LoadBalancerAddressAllocationFailedReason (Severity=Warning) documents a LXCCluster controller failing
+	// to allocate the control plane endpoint address from the configured address pool, e.g. because all
+	// addresses of the range are in use. Allocation is automatically re-tried by the controller.
+	LoadBalancerAddressAllocationFailedReason = "LoadBalancerAddressAllocationFailed"
 )
 
 const (
@@ -42,6 +56,21 @@ const (
 	KubeadmProfileCreationAbortedReason = "KubeadmProfileCreationAborted"
 )
 
+const (
+	// KubeadmProfileInSyncCondition documents whether the default kubeadm LXC profile matches the expected
+	// configuration. The condition is only set when the default kubeadm profile is managed by the controller.
+	KubeadmProfileInSyncCondition clusterv1.ConditionType = "KubeadmProfileInSync"
+
+	// KubeadmProfileDriftedReason (Severity=Warning) documents a LXCCluster controller detecting that the
+	// default kubeadm LXC profile does not match the expected configuration.
+	KubeadmProfileDriftedReason = "KubeadmProfileDrifted"
+
+	// KubeadmProfileUpdateFailedReason (Severity=Warning) documents a LXCCluster controller failing to update
+	// the default kubeadm LXC profile to match the expected configuration. Severity is Error if the update is
+	// not allowed by the server, e.g. because of a permissions issue.
+	KubeadmProfileUpdateFailedReason = "KubeadmProfileUpdateFailed"
+)
+
 // Conditions and condition Reasons for the LXCMachine object.
 
 const (
@@ -62,6 +91,22 @@ const (
 	// script to be ready before starting to create the instance that provides the LXCMachine infrastructure.
 	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
 
+	// WaitingForImageReason (Severity=Info) documents a LXCMachine waiting for the LXCImage referenced by
+	// the machine to be ready before starting to create the instance.
+	WaitingForImageReason = "WaitingForImage"
+
+	// WaitingForIPAddressesReason (Severity=Info) documents a LXCMachine waiting for the IPAM provider to
+	// allocate the static addresses of the instance.
+	WaitingForIPAddressesReason = "WaitingForIPAddresses"
+
+	// IPAddressClaimFailedReason (Severity=Warning) documents a LXCMachine controller failing to create or
+	// retrieve the IPAddressClaims for the static addresses of the instance.
+	IPAddressClaimFailedReason = "IPAddressClaimFailed"
+
+	// CloudInitConfigInvalidReason (Severity=Warning) documents a LXCMachine controller failing to retrieve
+	// or render the cloud-init network-config or vendor-data of the instance.
+	CloudInitConfigInvalidReason = "CloudInitConfigInvalid"
+
 	// CreatingInstanceReason (Severity=Info) documents a LXCMachine waiting for the instance that
 	// provides the LXCMachine infrastructure to be created.
 	CreatingInstanceReason = "CreatingInstance"
@@ -98,4 +143,75 @@ const (
 	// BootstrapFailedReason documents (Severity=Error) a LXCMachine controller detecting an error while
 	// bootstrapping the Kubernetes node on the machine just provisioned.
 	BootstrapFailedReason = "BootstrapFailed"
+
+	// BootstrapTimedOutReason documents (Severity=Error) a LXCMachine controller detecting that the bootstrap
+	// of the machine did not complete within the bootstrap timeout.
+	BootstrapTimedOutReason = "BootstrapTimedOut"
+)
+
+const (
+	// InstanceRunningCondition documents the state of the instance of a provisioned LXCMachine. It is true while
+	// the instance is running, and false if the instance is stopped, frozen or in error state.
+	InstanceRunningCondition clusterv1.ConditionType = "InstanceRunning"
+
+	// InstanceStoppedReason (Severity=Error) documents a LXCMachine controller detecting that the instance
+	// of a provisioned LXCMachine is stopped.
+	InstanceStoppedReason = "InstanceStopped"
+
+	// InstanceFrozenReason (Severity=Error) documents a LXCMachine controller detecting that the instance
+	// of a provisioned LXCMachine is frozen.
+	InstanceFrozenReason = "InstanceFrozen"
+
+	// InstanceErrorReason (Severity=Error) documents a LXCMachine controller detecting that the instance
+	// of a provisioned LXCMachine is in error state. This is a terminal failure, and the machine is expected
+	// to be remediated.
+	InstanceErrorReason = "InstanceError"
+
+	// InstanceRestartingReason (Severity=Warning) documents a LXCMachine controller starting the stopped or
+	// frozen instance of a provisioned LXCMachine, as configured by the LXCMachine spec.
+	InstanceRestartingReason = "InstanceRestarting"
+
+	// InstanceStateTransitioningReason (Severity=Info) documents a LXCMachine controller detecting that the
+	// instance of a provisioned LXCMachine is changing state, e.g. starting or stopping.
+	InstanceStateTransitioningReason = "InstanceStateTransitioning"
+)
+
+// Conditions and condition Reasons for the LXCMachinePool object.
+
+const (
+	// InstancesReadyCondition documents the status of the instances of a LXCMachinePool. It is true when
+	// the number of instances matches the number of MachinePool replicas and all instances are bootstrapped.
+	InstancesReadyCondition clusterv1.ConditionType = "InstancesReady"
+
+	// ScalingUpReason (Severity=Info) documents a LXCMachinePool controller creating instances to match the
+	// number of MachinePool replicas.
+	ScalingUpReason = "ScalingUp"
+
+	// ScalingDownReason (Severity=Info) documents a LXCMachinePool controller deleting instances to match the
+	// number of MachinePool replicas.
+	ScalingDownReason = "ScalingDown"
+
+	// WaitingForInstancesBootstrapReason (Severity=Info) documents a LXCMachinePool waiting for the bootstrap
+	// script to complete on some of its instances.
+	WaitingForInstancesBootstrapReason = "WaitingForInstancesBootstrap"
+)
+
+// Conditions and condition Reasons for the LXCImage object.
+
+const (
+	// ImageReadyCondition documents the availability of the image of a LXCImage on the LXC server.
+	ImageReadyCondition clusterv1.ConditionType = "ImageReady"
+
+	// ImageDownloadingReason (Severity=Info) documents a LXCImage controller waiting for the LXC server to
+	// copy the image from the remote server.
+	ImageDownloadingReason = "ImageDownloading"
+
+	// ImageDownloadFailedReason (Severity=Warning) documents a LXCImage controller detecting an error while
+	// copying the image into the LXC server; those kind of errors are usually transient and failed downloads
+	// are automatically re-tried by the controller.
+	ImageDownloadFailedReason = "ImageDownloadFailed"
+
+	// ImageNotFoundReason (Severity=Warning) documents a LXCImage controller detecting that an image without
+	// a remote server does not exist on the LXC server.
+	ImageNotFoundReason = "ImageNotFound"
 )
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -36,6 +37,9 @@ const (
 // LXCClusterSpec defines the desired state of LXCCluster.
 type LXCClusterSpec struct {
 	// ControlPlaneEndpoint represents the endpoint to communicate with the control plane.
+	//
+	// The port defaults to 6443. The cluster load balancer listens on this port, and forwards traffic to the
+	// kube-apiserver port of the control plane machines (`.spec.clusterNetwork.apiServerPort` of the Cluster).
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint,omitempty"`
 
 	// SecretRef references a secret with credentials to access the LXC (e.g. Incus, LXD) server.
@@ -44,6 +48,30 @@ type LXCClusterSpec struct {
 	// LoadBalancer is configuration for provisioning the load balancer of the cluster.
 	LoadBalancer LXCClusterLoadBalancer `json:"loadBalancer"`
 
+	// AdditionalLoadBalancerPorts is a list of additional ports that are forwarded by the cluster load
+	// balancer to the control plane or worker instances of the cluster (e.g. for konnectivity, or an
+	// ingress controller). It is ignored when using the "external" load balancer type.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	AdditionalLoadBalancerPorts []LXCLoadBalancerPort `json:"additionalLoadBalancerPorts,omitempty"`
+
+	// AddressFamily is the address family preference of the cluster. It can be one of:
+	//
+	//   - "IPv4": only IPv4 addresses are used for the control plane endpoint, the load balancer backends and the
+	//     InternalIP addresses of the machines.
+	//   - "IPv6": only IPv6 addresses are used for the control plane endpoint, the load balancer backends and the
+	//     InternalIP addresses of the machines. The haproxy load balancer also binds on IPv6.
+	//   - "DualStack": IPv4 addresses are preferred for the control plane endpoint and the load balancer backends.
+	//     Machines publish both IPv4 and IPv6 InternalIP addresses, and the haproxy load balancer binds on both.
+	//
+	// If empty, the first address reported by the instance is used, and all instance addresses are published.
+	//
+	// +kubebuilder:validation:Enum:=IPv4;IPv6;DualStack;""
+	// +optional
+	AddressFamily string `json:"addressFamily,omitempty"`
+
 	// Skip creation of the default kubeadm profile "cluster-api-$namespace-$name"
 	// for LXCClusters.
 	//
@@ -52,11 +80,18 @@ type LXCClusterSpec struct {
 	// LXCMachineTemplate objects.
 	//
 	// This is useful in cases where a restricted project is used, which does not
-	// allow privileged containers.
+	// allow privileged containers, and the unprivileged kubeadm profile (see
+	// `.spec.kubeadmProfile.mode`) is not sufficient.
 	//
 	// +optional
 	SkipDefaultKubeadmProfile bool `json:"skipDefaultKubeadmProfile"`
 
+	// KubeadmProfile is configuration for the default kubeadm profile "cluster-api-$namespace-$name".
+	// It is ignored if SkipDefaultKubeadmProfile is set.
+	//
+	// +optional
+	KubeadmProfile LXCClusterKubeadmProfile `json:"kubeadmProfile,omitempty"`
+
 	// SkipCloudProviderNodePatch will skip patching Nodes in the workload cluster
 	// to set `.spec.providerID`. Note that this requires deploying the external
 	// cloud controller manager, otherwise Machines will not be able to be tied
@@ -65,16 +100,181 @@ type LXCClusterSpec struct {
 	// +optional
 	SkipCloudProviderNodePatch bool `json:"skipCloudProviderNodePatch"`
 
-	// TODO(neoaggelos): enable failure domains
-	// FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+	// FailureDomains configures how failure domains are discovered for the
+	// cluster. If not set, no failure domains are published and the server
+	// decides where instances are placed.
+	//
+	// +optional
+	FailureDomains *LXCClusterFailureDomains `json:"failureDomains,omitempty"`
+}
+
+// LXCClusterKubeadmProfile is configuration for the default kubeadm profile of the cluster.
+type LXCClusterKubeadmProfile struct {
+	// Mode is the kind of kubeadm profile to create. It can be one of:
+	//
+	//   - "Privileged": a profile for privileged containers. This is the most compatible option.
+	//   - "Unprivileged": a profile for unprivileged containers, for projects that forbid privileged containers.
+	//
+	// If empty, a privileged profile is created, unless the project forbids privileged containers, in which
+	// case the controller falls back to an unprivileged profile. The selected mode is reported in the
+	// status of the LXCCluster.
+	//
+	// +kubebuilder:validation:Enum:=Privileged;Unprivileged;""
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Config is additional configuration keys to set on the profile. These take precedence over the
+	// configuration keys of the default kubeadm profile.
+	//
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// Devices is additional devices to add on the profile. These replace any devices of the default
+	// kubeadm profile with the same name.
+	//
+	// +optional
+	Devices map[string]map[string]string `json:"devices,omitempty"`
+
+	// DriftPolicy defines how the controller handles an existing profile that does not match the expected
+	// configuration, e.g. because it was edited manually or because the default kubeadm profile changed
+	// in a newer release. It can be one of:
+	//
+	//   - "Report": only report the drift in the KubeadmProfileInSync condition. This is the default.
+	//   - "Update": update the profile in place. Note that changes also affect all running instances using the profile.
+	//
+	// +kubebuilder:validation:Enum:=Report;Update;""
+	// +optional
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+}
+
+const (
+	// KubeadmProfileModePrivileged is a kubeadm profile for privileged containers.
+	KubeadmProfileModePrivileged = "Privileged"
+
+	// KubeadmProfileModeUnprivileged is a kubeadm profile for unprivileged containers.
+	KubeadmProfileModeUnprivileged = "Unprivileged"
+)
+
+const (
+	// KubeadmProfileDriftPolicyReport reports drift of the default kubeadm profile, without changing the profile.
+	KubeadmProfileDriftPolicyReport = "Report"
+
+	// KubeadmProfileDriftPolicyUpdate updates the default kubeadm profile in place when drift is detected.
+	KubeadmProfileDriftPolicyUpdate = "Update"
+)
+
+const (
+	// AddressFamilyIPv4 only uses IPv4 addresses.
+	AddressFamilyIPv4 = "IPv4"
+
+	// AddressFamilyIPv6 only uses IPv6 addresses.
+	AddressFamilyIPv6 = "IPv6"
+
+	// AddressFamilyDualStack uses both IPv4 and IPv6 addresses, preferring IPv4.
+	AddressFamilyDualStack = "DualStack"
+)
+
+// LXCClusterFailureDomains is configuration for publishing failure domains based on the Incus cluster topology.
+type LXCClusterFailureDomains struct {
+	// Type is the kind of Incus cluster object that maps to a failure domain. It can be one of:
+	//
+	//   - "ClusterMember": each cluster member is a failure domain. Instances are created with the member as target.
+	//   - "ClusterGroup": each cluster group is a failure domain. Instances are created with "@group" as target.
+	//
+	// +kubebuilder:validation:Enum:=ClusterMember;ClusterGroup
+	Type string `json:"type"`
+
+	// Names is an optional list of cluster members or cluster groups to use as failure domains.
+	// If empty, all online cluster members (or all non-empty cluster groups) are used.
+	//
+	// +optional
+	Names []string `json:"names,omitempty"`
 }
 
+const (
+	// FailureDomainTypeClusterMember maps Incus cluster members to failure domains.
+	FailureDomainTypeClusterMember = "ClusterMember"
+
+	// FailureDomainTypeClusterGroup maps Incus cluster groups to failure domains.
+	FailureDomainTypeClusterGroup = "ClusterGroup"
+)
+
 // SecretRef is a reference to a secret in the cluster.
 type SecretRef struct {
 	// Name is the name of the secret to use. The secret must already exist in the same namespace as the parent object.
 	Name string `json:"name"`
 }
 
+// ConfigMapKeyRef is a reference to a key of a ConfigMap in the cluster.
+type ConfigMapKeyRef struct {
+	// Name is the name of the ConfigMap to use. The ConfigMap must already exist in the same namespace as the parent object.
+	Name string `json:"name"`
+
+	// Key is the key of the ConfigMap to use.
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// SecretKeyRef is a reference to a key of a Secret in the cluster.
+type SecretKeyRef struct {
+	// Name is the name of the Secret to use. The Secret must already exist in the same namespace as the parent object.
+	Name string `json:"name"`
+
+	// Key is the key of the Secret to use.
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// LXCLoadBalancerPort is an additional port that is forwarded by the cluster load balancer.
+type LXCLoadBalancerPort struct {
+	// Name is a unique name for the port. It is used to name the haproxy frontend and backend, or the
+	// network load balancer backends.
+	//
+	// +kubebuilder:validation:Pattern:=`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength:=15
+	Name string `json:"name"`
+
+	// Port is the port on the load balancer address.
+	//
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	Port int32 `json:"port"`
+
+	// EndPort can be set to forward the port range Port-EndPort. Connections are forwarded to the
+	// same port on the target instances, therefore TargetPort must not be set.
+	//
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	// +optional
+	EndPort int32 `json:"endPort,omitempty"`
+
+	// TargetPort is the port on the target instances. Defaults to Port.
+	//
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	// +optional
+	TargetPort int32 `json:"targetPort,omitempty"`
+
+	// TargetRole is the role of the instances that traffic is forwarded to. It can be one of:
+	//
+	//   - "ControlPlane": forward to the control plane instances of the cluster. This is the default.
+	//   - "Worker": forward to the worker instances of the cluster.
+	//
+	// +kubebuilder:validation:Enum:=ControlPlane;Worker;""
+	// +optional
+	TargetRole string `json:"targetRole,omitempty"`
+}
+
+const (
+	// LoadBalancerPortTargetRoleControlPlane forwards an additional load balancer port to the control plane instances.
+	LoadBalancerPortTargetRoleControlPlane = "ControlPlane"
+
+	// LoadBalancerPortTargetRoleWorker forwards an additional load balancer port to the worker instances.
+	LoadBalancerPortTargetRoleWorker = "Worker"
+)
+
 // LXCClusterLoadBalancer is configuration for provisioning the load balancer of the cluster.
 //
 // +kubebuilder:validation:MaxProperties:=1
@@ -104,6 +304,20 @@ type LXCClusterLoadBalancer struct {
 	// +optional
 	OCI *LXCLoadBalancerInstance `json:"oci,omitempty"`
 
+	// Keepalived will spin up two or more LXC instances running haproxy and keepalived, sharing a virtual IP address.
+	//
+	// The controller will automatically update the list of backends on the haproxy configuration of all instances as control plane nodes are added or removed from the cluster.
+	//
+	// The load balancer instances are placed on different cluster members, if the server is clustered.
+	//
+	// When using the "keepalived" mode, the virtual IP address must be set in `.spec.controlPlaneEndpoint.host` on the LXCCluster object.
+	// The address must be reachable on the network of the load balancer instances, and must not be assigned to any other instance.
+	//
+	// The load balancer instance image must have both haproxy and keepalived installed.
+	//
+	// +optional
+	Keepalived *LXCLoadBalancerKeepalived `json:"keepalived,omitempty"`
+
 	// OVN will create a network load balancer.
 	//
 	// The controller will automatically update the list of backends for the network load balancer as control plane nodes are added or removed from the cluster.
@@ -130,14 +344,92 @@ type LXCLoadBalancerInstance struct {
 	//
 	// +optional
 	InstanceSpec LXCLoadBalancerMachineSpec `json:"instanceSpec,omitempty"`
+
+	// ConfigTemplateRef references a ConfigMap key with a custom haproxy configuration template.
+	// The template is rendered with the same data as the default template, and is validated before
+	// it is pushed to the load balancer instances. If not set, the default template is used.
+	//
+	// The key defaults to "haproxy.cfg.tmpl".
+	//
+	// +optional
+	ConfigTemplateRef *ConfigMapKeyRef `json:"configTemplateRef,omitempty"`
+}
+
+type LXCLoadBalancerKeepalived struct {
+	// Replicas is the number of load balancer instances. Defaults to 2.
+	//
+	// +kubebuilder:validation:Minimum:=2
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// VirtualRouterID is the VRRP virtual router ID (1-255) used by keepalived. It must be unique
+	// among the clusters that share the same network. If not set, it is derived from the cluster name.
+	//
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=255
+	// +optional
+	VirtualRouterID int32 `json:"virtualRouterID,omitempty"`
+
+	// Interface is the network interface of the load balancer instances that will hold the
+	// virtual IP address. Defaults to "eth0".
+	//
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// InstanceSpec can be used to adjust the load balancer instance configuration.
+	//
+	// +optional
+	InstanceSpec LXCLoadBalancerMachineSpec `json:"instanceSpec,omitempty"`
+
+	// ConfigTemplateRef references a ConfigMap key with a custom haproxy configuration template.
+	// The template is rendered with the same data as the default template, and is validated before
+	// it is pushed to the load balancer instances. If not set, the default template is used.
+	//
+	// The key defaults to "haproxy.cfg.tmpl".
+	//
+	// +optional
+	ConfigTemplateRef *ConfigMapKeyRef `json:"configTemplateRef,omitempty"`
 }
 
 type LXCLoadBalancerOVN struct {
 	// NetworkName is the name of the network to create the load balancer.
 	NetworkName string `json:"networkName,omitempty"`
+
+	// AddressPool is used to allocate the listen address of the network load balancer, if the
+	// control plane endpoint host is not set.
+	//
+	// +optional
+	AddressPool *LXCLoadBalancerAddressPool `json:"addressPool,omitempty"`
 }
 
 type LXCLoadBalancerExternal struct {
+	// AddressPool is used to allocate the address of the external load balancer (e.g. the kube-vip VIP), if the
+	// control plane endpoint host is not set.
+	//
+	// +optional
+	AddressPool *LXCLoadBalancerAddressPool `json:"addressPool,omitempty"`
+}
+
+// LXCLoadBalancerAddressPool is configuration for allocating the control plane endpoint address of the cluster.
+// Exactly one of PoolRef or CIDR must be set. The allocated address is stored in the control plane endpoint host.
+type LXCLoadBalancerAddressPool struct {
+	// PoolRef references a Cluster API IPAM pool (e.g. an InClusterIPPool) in the namespace of the LXCCluster.
+	// An IPAddressClaim is created for the cluster, and is deleted when the cluster is deleted.
+	//
+	// +optional
+	PoolRef *corev1.TypedLocalObjectReference `json:"poolRef,omitempty"`
+
+	// CIDR is an address range (e.g. "10.100.42.240/28"). The first address of the range that is not in use
+	// on Incus, or by the control plane endpoint of another LXCCluster, is allocated.
+	//
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+
+	// NetworkName is the name of the Incus network that the CIDR belongs to. If set, DHCP leases of the
+	// network are also considered when looking for a free address. It is ignored if PoolRef is set.
+	//
+	// +optional
+	NetworkName string `json:"networkName,omitempty"`
 }
 
 // LXCLoadBalancerMachineSpec is configuration for the container that will host the cluster load balancer, when using the "lxc" or "oci" load balancer type.
@@ -157,11 +449,27 @@ type LXCLoadBalancerMachineSpec struct {
 	// +optional
 	Profiles []string `json:"profiles,omitempty"`
 
+	// DeviceOverrides are devices of the instance, by device name. These are added to the devices from the
+	// profiles of the instance, and replace any profile devices with the same name.
+	//
+	// +optional
+	DeviceOverrides map[string]LXCDevice `json:"deviceOverrides,omitempty"`
+
+	// Config is additional instance configuration (e.g. "limits.cpu.allowance"). These are added to the
+	// configuration from the profiles of the instance.
+	//
+	// The "user.cluster-*" and "cloud-init.*" keys, as well as "raw.qemu", are managed by the provider and
+	// cannot be set.
+	//
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
 	// Image to use for provisioning the load balancer machine. If not set,
 	// a default image based on the load balancer type will be used.
 	//
 	//   - "oci": ghcr.io/neoaggelos/cluster-api-provider-lxc/haproxy:v0.0.1
 	//   - "lxc": haproxy from the default simplestreams server
+	//   - "keepalived": haproxy from the default simplestreams server
 	//
 	// +optional
 	Image LXCMachineImageSource `json:"image"`
@@ -174,6 +482,16 @@ type LXCClusterStatus struct {
 	// +optional
 	Ready bool `json:"ready"`
 
+	// FailureDomains is the list of failure domains discovered from the Incus cluster.
+	//
+	// +optional
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// KubeadmProfileMode is the mode of the default kubeadm profile created for the cluster, "Privileged" or "Unprivileged".
+	//
+	// +optional
+	KubeadmProfileMode string `json:"kubeadmProfileMode,omitempty"`
+
 	// Conditions defines current service state of the LXCCluster.
 	//
 	// +optional
@@ -262,6 +580,66 @@ func (c *LXCCluster) GetLoadBalancerInstanceName() string {
 	return fmt.Sprintf("%s-%s-lb", c.Name, hex.EncodeToString(hash[:3])[:5])
 }
 
+// HasWorkerLoadBalancerPorts returns true if any additional load balancer ports target the worker instances.
+// In that case, the load balancer must be reconfigured when worker instances are added or removed.
+func (c *LXCCluster) HasWorkerLoadBalancerPorts() bool {
+	if c.Spec.LoadBalancer.External != nil {
+		return false
+	}
+	for _, port := range c.Spec.AdditionalLoadBalancerPorts {
+		if port.TargetRole == LoadBalancerPortTargetRoleWorker {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLoadBalancerAddressPool returns the address pool used to allocate the control plane endpoint address. It returns
+// nil if no address pool is configured, or the load balancer type does not support address pools.
+func (c *LXCCluster) GetLoadBalancerAddressPool() *LXCLoadBalancerAddressPool {
+	switch {
+	case c.Spec.LoadBalancer.OVN != nil:
+		return c.Spec.LoadBalancer.OVN.AddressPool
+	case c.Spec.LoadBalancer.External != nil:
+		return c.Spec.LoadBalancer.External.AddressPool
+	default:
+		return nil
+	}
+}
+
+// GetLoadBalancerAddressClaimName returns the name of the IPAddressClaim for the control plane endpoint address.
+func (c *LXCCluster) GetLoadBalancerAddressClaimName() string {
+	return fmt.Sprintf("%s-control-plane-endpoint", c.Name)
+}
+
+// GetLoadBalancerConfigTemplateRef returns the reference to the custom haproxy configuration template of the cluster
+// load balancer. It returns nil if no custom template is configured, or the load balancer type does not use haproxy.
+func (c *LXCCluster) GetLoadBalancerConfigTemplateRef() *ConfigMapKeyRef {
+	switch {
+	case c.Spec.LoadBalancer.LXC != nil:
+		return c.Spec.LoadBalancer.LXC.ConfigTemplateRef
+	case c.Spec.LoadBalancer.OCI != nil:
+		return c.Spec.LoadBalancer.OCI.ConfigTemplateRef
+	case c.Spec.LoadBalancer.Keepalived != nil:
+		return c.Spec.LoadBalancer.Keepalived.ConfigTemplateRef
+	default:
+		return nil
+	}
+}
+
+// GetFailureDomainTarget returns the Incus target (cluster member or "@group") for a failure domain.
+// It returns an empty string if failure domains are not configured.
+func (c *LXCCluster) GetFailureDomainTarget(failureDomain string) string {
+	switch {
+	case failureDomain == "" || c.Spec.FailureDomains == nil:
+		return ""
+	case c.Spec.FailureDomains.Type == FailureDomainTypeClusterGroup:
+		return fmt.Sprintf("@%s", failureDomain)
+	default:
+		return failureDomain
+	}
+}
+
 // GetProfileName returns the profile name for the cluster LXC machines.
 func (c *LXCCluster) GetProfileName() string {
 	return fmt.Sprintf("cluster-api-%s-%s", c.Namespace, c.Name)
@@ -0,0 +1,153 @@
+/*
+Copyright 2024 Angelos Kolaitis.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// LXCImageSpec defines the desired state of LXCImage.
+type LXCImageSpec struct {
+	// SecretRef references a secret with credentials to access the LXC (e.g. Incus, LXD) server. This must be
+	// the same server that is used by the clusters whose machines reference the image.
+	SecretRef SecretRef `json:"secretRef"`
+
+	// Image is the source of the image. Images from a remote server are copied into the LXC server. Images
+	// without a remote server must already exist on the LXC server.
+	//
+	// The image source cannot be changed after creation.
+	Image LXCMachineImageSource `json:"image"`
+
+	// InstanceType is the type of instances that use the image, and is used to pick the image variant from
+	// image aliases. One of "container" (default) or "virtual-machine".
+	//
+	// +kubebuilder:validation:Enum:=container;virtual-machine;""
+	// +optional
+	InstanceType string `json:"instanceType,omitempty"`
+}
+
+// LXCImageStatus defines the observed state of LXCImage.
+type LXCImageStatus struct {
+	// Ready denotes that the image is available on the LXC server.
+	//
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// Fingerprint is the fingerprint of the image on the LXC server. It is set once the image is available.
+	//
+	// +optional
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// LXCImageDownloadStatus is the status of the image download on LXC servers that are not clustered.
+	LXCImageDownloadStatus `json:",inline"`
+
+	// Members is the status of the image on each online cluster member, if the LXC server is clustered. The image
+	// is copied to each cluster member.
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Members []LXCImageMemberStatus `json:"members,omitempty"`
+
+	// Conditions defines current service state of the LXCImage.
+	//
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// LXCImageDownloadStatus is the status of an image download on the LXC server.
+type LXCImageDownloadStatus struct {
+	// Operation is the ID of the LXC server operation that is copying the image.
+	//
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// Progress is the download progress of the image, as reported by the LXC server (e.g. "rootfs: 45% (12.50MB/s)").
+	//
+	// +optional
+	Progress string `json:"progress,omitempty"`
+}
+
+// LXCImageMemberStatus is the status of the image on a cluster member of the LXC server.
+type LXCImageMemberStatus struct {
+	// Name is the name of the cluster member.
+	Name string `json:"name"`
+
+	// Ready denotes that the image has been copied to the cluster member.
+	//
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// LXCImageDownloadStatus is the status of the image download on the cluster member.
+	LXCImageDownloadStatus `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image.name",description="Image name or alias"
+// +kubebuilder:printcolumn:name="Fingerprint",type="string",JSONPath=".status.fingerprint",description="Image fingerprint"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Image ready status"
+// +kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.progress",description="Image download progress",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of LXCImage"
+
+// LXCImage is the Schema for the lxcimages API. It copies an image into the LXC server ahead of time, so that
+// instances of machines that reference it are created from a local image.
+type LXCImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LXCImageSpec   `json:"spec,omitempty"`
+	Status LXCImageStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (c *LXCImage) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (c *LXCImage) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
+// GetLXCSecretNamespacedName returns the client.ObjectKey for the secret containing LXC credentials.
+func (c *LXCImage) GetLXCSecretNamespacedName() types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: c.ObjectMeta.Namespace,
+		Name:      c.Spec.SecretRef.Name,
+	}
+}
+
+// GetImageSource returns the source of the local copy of the image. It is only valid once the image is ready.
+func (c *LXCImage) GetImageSource() LXCMachineImageSource {
+	return LXCMachineImageSource{Fingerprint: c.Status.Fingerprint}
+}
+
+// +kubebuilder:object:root=true
+
+// LXCImageList contains a list of LXCImage.
+type LXCImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LXCImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LXCImage{}, &LXCImageList{})
+}
@@ -19,8 +19,10 @@ package v1alpha2
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/paused"
 )
 
@@ -28,6 +30,10 @@ const (
 	// MachineFinalizer allows ReconcileLXCMachine to clean up resources associated with LXCMachine before
 	// removing it from the apiserver.
 	MachineFinalizer = "lxcmachine.infrastructure.cluster.x-k8s.io"
+
+	// LoadBalancerWeightAnnotation is set on LXCMachine objects of control plane machines that are being deleted,
+	// once the machine has been removed from the cluster load balancer backends.
+	LoadBalancerWeightAnnotation = "lxcmachine.infrastructure.cluster.x-k8s.io/weight"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -73,9 +79,64 @@ type LXCMachineSpec struct {
 	//   - eth0,type=nic,network=my-network
 	// ```
 	//
+	// Deprecated: Use DeviceOverrides instead, which can express values with commas or equal signs. Devices
+	// is still supported, and is converted to device overrides when the instance is created.
+	//
 	// +optional
 	Devices []string `json:"devices,omitempty"`
 
+	// DeviceOverrides are devices of the instance, by device name. These are added to the devices from the
+	// profiles of the instance, and replace any profile devices with the same name.
+	//
+	// For example, to attach device "eth0" to network "my-network", you can use:
+	//
+	// ```yaml
+	//   deviceOverrides:
+	//     eth0:
+	//       type: nic
+	//       config:
+	//         network: my-network
+	// ```
+	//
+	// A device must not be set in both Devices and DeviceOverrides.
+	//
+	// +optional
+	DeviceOverrides map[string]LXCDevice `json:"deviceOverrides,omitempty"`
+
+	// Config is additional instance configuration (e.g. "limits.cpu.allowance", "security.secureboot" or
+	// "snapshots.schedule"). These are added to the configuration from the profiles of the instance.
+	//
+	// The "user.cluster-*" and "cloud-init.*" keys, as well as "raw.qemu", are managed by the provider and
+	// cannot be set.
+	//
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// AddressesFromPools allocates static addresses for network devices of the instance from Cluster API IPAM
+	// pools (e.g. an InClusterIPPool). An IPAddressClaim is created for each entry, and the instance is created
+	// once all addresses are allocated. The claims are deleted when the machine is deleted.
+	//
+	// For network devices on managed Incus bridges, the address is configured with the "ipv4.address" or
+	// "ipv6.address" device option. Otherwise, a cloud-init network-config is generated with the static
+	// addresses, and the gateway of the allocated addresses.
+	//
+	// +optional
+	AddressesFromPools []LXCMachineAddressFromPool `json:"addressesFromPools,omitempty"`
+
+	// Volumes are custom storage volumes that are created for the instance, and attached to it as disk devices
+	// (e.g. to keep "/var/lib/containerd" or "/var/lib/etcd" on a separate storage pool).
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Volumes []LXCMachineVolume `json:"volumes,omitempty"`
+
+	// CloudInit is additional cloud-init configuration for the instance, besides the bootstrap data of the
+	// machine (which is used as cloud-init user-data). It is not supported if the bootstrap data format is "ignition".
+	//
+	// +optional
+	CloudInit *LXCMachineCloudInit `json:"cloudInit,omitempty"`
+
 	// Image to use for provisioning the machine. If not set, a kubeadm image
 	// from the default upstream simplestreams source will be used, based on
 	// the version of the machine.
@@ -86,6 +147,158 @@ type LXCMachineSpec struct {
 	//
 	// +optional
 	Image LXCMachineImageSource `json:"image"`
+
+	// ImageRef references a LXCImage in the same namespace. The instance is created from the copy of the image
+	// on the LXC server, once the LXCImage is ready. ImageRef and Image are mutually exclusive.
+	//
+	// +optional
+	ImageRef *corev1.LocalObjectReference `json:"imageRef,omitempty"`
+
+	// AutoRestart configures the controller to start the instance again if it is found stopped or frozen after
+	// it has been provisioned. If not set, the LXCMachine is reported as unhealthy instead, so that the machine
+	// can be remediated by a MachineHealthCheck.
+	//
+	// +optional
+	AutoRestart bool `json:"autoRestart,omitempty"`
+
+	// BootstrapTimeout is the maximum duration for the bootstrap of the machine to complete, measured from the
+	// creation of the instance. After the timeout expires, the BootstrapSucceeded condition is set to false with
+	// reason BootstrapTimedOut and the failure reason of the LXCMachine is set, so that the machine can be remediated
+	// by a MachineHealthCheck. A zero duration disables the timeout.
+	//
+	// If not set, the default bootstrap timeout of the controller manager is used.
+	//
+	// +optional
+	BootstrapTimeout *metav1.Duration `json:"bootstrapTimeout,omitempty"`
+}
+
+// LXCMachineAddressFromPool is a static address for a network device of the instance, allocated from an IPAM pool.
+type LXCMachineAddressFromPool struct {
+	// Device is the name of the network device of the instance (e.g. "eth0"). The device must be defined
+	// in the profiles or the devices of the instance.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Device string `json:"device"`
+
+	// PoolRef references the IPAM pool to allocate the address from, in the namespace of the LXCMachine.
+	PoolRef corev1.TypedLocalObjectReference `json:"poolRef"`
+}
+
+// LXCDevice is the configuration of an instance device.
+type LXCDevice struct {
+	// Type of the device, e.g. "nic" or "disk". Type "none" removes a device with the same name that is
+	// inherited from the profiles of the instance.
+	//
+	// +kubebuilder:validation:Enum=none;nic;disk;unix-char;unix-block;usb;gpu;infiniband;proxy;unix-hotplug;tpm;pci
+	Type string `json:"type"`
+
+	// Config is the configuration of the device (e.g. "network" for a nic device, or "pool", "path" and "size"
+	// for a disk device). See the Incus documentation for the available options of each device type.
+	//
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// LXCMachineVolume is a custom storage volume of the instance.
+type LXCMachineVolume struct {
+	// Name of the volume. The storage volume is named "<instance name>-<name>", and is attached to the instance
+	// as a disk device named "<name>".
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Pool is the name of the storage pool to create the volume in.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Pool string `json:"pool"`
+
+	// Size of the volume (e.g. "10GiB"). If not set, the default volume size of the storage pool is used.
+	//
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// ContentType of the volume, "filesystem" (default) or "block". Block volumes are only supported for
+	// virtual machines, and are attached as disks without a path.
+	//
+	// +kubebuilder:validation:Enum=filesystem;block
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+
+	// Path is where filesystem volumes are mounted in the instance (e.g. "/var/lib/containerd"). It is required
+	// for filesystem volumes, and must not be set for block volumes.
+	//
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// DeletionPolicy is what happens to the volume when the machine is deleted, "Delete" (default) or "Retain".
+	// Retained volumes are left on the storage pool, and are not reused by other machines.
+	//
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+const (
+	// VolumeContentTypeFilesystem is a custom storage volume with a filesystem, mounted on a path in the instance.
+	VolumeContentTypeFilesystem = "filesystem"
+
+	// VolumeContentTypeBlock is a custom storage volume that is attached to the instance as a block device.
+	VolumeContentTypeBlock = "block"
+
+	// VolumeDeletionPolicyDelete deletes the volume when the machine is deleted.
+	VolumeDeletionPolicyDelete = "Delete"
+
+	// VolumeDeletionPolicyRetain keeps the volume when the machine is deleted.
+	VolumeDeletionPolicyRetain = "Retain"
+)
+
+// LXCMachineCloudInit is additional cloud-init configuration for the instance.
+//
+// The configuration is a Go template, rendered separately for each instance. The following values are available:
+//
+//   - `{{ .InstanceName }}` -- the name of the instance
+//   - `{{ .ClusterName }}` -- the name of the cluster
+//   - `{{ .Namespace }}` -- the namespace of the cluster
+//   - `{{ .Addresses }}` -- the static addresses allocated from IPAM pools (see AddressesFromPools). Each
+//     address has the `.Device`, `.Address`, `.Prefix` and `.Gateway` fields.
+type LXCMachineCloudInit struct {
+	// NetworkConfig is the cloud-init network configuration of the instance (cloud-init.network-config). If set, it
+	// replaces the network configuration that is generated for static addresses (see AddressesFromPools).
+	//
+	// The key of a Secret or ConfigMap defaults to "network-config".
+	//
+	// +optional
+	NetworkConfig *LXCMachineCloudInitSource `json:"networkConfig,omitempty"`
+
+	// VendorData is the cloud-init vendor-data of the instance (cloud-init.vendor-data).
+	//
+	// The key of a Secret or ConfigMap defaults to "vendor-data".
+	//
+	// +optional
+	VendorData *LXCMachineCloudInitSource `json:"vendorData,omitempty"`
+}
+
+// LXCMachineCloudInitSource is cloud-init configuration, set inline or read from a Secret or ConfigMap.
+//
+// +kubebuilder:validation:MinProperties:=1
+// +kubebuilder:validation:MaxProperties:=1
+type LXCMachineCloudInitSource struct {
+	// Inline is the configuration.
+	//
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// SecretKeyRef references a Secret key with the configuration.
+	//
+	// +optional
+	SecretKeyRef *SecretKeyRef `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef references a ConfigMap key with the configuration.
+	//
+	// +optional
+	ConfigMapKeyRef *ConfigMapKeyRef `json:"configMapKeyRef,omitempty"`
 }
 
 type LXCMachineImageSource struct {
@@ -128,16 +341,48 @@ type LXCMachineStatus struct {
 	// +optional
 	Ready bool `json:"ready,omitempty"`
 
-	// LoadBalancerConfigured will be set to true once for each control plane node, after the load balancer instance is reconfigured.
+	// LoadBalancerConfigured will be set to true once for each control plane node (or worker node, if additional
+	// load balancer ports target the worker nodes), after the load balancer instance is reconfigured.
 	//
 	// +optional
 	LoadBalancerConfigured bool `json:"loadBalancerConfigured,omitempty"`
 
+	// BootstrapDataScrubbed will be set to true once the bootstrap data (which contains secrets, e.g. the kubeadm
+	// join token) has been removed from the instance configuration, after bootstrap has completed successfully.
+	//
+	// +optional
+	BootstrapDataScrubbed bool `json:"bootstrapDataScrubbed,omitempty"`
+
 	// Addresses is the list of addresses of the LXC machine.
 	//
 	// +optional
 	Addresses []clusterv1.MachineAddress `json:"addresses"`
 
+	// ImageFingerprint is the fingerprint of the image that the instance was created from. Image names (aliases) are
+	// resolved to a fingerprint once, when the instance is created, and are recorded here. The instance is also
+	// created from this fingerprint if it is already set (e.g. when pinned on the LXCMachineTemplate).
+	//
+	// +optional
+	ImageFingerprint string `json:"imageFingerprint,omitempty"`
+
+	// InstanceState is the most recently observed status of the instance, e.g. "Running", "Stopped", "Frozen" or "Error".
+	//
+	// +optional
+	InstanceState string `json:"instanceState,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem with the instance of the LXCMachine
+	// (e.g. the instance was deleted, or is in Error state) and will contain a succinct value suitable for machine
+	// interpretation. Machines with a failure reason are considered unhealthy by MachineHealthCheck.
+	//
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem with the instance of the LXCMachine
+	// and will contain a more verbose string suitable for logging and human consumption.
+	//
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
 	// Conditions defines current service state of the LXCMachine.
 	//
 	// +optional
@@ -153,7 +398,7 @@ type LXCMachineStatus struct {
 // See https://github.com/kubernetes-sigs/cluster-api/blob/main/docs/proposals/20240916-improve-status-in-CAPI-resources.md for more context.
 type LXCMachineV1Beta2Status struct {
 	// conditions represents the observations of a LXCMachine's current state.
-	// Known condition types are Ready, InstanceProvisioned, BootstrapSucceeded, Deleting, Paused.
+	// Known condition types are Ready, InstanceProvisioned, BootstrapSucceeded, InstanceRunning, Deleting, Paused.
 	// +optional
 	// +listType=map
 	// +listMapKey=type
@@ -166,6 +411,7 @@ type LXCMachineV1Beta2Status struct {
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels['cluster\\.x-k8s\\.io/cluster-name']",description="Cluster"
 // +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns this LXCMachine"
 // +kubebuilder:printcolumn:name="ProviderID",type="string",JSONPath=".spec.providerID",description="Provider ID"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.instanceState",description="Instance state"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine ready status"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of LXCMachine"
 
@@ -213,6 +459,11 @@ func (c *LXCMachine) GetExpectedProviderID() string {
 	return fmt.Sprintf("lxc:///%s", c.GetInstanceName())
 }
 
+// GetIPAddressClaimName returns the name of the IPAddressClaim for the entry of AddressesFromPools with the given index.
+func (c *LXCMachine) GetIPAddressClaimName(index int) string {
+	return fmt.Sprintf("%s-%d", c.Name, index)
+}
+
 // +kubebuilder:object:root=true
 
 // LXCMachineList contains a list of LXCMachine.
@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Angelos Kolaitis.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/paused"
+)
+
+const (
+	// MachinePoolFinalizer allows ReconcileLXCMachinePool to clean up resources associated with LXCMachinePool before
+	// removing it from the apiserver.
+	MachinePoolFinalizer = "lxcmachinepool.infrastructure.cluster.x-k8s.io"
+)
+
+// LXCMachinePoolSpec defines the desired state of LXCMachinePool.
+type LXCMachinePoolSpec struct {
+	// Template is the configuration of the instances of the machine pool.
+	//
+	// Changes to the template only affect instances created afterwards. The providerID field is ignored, and
+	// addressesFromPools, volumes, imageRef and bootstrapTimeout are not supported.
+	Template LXCMachineSpec `json:"template"`
+
+	// ProviderIDList is the list of provider IDs of the instances of the machine pool that are ready.
+	//
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+}
+
+// LXCMachinePoolStatus defines the observed state of LXCMachinePool.
+type LXCMachinePoolStatus struct {
+	// Ready denotes that the LXC machine pool is ready, and instances for all replicas have been bootstrapped.
+	// Instances are added to the ProviderIDList as soon as they are bootstrapped.
+	//
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// Replicas is the most recently observed number of instances of the machine pool.
+	//
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// Instances is the status of each instance of the machine pool.
+	//
+	// +optional
+	Instances []LXCMachinePoolInstanceStatus `json:"instances,omitempty"`
+
+	// Conditions defines current service state of the LXCMachinePool.
+	//
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups all status fields that will be added in LXCMachinePool's status with the v1beta2 version.
+	//
+	// +optional
+	V1Beta2 *LXCMachinePoolV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// LXCMachinePoolInstanceStatus is the observed state of an instance of a LXCMachinePool.
+type LXCMachinePoolInstanceStatus struct {
+	// InstanceName is the name of the instance.
+	InstanceName string `json:"instanceName"`
+
+	// ProviderID is the provider ID of the instance (lxc:///<instancename>).
+	//
+	// +optional
+	ProviderID string `json:"providerID,omitempty"`
+
+	// Ready denotes that the instance is running and the bootstrap script has completed successfully.
+	//
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// BootstrapStatus is the bootstrap status of the instance (e.g. the status of cloud-init). One of "Running", "Done", "Error" or "Unknown".
+	//
+	// +optional
+	BootstrapStatus string `json:"bootstrapStatus,omitempty"`
+
+	// BootstrapDataScrubbed denotes that the bootstrap data has been removed from the instance configuration, after
+	// bootstrap has completed successfully.
+	//
+	// +optional
+	BootstrapDataScrubbed bool `json:"bootstrapDataScrubbed,omitempty"`
+
+	// Addresses is the list of addresses of the instance.
+	//
+	// +optional
+	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
+}
+
+// LXCMachinePoolV1Beta2Status groups all the fields that will be added or modified in LXCMachinePool with the V1Beta2 version.
+// See https://github.com/kubernetes-sigs/cluster-api/blob/main/docs/proposals/20240916-improve-status-in-CAPI-resources.md for more context.
+type LXCMachinePoolV1Beta2Status struct {
+	// conditions represents the observations of a LXCMachinePool's current state.
+	// Known condition types are Ready, InstancesReady, Deleting, Paused.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels['cluster\\.x-k8s\\.io/cluster-name']",description="Cluster"
+// +kubebuilder:printcolumn:name="MachinePool",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"MachinePool\")].name",description="MachinePool object which owns this LXCMachinePool"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Number of instances"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine pool ready status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of LXCMachinePool"
+
+// LXCMachinePool is the Schema for the lxcmachinepools API.
+type LXCMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LXCMachinePoolSpec   `json:"spec,omitempty"`
+	Status LXCMachinePoolStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (c *LXCMachinePool) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (c *LXCMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the set of conditions for this object.
+func (c *LXCMachinePool) GetV1Beta2Conditions() []metav1.Condition {
+	if c.Status.V1Beta2 == nil {
+		return nil
+	}
+	return c.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets conditions for an API object.
+func (c *LXCMachinePool) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if c.Status.V1Beta2 == nil {
+		c.Status.V1Beta2 = &LXCMachinePoolV1Beta2Status{}
+	}
+	c.Status.V1Beta2.Conditions = conditions
+}
+
+// GetInstanceProviderID returns the providerID that the Kubernetes node of an instance of the machine pool should have.
+func (c *LXCMachinePool) GetInstanceProviderID(instanceName string) string {
+	return fmt.Sprintf("lxc:///%s", instanceName)
+}
+
+// +kubebuilder:object:root=true
+
+// LXCMachinePoolList contains a list of LXCMachinePool.
+type LXCMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LXCMachinePool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LXCMachinePool{}, &LXCMachinePoolList{})
+}
+
+var (
+	_ paused.ConditionSetter = &LXCMachinePool{}
+)
@@ -21,6 +21,18 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+const (
+	// PinImageAnnotation can be set to "true" on LXCMachineTemplate objects to pin the image of their machines. The
+	// fingerprint of the image of the first machine that is created from the template is recorded in the
+	// ImageFingerprintAnnotation, and all later machines are created from the exact same image build.
+	PinImageAnnotation = "lxcmachinetemplate.infrastructure.cluster.x-k8s.io/pin-image"
+
+	// ImageFingerprintAnnotation is set on LXCMachineTemplate objects with the PinImageAnnotation, and contains the
+	// fingerprint of the image that is used by machines created from the template. Remove the annotation to pin
+	// the image again on the next machine.
+	ImageFingerprintAnnotation = "lxcmachinetemplate.infrastructure.cluster.x-k8s.io/image-fingerprint"
+)
+
 // LXCMachineTemplateSpec defines the desired state of LXCMachineTemplate.
 type LXCMachineTemplateSpec struct {
 	Template LXCMachineTemplateResource `json:"template"`
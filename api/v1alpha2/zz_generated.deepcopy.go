@@ -21,11 +21,28 @@ limitations under the License.
 package v1alpha2
 
 import (
-	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyRef) DeepCopyInto(out *ConfigMapKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyRef.
+func (in *ConfigMapKeyRef) DeepCopy() *ConfigMapKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCCluster) DeepCopyInto(out *LXCCluster) {
 	*out = *in
@@ -53,6 +70,66 @@ func (in *LXCCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCClusterFailureDomains) DeepCopyInto(out *LXCClusterFailureDomains) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCClusterFailureDomains.
+func (in *LXCClusterFailureDomains) DeepCopy() *LXCClusterFailureDomains {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCClusterFailureDomains)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCClusterKubeadmProfile) DeepCopyInto(out *LXCClusterKubeadmProfile) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCClusterKubeadmProfile.
+func (in *LXCClusterKubeadmProfile) DeepCopy() *LXCClusterKubeadmProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCClusterKubeadmProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCClusterList) DeepCopyInto(out *LXCClusterList) {
 	*out = *in
@@ -98,15 +175,20 @@ func (in *LXCClusterLoadBalancer) DeepCopyInto(out *LXCClusterLoadBalancer) {
 		*out = new(LXCLoadBalancerInstance)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Keepalived != nil {
+		in, out := &in.Keepalived, &out.Keepalived
+		*out = new(LXCLoadBalancerKeepalived)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.OVN != nil {
 		in, out := &in.OVN, &out.OVN
 		*out = new(LXCLoadBalancerOVN)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.External != nil {
 		in, out := &in.External, &out.External
 		*out = new(LXCLoadBalancerExternal)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -126,6 +208,17 @@ func (in *LXCClusterSpec) DeepCopyInto(out *LXCClusterSpec) {
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
 	out.SecretRef = in.SecretRef
 	in.LoadBalancer.DeepCopyInto(&out.LoadBalancer)
+	if in.AdditionalLoadBalancerPorts != nil {
+		in, out := &in.AdditionalLoadBalancerPorts, &out.AdditionalLoadBalancerPorts
+		*out = make([]LXCLoadBalancerPort, len(*in))
+		copy(*out, *in)
+	}
+	in.KubeadmProfile.DeepCopyInto(&out.KubeadmProfile)
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = new(LXCClusterFailureDomains)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCClusterSpec.
@@ -141,6 +234,13 @@ func (in *LXCClusterSpec) DeepCopy() *LXCClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCClusterStatus) DeepCopyInto(out *LXCClusterStatus) {
 	*out = *in
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make(v1beta1.FailureDomains, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(v1beta1.Conditions, len(*in))
@@ -261,7 +361,7 @@ func (in *LXCClusterV1Beta2Status) DeepCopyInto(out *LXCClusterV1Beta2Status) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -278,9 +378,191 @@ func (in *LXCClusterV1Beta2Status) DeepCopy() *LXCClusterV1Beta2Status {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCDevice) DeepCopyInto(out *LXCDevice) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCDevice.
+func (in *LXCDevice) DeepCopy() *LXCDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImage) DeepCopyInto(out *LXCImage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImage.
+func (in *LXCImage) DeepCopy() *LXCImage {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LXCImage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImageDownloadStatus) DeepCopyInto(out *LXCImageDownloadStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImageDownloadStatus.
+func (in *LXCImageDownloadStatus) DeepCopy() *LXCImageDownloadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImageDownloadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImageList) DeepCopyInto(out *LXCImageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LXCImage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImageList.
+func (in *LXCImageList) DeepCopy() *LXCImageList {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LXCImageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImageMemberStatus) DeepCopyInto(out *LXCImageMemberStatus) {
+	*out = *in
+	out.LXCImageDownloadStatus = in.LXCImageDownloadStatus
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImageMemberStatus.
+func (in *LXCImageMemberStatus) DeepCopy() *LXCImageMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImageMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImageSpec) DeepCopyInto(out *LXCImageSpec) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	out.Image = in.Image
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImageSpec.
+func (in *LXCImageSpec) DeepCopy() *LXCImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCImageStatus) DeepCopyInto(out *LXCImageStatus) {
+	*out = *in
+	out.LXCImageDownloadStatus = in.LXCImageDownloadStatus
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]LXCImageMemberStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCImageStatus.
+func (in *LXCImageStatus) DeepCopy() *LXCImageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCImageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCLoadBalancerAddressPool) DeepCopyInto(out *LXCLoadBalancerAddressPool) {
+	*out = *in
+	if in.PoolRef != nil {
+		in, out := &in.PoolRef, &out.PoolRef
+		*out = new(v1.TypedLocalObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerAddressPool.
+func (in *LXCLoadBalancerAddressPool) DeepCopy() *LXCLoadBalancerAddressPool {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCLoadBalancerAddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCLoadBalancerExternal) DeepCopyInto(out *LXCLoadBalancerExternal) {
 	*out = *in
+	if in.AddressPool != nil {
+		in, out := &in.AddressPool, &out.AddressPool
+		*out = new(LXCLoadBalancerAddressPool)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerExternal.
@@ -297,6 +579,11 @@ func (in *LXCLoadBalancerExternal) DeepCopy() *LXCLoadBalancerExternal {
 func (in *LXCLoadBalancerInstance) DeepCopyInto(out *LXCLoadBalancerInstance) {
 	*out = *in
 	in.InstanceSpec.DeepCopyInto(&out.InstanceSpec)
+	if in.ConfigTemplateRef != nil {
+		in, out := &in.ConfigTemplateRef, &out.ConfigTemplateRef
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerInstance.
@@ -309,6 +596,27 @@ func (in *LXCLoadBalancerInstance) DeepCopy() *LXCLoadBalancerInstance {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCLoadBalancerKeepalived) DeepCopyInto(out *LXCLoadBalancerKeepalived) {
+	*out = *in
+	in.InstanceSpec.DeepCopyInto(&out.InstanceSpec)
+	if in.ConfigTemplateRef != nil {
+		in, out := &in.ConfigTemplateRef, &out.ConfigTemplateRef
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerKeepalived.
+func (in *LXCLoadBalancerKeepalived) DeepCopy() *LXCLoadBalancerKeepalived {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCLoadBalancerKeepalived)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCLoadBalancerMachineSpec) DeepCopyInto(out *LXCLoadBalancerMachineSpec) {
 	*out = *in
@@ -317,6 +625,20 @@ func (in *LXCLoadBalancerMachineSpec) DeepCopyInto(out *LXCLoadBalancerMachineSp
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DeviceOverrides != nil {
+		in, out := &in.DeviceOverrides, &out.DeviceOverrides
+		*out = make(map[string]LXCDevice, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	out.Image = in.Image
 }
 
@@ -333,6 +655,11 @@ func (in *LXCLoadBalancerMachineSpec) DeepCopy() *LXCLoadBalancerMachineSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCLoadBalancerOVN) DeepCopyInto(out *LXCLoadBalancerOVN) {
 	*out = *in
+	if in.AddressPool != nil {
+		in, out := &in.AddressPool, &out.AddressPool
+		*out = new(LXCLoadBalancerAddressPool)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerOVN.
@@ -345,6 +672,21 @@ func (in *LXCLoadBalancerOVN) DeepCopy() *LXCLoadBalancerOVN {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCLoadBalancerPort) DeepCopyInto(out *LXCLoadBalancerPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCLoadBalancerPort.
+func (in *LXCLoadBalancerPort) DeepCopy() *LXCLoadBalancerPort {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCLoadBalancerPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCMachine) DeepCopyInto(out *LXCMachine) {
 	*out = *in
@@ -372,6 +714,72 @@ func (in *LXCMachine) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachineAddressFromPool) DeepCopyInto(out *LXCMachineAddressFromPool) {
+	*out = *in
+	in.PoolRef.DeepCopyInto(&out.PoolRef)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachineAddressFromPool.
+func (in *LXCMachineAddressFromPool) DeepCopy() *LXCMachineAddressFromPool {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachineAddressFromPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachineCloudInit) DeepCopyInto(out *LXCMachineCloudInit) {
+	*out = *in
+	if in.NetworkConfig != nil {
+		in, out := &in.NetworkConfig, &out.NetworkConfig
+		*out = new(LXCMachineCloudInitSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VendorData != nil {
+		in, out := &in.VendorData, &out.VendorData
+		*out = new(LXCMachineCloudInitSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachineCloudInit.
+func (in *LXCMachineCloudInit) DeepCopy() *LXCMachineCloudInit {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachineCloudInit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachineCloudInitSource) DeepCopyInto(out *LXCMachineCloudInitSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachineCloudInitSource.
+func (in *LXCMachineCloudInitSource) DeepCopy() *LXCMachineCloudInitSource {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachineCloudInitSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCMachineImageSource) DeepCopyInto(out *LXCMachineImageSource) {
 	*out = *in
@@ -419,6 +827,162 @@ func (in *LXCMachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePool) DeepCopyInto(out *LXCMachinePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePool.
+func (in *LXCMachinePool) DeepCopy() *LXCMachinePool {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LXCMachinePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePoolInstanceStatus) DeepCopyInto(out *LXCMachinePoolInstanceStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]v1beta1.MachineAddress, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePoolInstanceStatus.
+func (in *LXCMachinePoolInstanceStatus) DeepCopy() *LXCMachinePoolInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePoolInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePoolList) DeepCopyInto(out *LXCMachinePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LXCMachinePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePoolList.
+func (in *LXCMachinePoolList) DeepCopy() *LXCMachinePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LXCMachinePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePoolSpec) DeepCopyInto(out *LXCMachinePoolSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePoolSpec.
+func (in *LXCMachinePoolSpec) DeepCopy() *LXCMachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePoolStatus) DeepCopyInto(out *LXCMachinePoolStatus) {
+	*out = *in
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]LXCMachinePoolInstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(LXCMachinePoolV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePoolStatus.
+func (in *LXCMachinePoolStatus) DeepCopy() *LXCMachinePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachinePoolV1Beta2Status) DeepCopyInto(out *LXCMachinePoolV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachinePoolV1Beta2Status.
+func (in *LXCMachinePoolV1Beta2Status) DeepCopy() *LXCMachinePoolV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachinePoolV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LXCMachineSpec) DeepCopyInto(out *LXCMachineSpec) {
 	*out = *in
@@ -437,7 +1001,48 @@ func (in *LXCMachineSpec) DeepCopyInto(out *LXCMachineSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DeviceOverrides != nil {
+		in, out := &in.DeviceOverrides, &out.DeviceOverrides
+		*out = make(map[string]LXCDevice, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AddressesFromPools != nil {
+		in, out := &in.AddressesFromPools, &out.AddressesFromPools
+		*out = make([]LXCMachineAddressFromPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]LXCMachineVolume, len(*in))
+		copy(*out, *in)
+	}
+	if in.CloudInit != nil {
+		in, out := &in.CloudInit, &out.CloudInit
+		*out = new(LXCMachineCloudInit)
+		(*in).DeepCopyInto(*out)
+	}
 	out.Image = in.Image
+	if in.ImageRef != nil {
+		in, out := &in.ImageRef, &out.ImageRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.BootstrapTimeout != nil {
+		in, out := &in.BootstrapTimeout, &out.BootstrapTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachineSpec.
@@ -458,6 +1063,16 @@ func (in *LXCMachineStatus) DeepCopyInto(out *LXCMachineStatus) {
 		*out = make([]v1beta1.MachineAddress, len(*in))
 		copy(*out, *in)
 	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(v1beta1.Conditions, len(*in))
@@ -578,7 +1193,7 @@ func (in *LXCMachineV1Beta2Status) DeepCopyInto(out *LXCMachineV1Beta2Status) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -595,6 +1210,36 @@ func (in *LXCMachineV1Beta2Status) DeepCopy() *LXCMachineV1Beta2Status {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LXCMachineVolume) DeepCopyInto(out *LXCMachineVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LXCMachineVolume.
+func (in *LXCMachineVolume) DeepCopy() *LXCMachineVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(LXCMachineVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretRef) DeepCopyInto(out *SecretRef) {
 	*out = *in
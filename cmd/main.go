@@ -40,6 +40,8 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/clustercache"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/flags"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -50,7 +52,10 @@ import (
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxccluster"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcimage"
 	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcmachine"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcmachinepool"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/webhooks"
 )
 
 var (
@@ -81,13 +86,17 @@ var (
 	logOptions                  = logs.NewOptions()
 
 	// CAPL specific flags.
-	concurrency             int
-	clusterCacheConcurrency int
+	concurrency                 int
+	clusterCacheConcurrency     int
+	instanceHealthCheckInterval time.Duration
+	bootstrapTimeout            time.Duration
 )
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(expv1.AddToScheme(scheme))
+	utilruntime.Must(ipamv1.AddToScheme(scheme))
 
 	utilruntime.Must(infrav1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
@@ -130,6 +139,12 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&clusterCacheConcurrency, "clustercache-concurrency", 100,
 		"Number of clusters to process simultaneously")
 
+	fs.DurationVar(&instanceHealthCheckInterval, "instance-health-check-interval", time.Minute,
+		"The interval at which the state of the instances of provisioned machines is checked (e.g. 1m). Set to 0 to only check on every sync period.")
+
+	fs.DurationVar(&bootstrapTimeout, "bootstrap-timeout", 0,
+		"The default maximum duration for the bootstrap of LXCMachines to complete (e.g. 30m), for machines that do not set spec.bootstrapTimeout. Set to 0 to wait indefinitely.")
+
 	fs.DurationVar(&syncPeriod, "sync-period", 10*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
 
@@ -149,7 +164,7 @@ func InitFlags(fs *pflag.FlagSet) {
 			" to the Kubernetes API server of workload clusters.")
 
 	fs.IntVar(&webhookPort, "webhook-port", 9443,
-		"Webhook Server port")
+		"Webhook Server port. Set to 0 to disable the admission webhooks (e.g. when running the controller locally).")
 
 	fs.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs/",
 		"Webhook cert dir.")
@@ -272,6 +287,9 @@ func main() {
 	ctx := ctrl.SetupSignalHandler()
 
 	setupReconcilers(ctx, mgr)
+	if webhookPort != 0 {
+		setupWebhooks(mgr)
+	}
 	setupChecks(mgr)
 
 	setupLog.Info("starting manager")
@@ -290,6 +308,12 @@ func setupChecks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if webhookPort != 0 {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
+	}
 }
 
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
@@ -338,6 +362,21 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
 	}
 
 	if err := (&lxcmachine.LXCMachineReconciler{
+		Client:                      mgr.GetClient(),
+		CachingClient:               secretCachingClient,
+		ClusterCache:                clusterCache,
+		Recorder:                    mgr.GetEventRecorderFor("lxcmachine-controller"),
+		WatchFilterValue:            watchFilterValue,
+		InstanceHealthCheckInterval: instanceHealthCheckInterval,
+		BootstrapTimeout:            bootstrapTimeout,
+	}).SetupWithManager(ctx, mgr, ctrl_controller.Options{
+		MaxConcurrentReconciles: concurrency,
+	}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LXCMachine")
+		os.Exit(1)
+	}
+
+	if err := (&lxcmachinepool.LXCMachinePoolReconciler{
 		Client:           mgr.GetClient(),
 		CachingClient:    secretCachingClient,
 		ClusterCache:     clusterCache,
@@ -345,8 +384,45 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
 	}).SetupWithManager(ctx, mgr, ctrl_controller.Options{
 		MaxConcurrentReconciles: concurrency,
 	}); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "LXCMachine")
+		setupLog.Error(err, "unable to create controller", "controller", "LXCMachinePool")
+		os.Exit(1)
+	}
+
+	if err := (&lxcimage.LXCImageReconciler{
+		Client:           mgr.GetClient(),
+		WatchFilterValue: watchFilterValue,
+	}).SetupWithManager(ctx, mgr, ctrl_controller.Options{
+		MaxConcurrentReconciles: concurrency,
+	}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LXCImage")
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 }
+
+func setupWebhooks(mgr ctrl.Manager) {
+	if err := (&webhooks.LXCCluster{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCCluster")
+		os.Exit(1)
+	}
+	if err := (&webhooks.LXCClusterTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCClusterTemplate")
+		os.Exit(1)
+	}
+	if err := (&webhooks.LXCMachine{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCMachine")
+		os.Exit(1)
+	}
+	if err := (&webhooks.LXCMachineTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCMachineTemplate")
+		os.Exit(1)
+	}
+	if err := (&webhooks.LXCMachinePool{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCMachinePool")
+		os.Exit(1)
+	}
+	if err := (&webhooks.LXCImage{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LXCImage")
+		os.Exit(1)
+	}
+}
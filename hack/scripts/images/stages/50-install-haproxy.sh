@@ -6,4 +6,4 @@
 set -xeu
 
 apt update
-apt install haproxy -y
+apt install haproxy keepalived -y
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
+	"strings"
 )
 
 // Status defines different possible values for the status of cloud-init in an instance.
@@ -24,6 +27,16 @@ const (
 	StatusError Status = "Error"
 )
 
+// StageErrors are the errors reported by a cloud-init stage.
+type StageErrors struct {
+	// Stage is the name of the cloud-init stage, e.g. "modules-final".
+	Stage string
+	// Errors are the errors of the stage.
+	Errors []string
+	// RecoverableErrors are the recoverable errors of the stage, by log level (e.g. "WARNING").
+	RecoverableErrors map[string][]string
+}
+
 // ParseStatus checks the cloud-init status of an instance. It accepts a reader that fetches the /run/cloud-init/status.json file contents.
 //
 // ParseStatus returns one of the following:
@@ -33,16 +46,13 @@ const (
 // - (StatusError, nil)
 // - (StatusUnknown, <error describing why status is unknown>)
 func ParseStatus(reader io.Reader) (Status, error) {
-	if reader == nil {
-		return StatusUnknown, fmt.Errorf("empty status.json data")
-	}
-	raw := &statusJSON{}
-	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
-		return StatusUnknown, fmt.Errorf("failed to parse status.json: %w", err)
+	raw, err := parseStatusJSON(reader)
+	if err != nil {
+		return StatusUnknown, err
 	}
 
 	switch {
-	case len(raw.V1.Init.Errors)+len(raw.V1.Init.Errors)+len(raw.V1.ModulesConfig.Errors)+len(raw.V1.ModulesFinal.Errors) > 0:
+	case len(raw.V1.InitLocal.Errors)+len(raw.V1.Init.Errors)+len(raw.V1.ModulesConfig.Errors)+len(raw.V1.ModulesFinal.Errors) > 0:
 		return StatusError, nil
 	case raw.V1.Stage != nil:
 		return StatusRunning, nil
@@ -50,3 +60,62 @@ func ParseStatus(reader io.Reader) (Status, error) {
 
 	return StatusDone, nil
 }
+
+// ParseErrors returns the errors of the failed cloud-init stages, in the order the stages run. It accepts a reader
+// that fetches the /run/cloud-init/status.json file contents.
+func ParseErrors(reader io.Reader) ([]StageErrors, error) {
+	raw, err := parseStatusJSON(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []StageErrors
+	for _, stage := range []struct {
+		name   string
+		status statusJSONv1Stage
+	}{
+		{name: "init-local", status: raw.V1.InitLocal},
+		{name: "init", status: raw.V1.Init},
+		{name: "modules-config", status: raw.V1.ModulesConfig},
+		{name: "modules-final", status: raw.V1.ModulesFinal},
+	} {
+		if len(stage.status.Errors) == 0 {
+			continue
+		}
+		result = append(result, StageErrors{Stage: stage.name, Errors: stage.status.Errors, RecoverableErrors: stage.status.RecoverableErrors})
+	}
+	return result, nil
+}
+
+// FailureMessage returns a human readable description of a cloud-init failure, with the errors of the failed stages
+// and the last lines of the cloud-init output log (if any).
+func FailureMessage(stages []StageErrors, outputTail string) string {
+	var b strings.Builder
+	if len(stages) == 0 {
+		b.WriteString("cloud-init finished with error status")
+	}
+	for idx, stage := range stages {
+		if idx > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "cloud-init failed in stage %s: %s", stage.Stage, strings.Join(stage.Errors, "; "))
+		for _, level := range slices.Sorted(maps.Keys(stage.RecoverableErrors)) {
+			fmt.Fprintf(&b, "\n%s: %s", level, strings.Join(stage.RecoverableErrors[level], "; "))
+		}
+	}
+	if outputTail = strings.TrimSpace(outputTail); outputTail != "" {
+		fmt.Fprintf(&b, "\n\ncloud-init output:\n%s", outputTail)
+	}
+	return b.String()
+}
+
+func parseStatusJSON(reader io.Reader) (*statusJSON, error) {
+	if reader == nil {
+		return nil, fmt.Errorf("empty status.json data")
+	}
+	raw := &statusJSON{}
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse status.json: %w", err)
+	}
+	return raw, nil
+}
@@ -49,3 +49,68 @@ func TestParseStatus(t *testing.T) {
 		}
 	})
 }
+
+func TestParseErrors(t *testing.T) {
+	t.Run("InvalidJSON", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := cloudinit.ParseErrors(bytes.NewReader([]byte(`invalid json`)))
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		g := NewWithT(t)
+
+		f, err := os.Open("testdata/done.json")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		stages, err := cloudinit.ParseErrors(f)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(stages).To(BeEmpty())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		g := NewWithT(t)
+
+		f, err := os.Open("testdata/error.json")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		stages, err := cloudinit.ParseErrors(f)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(stages).To(ConsistOf(cloudinit.StageErrors{
+			Stage:  "modules-final",
+			Errors: []string{"('scripts_user', RuntimeError('Runparts: 1 failures (runcmd) in 1 attempted commands'))"},
+			RecoverableErrors: map[string][]string{
+				"WARNING": {
+					"Failed to run module scripts_user (scripts in /var/lib/cloud/instance/scripts)",
+					"Running module scripts_user (<module 'cloudinit.config.cc_scripts_user' from '/usr/lib/python3/dist-packages/cloudinit/config/cc_scripts_user.py'>) failed",
+				},
+			},
+		}))
+	})
+}
+
+func TestFailureMessage(t *testing.T) {
+	t.Run("NoStages", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(cloudinit.FailureMessage(nil, "")).To(Equal("cloud-init finished with error status"))
+	})
+
+	t.Run("WithOutput", func(t *testing.T) {
+		g := NewWithT(t)
+
+		message := cloudinit.FailureMessage([]cloudinit.StageErrors{
+			{Stage: "init", Errors: []string{"error1", "error2"}},
+			{Stage: "modules-final", Errors: []string{"error3"}, RecoverableErrors: map[string][]string{"WARNING": {"warning1"}, "ERROR": {"error4"}}},
+		}, "line1\nline2\n")
+		g.Expect(message).To(Equal(`cloud-init failed in stage init: error1; error2
+cloud-init failed in stage modules-final: error3
+ERROR: error4
+WARNING: warning1
+
+cloud-init output:
+line1
+line2`))
+	})
+}
@@ -0,0 +1,45 @@
+package cloudinit
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// TemplateData is supplied to the cloud-init network-config and vendor-data templates of instances.
+type TemplateData struct {
+	InstanceName string
+	ClusterName  string
+	Namespace    string
+	Addresses    []TemplateAddress
+}
+
+// TemplateAddress is a static address of the instance, allocated from an IPAM pool.
+type TemplateAddress struct {
+	Device  string
+	Address string
+	Prefix  int
+	Gateway string
+}
+
+// ParseTemplate parses a cloud-init configuration template.
+func ParseTemplate(name string, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// RenderTemplate renders a cloud-init configuration template for an instance.
+func RenderTemplate(name string, text string, data TemplateData) (string, error) {
+	t, err := ParseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
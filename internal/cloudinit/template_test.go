@@ -0,0 +1,53 @@
+package cloudinit_test
+
+import (
+	"testing"
+
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := cloudinit.TemplateData{
+		InstanceName: "c1-md-0-abcde",
+		ClusterName:  "c1",
+		Namespace:    "default",
+		Addresses: []cloudinit.TemplateAddress{
+			{Device: "eth0", Address: "10.0.0.10", Prefix: 24, Gateway: "10.0.0.1"},
+		},
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		g := NewWithT(t)
+
+		config, err := cloudinit.RenderTemplate("network-config", `# {{ .Namespace }}/{{ .ClusterName }}/{{ .InstanceName }}
+{{- range .Addresses }}
+{{ .Device }}: {{ .Address }}/{{ .Prefix }} via {{ .Gateway }}
+{{- end }}`, data)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(config).To(Equal("# default/c1/c1-md-0-abcde\neth0: 10.0.0.10/24 via 10.0.0.1"))
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		g := NewWithT(t)
+
+		config, err := cloudinit.RenderTemplate("vendor-data", "#cloud-config\nruncmd: []\n", data)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(config).To(Equal("#cloud-config\nruncmd: []\n"))
+	})
+
+	t.Run("ParseError", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := cloudinit.RenderTemplate("vendor-data", "{{ .InstanceName", data)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := cloudinit.RenderTemplate("vendor-data", "{{ .Unknown }}", data)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
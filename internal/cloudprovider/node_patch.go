@@ -53,19 +53,27 @@ func PatchNode(ctx context.Context, remoteClient client.Client, lxcMachine *infr
 	// slices.DeleteFunc() preserves the length of the original slice and zeroes out last elements. If length is different, it means node originally had the taint.
 	if taints := slices.DeleteFunc(remoteNode.Spec.Taints, matchesCloudProviderTaint); len(taints) != len(remoteNode.Spec.Taints) {
 		// 2. set addresses in the remoteNode `.status.addresses`
-		remoteNodeAddressSet := sets.New(remoteNode.Status.Addresses...)
+		// The InternalIP addresses of the machine are ordered based on the address family preference of the cluster,
+		// so they are placed first, followed by any existing addresses of the remoteNode.
+		addresses := make([]corev1.NodeAddress, 0, len(lxcMachine.Status.Addresses)+len(remoteNode.Status.Addresses))
 		for _, address := range lxcMachine.Status.Addresses {
 			// Only consider "InternalIP" addresses
 			if address.Type != clusterv1.MachineInternalIP {
 				continue
 			}
-			// Append address if not already set in the remoteNode
 			nodeAddress := corev1.NodeAddress{Type: corev1.NodeAddressType(address.Type), Address: address.Address}
-			if !remoteNodeAddressSet.Has(nodeAddress) {
+			if !slices.Contains(remoteNode.Status.Addresses, nodeAddress) {
 				log.FromContext(ctx).WithValues("address", nodeAddress).Info("Adding missing machine address to remote node")
-				remoteNode.Status.Addresses = append(remoteNode.Status.Addresses, nodeAddress)
 			}
+			addresses = append(addresses, nodeAddress)
 		}
+		addressSet := sets.New(addresses...)
+		for _, nodeAddress := range remoteNode.Status.Addresses {
+			if !addressSet.Has(nodeAddress) {
+				addresses = append(addresses, nodeAddress)
+			}
+		}
+		remoteNode.Status.Addresses = addresses
 
 		// 3. remove cloud provider taint to initialize node
 		log.FromContext(ctx).WithValues("taint", cloudProviderTaint).Info("Removing cloud provider taint to initialize remote node")
@@ -77,8 +77,49 @@ func TestPatchNode(t *testing.T) {
 			// original addresses
 			corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node0"},
 			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.20"},
-			// missing InternalIP address appended
+			// missing InternalIP address added
 			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
 		))
 	})
+
+	t.Run("InternalIPOrder", func(t *testing.T) {
+		remoteNode := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node0"},
+			Spec: corev1.NodeSpec{
+				Taints: []corev1.Taint{
+					{Key: "node.cloudprovider.kubernetes.io/uninitialized", Effect: "NoSchedule"},
+				},
+			},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeHostName, Address: "node0"},
+				},
+			},
+		}
+		lxcMachine := &infrav1.LXCMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "node0", Namespace: "default"},
+			Status: infrav1.LXCMachineStatus{
+				Addresses: []clusterv1.MachineAddress{
+					{Type: clusterv1.MachineHostName, Address: "node0"},
+					{Type: clusterv1.MachineInternalIP, Address: "fd42::10"},
+					{Type: clusterv1.MachineInternalIP, Address: "10.0.0.10"},
+					{Type: clusterv1.MachineExternalIP, Address: "10.0.0.10"},
+					{Type: clusterv1.MachineExternalIP, Address: "fd42::10"},
+				},
+			},
+		}
+
+		remoteClient := fake.NewFakeClient(remoteNode)
+		g := NewWithT(t)
+		g.Expect(cloudprovider.PatchNode(context.TODO(), remoteClient, lxcMachine)).NotTo(HaveOccurred())
+
+		node := &corev1.Node{}
+		g.Expect(remoteClient.Get(context.TODO(), client.ObjectKeyFromObject(remoteNode), node)).NotTo(HaveOccurred())
+		g.Expect(node.Status.Addresses).To(Equal([]corev1.NodeAddress{
+			// InternalIP addresses first, in the order of the machine addresses
+			{Type: corev1.NodeInternalIP, Address: "fd42::10"},
+			{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
+			{Type: corev1.NodeHostName, Address: "node0"},
+		}))
+	})
 }
@@ -19,11 +19,14 @@ package lxccluster
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/finalizers"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -48,11 +51,25 @@ type LXCClusterReconciler struct {
 
 	// WatchFilterValue is the label value used to filter events prior to reconciliation.
 	WatchFilterValue string
+
+	// NewIncusClient creates the client used to interact with the infrastructure. Defaults to incus.New.
+	// It is mainly used to inject a fake Incus server in tests.
+	NewIncusClient func(ctx context.Context, opts incus.Options) (*incus.Client, error)
+
+	// addressAllocationMu serializes the allocation of control plane endpoint addresses from CIDR ranges.
+	addressAllocationMu sync.Mutex
+	// allocatedAddresses are the control plane endpoint addresses allocated from CIDR ranges, by LXCCluster. An
+	// allocation is only persisted when the LXCCluster is patched, so addresses are tracked until the allocation is
+	// observed when listing LXCClusters.
+	allocatedAddresses map[types.NamespacedName]string
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcclusters/finalizers,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -91,7 +108,11 @@ func (r *LXCClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.WithValues("secret", lxcCluster.GetLXCSecretNamespacedName()).Error(err, "Failed to fetch LXC credentials secret")
 		return ctrl.Result{}, fmt.Errorf("failed to fetch LXC credentials: %w", err)
 	}
-	lxcClient, err := incus.New(ctx, incus.NewOptionsFromSecret(lxcSecret))
+	newIncusClient := r.NewIncusClient
+	if newIncusClient == nil {
+		newIncusClient = incus.New
+	}
+	lxcClient, err := newIncusClient(ctx, incus.NewOptionsFromSecret(lxcSecret))
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to create incus client: %w", err)
 	}
@@ -146,6 +167,12 @@ func (r *LXCClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 		For(&infrav1.LXCCluster{}).
 		WithOptions(options).
 		WithEventFilter(predicates.ResourceHasFilterLabel(mgr.GetScheme(), predicateLog, r.WatchFilterValue)).
+		Owns(&ipamv1.IPAddressClaim{}).
+		// Only the metadata of ConfigMaps is cached, the referenced ConfigMaps are retrieved when reconciling.
+		WatchesMetadata(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.ConfigMapToLXCClusters),
+		).
 		Watches(
 			&clusterv1.Cluster{},
 			handler.EnqueueRequestsFromMapFunc(util.ClusterToInfrastructureMapFunc(ctx, infrav1.GroupVersion.WithKind("LXCCluster"), mgr.GetClient(), &infrav1.LXCCluster{})),
@@ -158,3 +185,20 @@ func (r *LXCClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 
 	return nil
 }
+
+// ConfigMapToLXCClusters is a handler.ToRequestsFunc to be used to enqueue requests for reconciliation of LXCClusters
+// that reference a ConfigMap with a custom load balancer config template. Only the metadata of the ConfigMap is used.
+func (r *LXCClusterReconciler) ConfigMapToLXCClusters(ctx context.Context, o client.Object) []ctrl.Request {
+	lxcClusterList := &infrav1.LXCClusterList{}
+	if err := r.Client.List(ctx, lxcClusterList, client.InNamespace(o.GetNamespace())); err != nil {
+		return nil
+	}
+	var result []ctrl.Request
+	for _, lxcCluster := range lxcClusterList.Items {
+		if ref := lxcCluster.GetLoadBalancerConfigTemplateRef(); ref != nil && ref.Name == o.GetName() {
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&lxcCluster)})
+		}
+	}
+
+	return result
+}
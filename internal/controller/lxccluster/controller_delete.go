@@ -39,6 +39,14 @@ func (r *LXCClusterReconciler) reconcileDelete(ctx context.Context, cluster *clu
 		return ctrl.Result{}, fmt.Errorf("failed to delete the load balancer instance: %w", err)
 	}
 
+	// Release the control plane endpoint address allocated from the IPAM pool, if any.
+	if pool := lxcCluster.GetLoadBalancerAddressPool(); pool != nil && pool.PoolRef != nil {
+		log.FromContext(ctx).Info("Releasing control plane endpoint address")
+		if err := util.DeleteIPAddressClaim(ctx, r.Client, lxcCluster.Namespace, lxcCluster.GetLoadBalancerAddressClaimName()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	machines, err := util.GetMachinesForCluster(ctx, r.Client, client.ObjectKeyFromObject(cluster))
 	if err != nil {
 		log.FromContext(ctx).Error(err, "Failed to get list of Machines for Cluster")
@@ -3,15 +3,19 @@ package lxccluster
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/lxc/incus/v6/shared/api"
+	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
 	"github.com/neoaggelos/cluster-api-provider-lxc/internal/profile"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/util"
 )
 
 func (r *LXCClusterReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client) error {
@@ -19,16 +23,29 @@ func (r *LXCClusterReconciler) reconcileNormal(ctx context.Context, cluster *clu
 	profileName := lxcCluster.GetProfileName()
 	if lxcCluster.Spec.SkipDefaultKubeadmProfile {
 		conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileAvailableCondition, infrav1.KubeadmProfileDisabledReason, clusterv1.ConditionSeverityInfo, "Will not create default kubeadm profile %s", profileName)
+		conditions.Delete(lxcCluster, infrav1.KubeadmProfileInSyncCondition)
 	} else {
-
 		ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("profileName", profileName))
-		log.FromContext(ctx).Info("Creating default kubeadm profile")
-		if err := lxcClient.InitProfile(ctx, api.ProfilesPost{Name: profileName, ProfilePut: profile.DefaultKubeadm}); err != nil {
+
+		// Use the configured profile mode. Otherwise, keep using the mode of the profile that was previously created.
+		mode := lxcCluster.Spec.KubeadmProfile.Mode
+		if mode == "" {
+			mode = lxcCluster.Status.KubeadmProfileMode
+		}
+
+		kubeadmProfile, err := r.initKubeadmProfile(ctx, lxcCluster, lxcClient, mode)
+		if err != nil && incus.IsTerminalError(err) && mode == "" {
+			// privileged containers are forbidden on the project, fallback to the unprivileged kubeadm profile
+			log.FromContext(ctx).Info("Privileged containers are not allowed, using unprivileged kubeadm profile")
+			mode = infrav1.KubeadmProfileModeUnprivileged
+			kubeadmProfile, err = r.initKubeadmProfile(ctx, lxcCluster, lxcClient, mode)
+		}
+		if err != nil {
 			err = fmt.Errorf("failed to create default kubeadm profile %q: %w", profileName, err)
 			log.FromContext(ctx).Error(err, "Failed to create default kubeadm profile")
 
 			if incus.IsTerminalError(err) {
-				conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileAvailableCondition, infrav1.KubeadmProfileCreationAbortedReason, clusterv1.ConditionSeverityError, "The default kubeadm LXC profile could not be created, most likely because of a permissions issue. Either enable privileged containers on the project, or specify .spec.skipDefaultKubeadmProfile=true on the LXCCluster object. The error was: %s", err)
+				conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileAvailableCondition, infrav1.KubeadmProfileCreationAbortedReason, clusterv1.ConditionSeverityError, "The default kubeadm LXC profile could not be created, most likely because of a permissions issue. Either enable privileged containers on the project, set .spec.kubeadmProfile.mode=Unprivileged, or specify .spec.skipDefaultKubeadmProfile=true on the LXCCluster object. The error was: %s", err)
 				return nil
 			}
 
@@ -36,12 +53,50 @@ func (r *LXCClusterReconciler) reconcileNormal(ctx context.Context, cluster *clu
 			return err
 		}
 
+		if mode == "" {
+			mode = infrav1.KubeadmProfileModePrivileged
+		}
+		lxcCluster.Status.KubeadmProfileMode = mode
 		conditions.MarkTrue(lxcCluster, infrav1.KubeadmProfileAvailableCondition)
+
+		if err := r.reconcileKubeadmProfileDrift(ctx, lxcCluster, lxcClient, kubeadmProfile); err != nil {
+			return err
+		}
+	}
+
+	// Allocate the control plane endpoint address from the address pool, if any.
+	if lxcCluster.Spec.ControlPlaneEndpoint.Host == "" && lxcCluster.GetLoadBalancerAddressPool() != nil {
+		address, err := r.allocateLoadBalancerAddress(ctx, cluster, lxcCluster, lxcClient)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to allocate control plane endpoint address")
+			if incus.IsTerminalError(err) {
+				conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.LoadBalancerAddressAllocationFailedReason, clusterv1.ConditionSeverityError, "The control plane endpoint address could not be allocated. The error was: %s", err)
+				return nil
+			}
+			conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.LoadBalancerAddressAllocationFailedReason, clusterv1.ConditionSeverityWarning, "%s", err)
+			return err
+		}
+		if address == "" {
+			log.FromContext(ctx).Info("Waiting for control plane endpoint address to be allocated")
+			conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.WaitingForLoadBalancerAddressReason, clusterv1.ConditionSeverityInfo, "")
+			return nil
+		}
+		log.FromContext(ctx).Info("Allocated control plane endpoint address", "address", address)
+		lxcCluster.Spec.ControlPlaneEndpoint.Host = address
 	}
 
+	// Retrieve the custom load balancer config template, if any.
+	lbOpts, err := util.GetLoadBalancerOptions(ctx, r.Client, lxcCluster)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to retrieve load balancer config template")
+		conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.LoadBalancerConfigTemplateInvalidReason, clusterv1.ConditionSeverityWarning, "%s", err)
+		return err
+	}
+	lbManager := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster, lbOpts...)
+
 	// Create the container hosting the load balancer.
 	log.FromContext(ctx).Info("Creating load balancer")
-	lbIPs, err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+	lbIPs, err := lbManager.Create(ctx)
 	if err != nil {
 		log.FromContext(ctx).Error(err, "Failed to provision load balancer")
 		if incus.IsTerminalError(err) {
@@ -52,18 +107,150 @@ func (r *LXCClusterReconciler) reconcileNormal(ctx context.Context, cluster *clu
 		return err
 	}
 
+	// Once the load balancer is provisioned, reconfigure it such that changes to the LXCCluster spec (e.g. additional
+	// ports) or the custom config template are applied. Changes to the cluster instances are handled by the LXCMachine
+	// and LXCMachinePool controllers.
+	if lxcCluster.Status.Ready {
+		log.FromContext(ctx).V(2).Info("Reconfiguring load balancer")
+		if err := lbManager.Reconfigure(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to reconfigure load balancer")
+			conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.LoadBalancerProvisioningFailedReason, clusterv1.ConditionSeverityWarning, "%s", err)
+			return fmt.Errorf("failed to reconfigure load balancer: %w", err)
+		}
+	}
+
 	// Surface the control plane endpoint
 	if lxcCluster.Spec.ControlPlaneEndpoint.Host == "" {
-		// TODO(neoaggelos): care about IPv4 vs IPv6
-		lxcCluster.Spec.ControlPlaneEndpoint.Host = lbIPs[0]
+		addresses := incus.FilterAddressesForFamily(lbIPs, lxcCluster.Spec.AddressFamily)
+		if len(addresses) == 0 {
+			conditions.MarkFalse(lxcCluster, infrav1.LoadBalancerAvailableCondition, infrav1.LoadBalancerProvisioningFailedReason, clusterv1.ConditionSeverityWarning, "Load balancer has no %s address", lxcCluster.Spec.AddressFamily)
+			return fmt.Errorf("load balancer has no %s address, addresses are %v", lxcCluster.Spec.AddressFamily, lbIPs)
+		}
+		lxcCluster.Spec.ControlPlaneEndpoint.Host = addresses[0]
 	}
 	if lxcCluster.Spec.ControlPlaneEndpoint.Port == 0 {
 		lxcCluster.Spec.ControlPlaneEndpoint.Port = 6443
 	}
 
+	// Surface the failure domains
+	failureDomains, err := lxcClient.GetFailureDomains(ctx, lxcCluster.Spec.FailureDomains)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to discover failure domains")
+		return fmt.Errorf("failed to discover failure domains: %w", err)
+	}
+	lxcCluster.Status.FailureDomains = failureDomains
+
 	// Mark the lxcCluster ready
 	lxcCluster.Status.Ready = true
 	conditions.MarkTrue(lxcCluster, infrav1.LoadBalancerAvailableCondition)
 
 	return nil
 }
+
+// allocateLoadBalancerAddress allocates the control plane endpoint address from the address pool of the cluster.
+//
+// For IPAM pools, an IPAddressClaim is created for the cluster, and an empty address is returned until the IPAM
+// provider allocates an address. For CIDR ranges, the first free address of the range is returned, excluding the
+// control plane endpoints of all other LXCClusters. CIDR allocations are serialized, and addresses that were allocated
+// for other LXCClusters but are not yet persisted are also excluded.
+func (r *LXCClusterReconciler) allocateLoadBalancerAddress(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client) (string, error) {
+	pool := lxcCluster.GetLoadBalancerAddressPool()
+
+	if pool.PoolRef != nil {
+		address, err := util.GetOrCreateIPAddressClaim(ctx, r.Client, lxcCluster, infrav1.GroupVersion.WithKind("LXCCluster"), lxcCluster.GetLoadBalancerAddressClaimName(), cluster.Name, *pool.PoolRef)
+		if err != nil || address == nil {
+			return "", err
+		}
+		return address.Spec.Address, nil
+	}
+
+	r.addressAllocationMu.Lock()
+	defer r.addressAllocationMu.Unlock()
+
+	lxcClusters := &infrav1.LXCClusterList{}
+	if err := r.Client.List(ctx, lxcClusters); err != nil {
+		return "", fmt.Errorf("failed to list LXCClusters: %w", err)
+	}
+	key := client.ObjectKeyFromObject(lxcCluster)
+	pending := make(map[types.NamespacedName]bool, len(lxcClusters.Items))
+	exclude := make([]string, 0, len(lxcClusters.Items))
+	for _, item := range lxcClusters.Items {
+		if item.Spec.ControlPlaneEndpoint.Host == "" {
+			pending[client.ObjectKeyFromObject(&item)] = true
+		} else if client.ObjectKeyFromObject(&item) != key {
+			exclude = append(exclude, item.Spec.ControlPlaneEndpoint.Host)
+		}
+	}
+	for allocationKey, address := range r.allocatedAddresses {
+		switch {
+		case !pending[allocationKey]:
+			// the allocation is persisted, or the LXCCluster is deleted
+			delete(r.allocatedAddresses, allocationKey)
+		case allocationKey != key:
+			exclude = append(exclude, address)
+		}
+	}
+
+	address, err := lxcClient.AllocateAddress(ctx, pool.CIDR, pool.NetworkName, exclude)
+	if err != nil {
+		return "", err
+	}
+	if r.allocatedAddresses == nil {
+		r.allocatedAddresses = make(map[types.NamespacedName]string)
+	}
+	r.allocatedAddresses[key] = address
+	return address, nil
+}
+
+// initKubeadmProfile creates the default kubeadm profile for the cluster, unless it already exists. It returns the
+// expected configuration of the profile, based on the profile mode and the configured overrides.
+func (r *LXCClusterReconciler) initKubeadmProfile(ctx context.Context, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client, mode string) (api.ProfilePut, error) {
+	baseProfile := profile.DefaultKubeadm
+	if mode == infrav1.KubeadmProfileModeUnprivileged {
+		baseProfile = profile.UnprivilegedKubeadm
+	}
+	kubeadmProfile := profile.WithOverrides(baseProfile, lxcCluster.Spec.KubeadmProfile.Config, lxcCluster.Spec.KubeadmProfile.Devices)
+
+	log.FromContext(ctx).Info("Creating default kubeadm profile", "mode", mode)
+	return kubeadmProfile, lxcClient.InitProfile(ctx, api.ProfilesPost{Name: lxcCluster.GetProfileName(), ProfilePut: kubeadmProfile})
+}
+
+// reconcileKubeadmProfileDrift compares the existing default kubeadm profile against the expected configuration. If the
+// profile has drifted, it is either updated in place or the drift is reported, depending on the configured drift policy.
+func (r *LXCClusterReconciler) reconcileKubeadmProfileDrift(ctx context.Context, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client, kubeadmProfile api.ProfilePut) error {
+	profileName := lxcCluster.GetProfileName()
+
+	diff, err := lxcClient.DiffProfile(ctx, profileName, kubeadmProfile)
+	if err != nil {
+		err = fmt.Errorf("failed to check default kubeadm profile %q for drift: %w", profileName, err)
+		conditions.MarkUnknown(lxcCluster, infrav1.KubeadmProfileInSyncCondition, infrav1.KubeadmProfileDriftedReason, "%s", err)
+		return err
+	}
+	if len(diff) == 0 {
+		conditions.MarkTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)
+		return nil
+	}
+
+	if lxcCluster.Spec.KubeadmProfile.DriftPolicy != infrav1.KubeadmProfileDriftPolicyUpdate {
+		log.FromContext(ctx).Info("Default kubeadm profile has drifted from the expected configuration", "diff", diff)
+		conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileInSyncCondition, infrav1.KubeadmProfileDriftedReason, clusterv1.ConditionSeverityWarning, "Profile does not match the expected configuration (%s). Set .spec.kubeadmProfile.driftPolicy=Update on the LXCCluster object to update the profile.", strings.Join(diff, ", "))
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Updating default kubeadm profile", "diff", diff)
+	if err := lxcClient.UpdateProfile(ctx, profileName, kubeadmProfile); err != nil {
+		err = fmt.Errorf("failed to update default kubeadm profile %q: %w", profileName, err)
+		log.FromContext(ctx).Error(err, "Failed to update default kubeadm profile")
+
+		if incus.IsTerminalError(err) {
+			conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileInSyncCondition, infrav1.KubeadmProfileUpdateFailedReason, clusterv1.ConditionSeverityError, "The default kubeadm LXC profile could not be updated, most likely because of a permissions issue. The error was: %s", err)
+			return nil
+		}
+
+		conditions.MarkFalse(lxcCluster, infrav1.KubeadmProfileInSyncCondition, infrav1.KubeadmProfileUpdateFailedReason, clusterv1.ConditionSeverityWarning, "%s", err)
+		return err
+	}
+
+	conditions.MarkTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)
+	return nil
+}
@@ -0,0 +1,448 @@
+package lxccluster_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/lxc/incus/v6/shared/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxccluster"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+
+	. "github.com/onsi/gomega"
+)
+
+// staleListClient returns a snapshot when listing LXCClusters, like a cache that has not observed recent updates.
+type staleListClient struct {
+	client.Client
+
+	lxcClusters *infrav1.LXCClusterList
+}
+
+func (c *staleListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if lxcClusters, ok := list.(*infrav1.LXCClusterList); ok {
+		c.lxcClusters.DeepCopyInto(lxcClusters)
+		return nil
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+// setupCluster creates a namespace with an infrastructure credentials secret, a Cluster and an owned LXCCluster.
+func setupCluster(g *WithT, loadBalancer infrav1.LXCClusterLoadBalancer, controlPlaneEndpoint clusterv1.APIEndpoint) *infrav1.LXCCluster {
+	ctx := context.TODO()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "lxccluster-"}}
+	g.Expect(testClient.Create(ctx, ns)).To(Succeed())
+
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "lxc-secret", Namespace: ns.Name},
+		Data:       map[string][]byte{"server": []byte("https://fake:8443")},
+	})).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: ns.Name},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{APIVersion: infrav1.GroupVersion.String(), Kind: "LXCCluster", Name: "c1", Namespace: ns.Name},
+		},
+	}
+	g.Expect(testClient.Create(ctx, cluster)).To(Succeed())
+
+	lxcCluster := &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "c1",
+			Namespace: ns.Name,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: cluster.Name, UID: cluster.UID},
+			},
+		},
+		Spec: infrav1.LXCClusterSpec{
+			SecretRef:            infrav1.SecretRef{Name: "lxc-secret"},
+			ControlPlaneEndpoint: controlPlaneEndpoint,
+			LoadBalancer:         loadBalancer,
+		},
+	}
+	g.Expect(testClient.Create(ctx, lxcCluster)).To(Succeed())
+
+	return lxcCluster
+}
+
+func newReconciler(server *fake.Server) *lxccluster.LXCClusterReconciler {
+	return &lxccluster.LXCClusterReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+}
+
+// reconcileUntilReady reconciles the LXCCluster until it is marked as ready.
+func reconcileUntilReady(g *WithT, r *lxccluster.LXCClusterReconciler, lxcCluster *infrav1.LXCCluster) {
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+		g.Expect(lxcCluster.Status.Ready).To(BeTrue())
+	}).Should(Succeed())
+}
+
+// reconcileUntilDeleted deletes the LXCCluster and reconciles until it is removed.
+func reconcileUntilDeleted(g *WithT, r *lxccluster.LXCClusterReconciler, lxcCluster *infrav1.LXCCluster) {
+	g.Expect(testClient.Delete(context.TODO(), lxcCluster)).To(Succeed())
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+		err = testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcCluster), &infrav1.LXCCluster{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	}).Should(Succeed())
+}
+
+func TestLXCClusterReconciler_LoadBalancerLXC(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer()
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}}, clusterv1.APIEndpoint{})
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntilReady(g, r, lxcCluster)
+
+		g.Expect(server.InstanceNames()).To(ConsistOf(lxcCluster.GetLoadBalancerInstanceName()))
+		state, _, err := server.GetInstanceState(lxcCluster.GetLoadBalancerInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(state.StatusCode).To(Equal(api.Running))
+
+		_, _, err = server.GetProfile(lxcCluster.GetProfileName())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(lxcCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(state.Network["eth0"].Addresses[0].Address))
+		g.Expect(lxcCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(6443))
+		g.Expect(conditions.IsTrue(lxcCluster, infrav1.LoadBalancerAvailableCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileAvailableCondition)).To(BeTrue())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntilDeleted(g, r, lxcCluster)
+
+		g.Expect(server.InstanceNames()).To(BeEmpty())
+		_, _, err := server.GetProfile(lxcCluster.GetProfileName())
+		g.Expect(err).To(MatchError(ContainSubstring("Profile not found")))
+	})
+}
+
+func TestLXCClusterReconciler_LoadBalancerOVN(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithNetworks(api.Network{Name: "ovn0", Type: "ovn"}))
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn0"}}, clusterv1.APIEndpoint{Host: "10.100.42.1"})
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntilReady(g, r, lxcCluster)
+
+		_, _, err := server.GetNetworkLoadBalancer("ovn0", "10.100.42.1")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(server.InstanceNames()).To(BeEmpty())
+		g.Expect(lxcCluster.Spec.ControlPlaneEndpoint).To(Equal(clusterv1.APIEndpoint{Host: "10.100.42.1", Port: 6443}))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntilDeleted(g, r, lxcCluster)
+
+		_, _, err := server.GetNetworkLoadBalancer("ovn0", "10.100.42.1")
+		g.Expect(err).To(MatchError(ContainSubstring("not found")))
+	})
+}
+
+func TestLXCClusterReconciler_LoadBalancerOVNMissingExtensions(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithAPIExtensions(), fake.WithNetworks(api.Network{Name: "ovn0", Type: "ovn"}))
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn0"}}, clusterv1.APIEndpoint{Host: "10.100.42.1"})
+
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+		g.Expect(conditions.GetReason(lxcCluster, infrav1.LoadBalancerAvailableCondition)).To(Equal(infrav1.LoadBalancerProvisioningAbortedReason))
+	}).Should(Succeed())
+	g.Expect(lxcCluster.Status.Ready).To(BeFalse())
+}
+
+func TestLXCClusterReconciler_KubeadmProfileDrift(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}}, clusterv1.APIEndpoint{})
+
+	reconcileUntilReady(g, r, lxcCluster)
+	g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(BeTrue())
+
+	t.Run("Report", func(t *testing.T) {
+		g := NewWithT(t)
+
+		p, _, err := server.GetProfile(lxcCluster.GetProfileName())
+		g.Expect(err).ToNot(HaveOccurred())
+		p.Config["security.nesting"] = "false"
+		g.Expect(server.UpdateProfile(lxcCluster.GetProfileName(), p.ProfilePut, "")).To(Succeed())
+
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+
+		g.Expect(conditions.GetReason(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(Equal(infrav1.KubeadmProfileDriftedReason))
+		g.Expect(conditions.GetMessage(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(ContainSubstring(`config["security.nesting"]`))
+		g.Expect(lxcCluster.Status.Ready).To(BeTrue())
+
+		p, _, err = server.GetProfile(lxcCluster.GetProfileName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(p.Config).To(HaveKeyWithValue("security.nesting", "false"))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcCluster.Spec.KubeadmProfile = infrav1.LXCClusterKubeadmProfile{
+			Config:      map[string]string{"limits.kernel.nofile": "1048576"},
+			DriftPolicy: infrav1.KubeadmProfileDriftPolicyUpdate,
+		}
+		g.Expect(testClient.Update(ctx, lxcCluster)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+
+		g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(BeTrue())
+
+		p, _, err := server.GetProfile(lxcCluster.GetProfileName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(p.Config).To(HaveKeyWithValue("security.nesting", "true"))
+		g.Expect(p.Config).To(HaveKeyWithValue("limits.kernel.nofile", "1048576"))
+	})
+}
+
+func TestLXCClusterReconciler_UnprivilegedKubeadmProfile(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithPrivilegedContainersForbidden())
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}}, clusterv1.APIEndpoint{})
+
+	reconcileUntilReady(g, r, lxcCluster)
+
+	g.Expect(lxcCluster.Status.KubeadmProfileMode).To(Equal(infrav1.KubeadmProfileModeUnprivileged))
+	g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileAvailableCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(BeTrue())
+
+	p, _, err := server.GetProfile(lxcCluster.GetProfileName())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.Config).To(HaveKeyWithValue("security.privileged", "false"))
+	g.Expect(p.Config).To(HaveKeyWithValue("security.nesting", "true"))
+
+	// the selected mode is kept on subsequent reconciliations
+	reconcileUntilReady(g, r, lxcCluster)
+	g.Expect(lxcCluster.Status.KubeadmProfileMode).To(Equal(infrav1.KubeadmProfileModeUnprivileged))
+	g.Expect(conditions.IsTrue(lxcCluster, infrav1.KubeadmProfileInSyncCondition)).To(BeTrue())
+}
+
+func TestLXCClusterReconciler_LoadBalancerConfigTemplate(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := newReconciler(server)
+	lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{
+		ConfigTemplateRef: &infrav1.ConfigMapKeyRef{Name: "haproxy-template"},
+	}}, clusterv1.APIEndpoint{})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "haproxy-template", Namespace: lxcCluster.Namespace},
+		Data:       map[string]string{"haproxy.cfg.tmpl": "frontend control-plane\n  bind *:{{ .FrontendControlPlanePort"},
+	}
+	g.Expect(testClient.Create(ctx, configMap)).To(Succeed())
+
+	t.Run("ConfigMapToLXCClusters", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// the ConfigMap watch only caches metadata
+		configMapMetadata := &metav1.PartialObjectMetadata{ObjectMeta: configMap.ObjectMeta}
+		g.Expect(r.ConfigMapToLXCClusters(ctx, configMapMetadata)).To(ConsistOf(ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)}))
+		g.Expect(r.ConfigMapToLXCClusters(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: lxcCluster.Namespace},
+		})).To(BeEmpty())
+		g.Expect(r.ConfigMapToLXCClusters(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "haproxy-template", Namespace: "other"},
+		})).To(BeEmpty())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+			g.Expect(err).To(MatchError(ContainSubstring("invalid load balancer config template")))
+			g.Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(lxcCluster, infrav1.LoadBalancerAvailableCondition)).To(Equal(infrav1.LoadBalancerConfigTemplateInvalidReason))
+		}).Should(Succeed())
+		g.Expect(lxcCluster.Status.Ready).To(BeFalse())
+		g.Expect(server.InstanceNames()).To(BeEmpty())
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		g := NewWithT(t)
+
+		configMap.Data["haproxy.cfg.tmpl"] = "# custom template\nfrontend control-plane\n  bind *:{{ .FrontendControlPlanePort }}\n  timeout client 1h\n"
+		g.Expect(testClient.Update(ctx, configMap)).To(Succeed())
+
+		reconcileUntilReady(g, r, lxcCluster)
+		g.Expect(conditions.IsTrue(lxcCluster, infrav1.LoadBalancerAvailableCondition)).To(BeTrue())
+
+		// the load balancer is reconfigured once provisioned
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		rc, _, err := server.GetInstanceFile(lxcCluster.GetLoadBalancerInstanceName(), "/etc/haproxy/haproxy.cfg")
+		g.Expect(err).ToNot(HaveOccurred())
+		b, err := io.ReadAll(rc)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(b)).To(ContainSubstring("# custom template"))
+		g.Expect(string(b)).To(ContainSubstring("bind *:6443"))
+	})
+}
+
+func TestLXCClusterReconciler_LoadBalancerAddressPool(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+
+	t.Run("CIDR", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server := fake.NewServer(fake.WithNetworks(api.Network{Name: "ovn0", Type: "ovn"}))
+		g.Expect(server.CreateNetworkLoadBalancer("ovn0", api.NetworkLoadBalancersPost{ListenAddress: "10.100.43.1"})).To(Succeed())
+
+		r := newReconciler(server)
+		lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{
+			NetworkName: "ovn0",
+			AddressPool: &infrav1.LXCLoadBalancerAddressPool{CIDR: "10.100.43.0/29"},
+		}}, clusterv1.APIEndpoint{})
+
+		reconcileUntilReady(g, r, lxcCluster)
+
+		// first address of the range is the network address, second is in use by another load balancer
+		g.Expect(lxcCluster.Spec.ControlPlaneEndpoint).To(Equal(clusterv1.APIEndpoint{Host: "10.100.43.2", Port: 6443}))
+		_, _, err := server.GetNetworkLoadBalancer("ovn0", "10.100.43.2")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		reconcileUntilDeleted(g, r, lxcCluster)
+	})
+
+	t.Run("TwoClusters", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server := fake.NewServer()
+		loadBalancer := infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+			AddressPool: &infrav1.LXCLoadBalancerAddressPool{CIDR: "10.100.45.0/29"},
+		}}
+		lxcCluster1 := setupCluster(g, loadBalancer, clusterv1.APIEndpoint{})
+		lxcCluster2 := setupCluster(g, loadBalancer, clusterv1.APIEndpoint{})
+
+		// the reconciler does not observe the allocation of the first cluster when allocating for the second one
+		snapshot := &infrav1.LXCClusterList{}
+		g.Expect(testClient.List(context.TODO(), snapshot)).To(Succeed())
+		r := newReconciler(server)
+		r.Client = &staleListClient{Client: testClient, lxcClusters: snapshot}
+
+		reconcileUntilReady(g, r, lxcCluster1)
+		reconcileUntilReady(g, r, lxcCluster2)
+		g.Expect(lxcCluster1.Spec.ControlPlaneEndpoint.Host).To(Equal("10.100.45.1"))
+		g.Expect(lxcCluster2.Spec.ControlPlaneEndpoint.Host).To(Equal("10.100.45.2"))
+
+		reconcileUntilDeleted(g, r, lxcCluster1)
+		reconcileUntilDeleted(g, r, lxcCluster2)
+	})
+
+	t.Run("PoolRef", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server := fake.NewServer()
+		r := newReconciler(server)
+		poolRef := corev1.TypedLocalObjectReference{APIGroup: ptr.To("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"}
+		lxcCluster := setupCluster(g, infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+			AddressPool: &infrav1.LXCLoadBalancerAddressPool{PoolRef: &poolRef},
+		}}, clusterv1.APIEndpoint{})
+		claimKey := client.ObjectKey{Namespace: lxcCluster.Namespace, Name: lxcCluster.GetLoadBalancerAddressClaimName()}
+
+		claim := &ipamv1.IPAddressClaim{}
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcCluster)})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcCluster), lxcCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(lxcCluster, infrav1.LoadBalancerAvailableCondition)).To(Equal(infrav1.WaitingForLoadBalancerAddressReason))
+			g.Expect(testClient.Get(context.TODO(), claimKey, claim)).To(Succeed())
+		}).Should(Succeed())
+		g.Expect(claim.Spec.PoolRef).To(Equal(poolRef))
+		g.Expect(claim.Spec.ClusterName).To(Equal("c1"))
+
+		// simulate the IPAM provider allocating an address
+		g.Expect(testClient.Create(context.TODO(), &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+			Spec: ipamv1.IPAddressSpec{
+				ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+				PoolRef:  poolRef,
+				Address:  "10.100.44.10",
+				Prefix:   24,
+			},
+		})).To(Succeed())
+		claim.Status.AddressRef = corev1.LocalObjectReference{Name: claim.Name}
+		g.Expect(testClient.Status().Update(context.TODO(), claim)).To(Succeed())
+
+		reconcileUntilReady(g, r, lxcCluster)
+		g.Expect(lxcCluster.Spec.ControlPlaneEndpoint).To(Equal(clusterv1.APIEndpoint{Host: "10.100.44.10", Port: 6443}))
+
+		reconcileUntilDeleted(g, r, lxcCluster)
+		err := testClient.Get(context.TODO(), claimKey, &ipamv1.IPAddressClaim{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
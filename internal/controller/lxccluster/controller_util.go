@@ -33,9 +33,10 @@ func patchLXCCluster(ctx context.Context, patchHelper *patch.Helper, lxcCluster
 	)
 
 	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	// KubeadmProfileInSync is not part of the summary, as profile drift does not affect the cluster infrastructure.
 	return patchHelper.Patch(
 		ctx,
 		lxcCluster,
-		patch.WithOwnedConditions{Conditions: append(infraConditions, clusterv1.ReadyCondition)},
+		patch.WithOwnedConditions{Conditions: append(infraConditions, infrav1.KubeadmProfileInSyncCondition, clusterv1.ReadyCondition)},
 	)
 }
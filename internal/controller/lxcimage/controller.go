@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Angelos Kolaitis.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lxcimage
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+)
+
+// LXCImageReconciler reconciles a LXCImage object
+type LXCImageReconciler struct {
+	client.Client
+
+	// WatchFilterValue is the label value used to filter events prior to reconciliation.
+	WatchFilterValue string
+
+	// NewIncusClient creates the client used to interact with the infrastructure. Defaults to incus.New.
+	// It is mainly used to inject a fake Incus server in tests.
+	NewIncusClient func(ctx context.Context, opts incus.Options) (*incus.Client, error)
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcimages,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcimages/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.1/pkg/reconcile
+func (r *LXCImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// Fetch the LXCImage instance.
+	lxcImage := &infrav1.LXCImage{}
+	if err := r.Client.Get(ctx, req.NamespacedName, lxcImage); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Images are kept on the server when the LXCImage is deleted.
+	if !lxcImage.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	lxcSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, lxcImage.GetLXCSecretNamespacedName(), lxcSecret); err != nil {
+		log.WithValues("secret", lxcImage.GetLXCSecretNamespacedName()).Error(err, "Failed to fetch LXC credentials secret")
+		return ctrl.Result{}, fmt.Errorf("failed to fetch LXC credentials: %w", err)
+	}
+	newIncusClient := r.NewIncusClient
+	if newIncusClient == nil {
+		newIncusClient = incus.New
+	}
+	lxcClient, err := newIncusClient(ctx, incus.NewOptionsFromSecret(lxcSecret))
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to create incus client: %w", err)
+	}
+
+	// Initialize the patch helper
+	patchHelper, err := patch.NewHelper(lxcImage, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// Always attempt to Patch the LXCImage object and status after each reconciliation.
+	defer func() {
+		if err := patchLXCImage(ctx, patchHelper, lxcImage); err != nil {
+			log.Error(err, "Failed to patch LXCImage")
+			if rerr == nil {
+				rerr = err
+			}
+		}
+	}()
+
+	return r.reconcileNormal(ctx, lxcImage, lxcClient)
+}
+
+func patchLXCImage(ctx context.Context, patchHelper *patch.Helper, lxcImage *infrav1.LXCImage) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	conditions.SetSummary(lxcImage,
+		conditions.WithConditions(infrav1.ImageReadyCondition),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(
+		ctx,
+		lxcImage,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{infrav1.ImageReadyCondition, clusterv1.ReadyCondition}},
+	)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LXCImageReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	if r.Client == nil {
+		return fmt.Errorf("required field Client must not be nil")
+	}
+
+	predicateLog := ctrl.LoggerFrom(ctx).WithValues("controller", "lxcimage")
+	if err := ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.LXCImage{}).
+		WithOptions(options).
+		WithEventFilter(predicates.ResourceHasFilterLabel(mgr.GetScheme(), predicateLog, r.WatchFilterValue)).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed setting up with a controller manager: %w", err)
+	}
+
+	return nil
+}
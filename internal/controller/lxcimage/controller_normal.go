@@ -0,0 +1,202 @@
+package lxcimage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+)
+
+const (
+	// imageDownloadPollInterval is the interval for checking the progress of image downloads.
+	imageDownloadPollInterval = 10 * time.Second
+
+	// imageCheckInterval is the interval for checking that the image of a ready LXCImage still exists on the server.
+	imageCheckInterval = 10 * time.Minute
+)
+
+func (r *LXCImageReconciler) reconcileNormal(ctx context.Context, lxcImage *infrav1.LXCImage, lxcClient *incus.Client) (ctrl.Result, error) {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("image", lxcImage.Spec.Image))
+
+	// On clustered servers, the image is copied to each online cluster member.
+	members, err := lxcClient.GetOnlineClusterMembers(ctx)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list cluster members: %w", err)
+	}
+
+	// Check that the image is still available on the server, otherwise copy it again.
+	if lxcImage.Status.Fingerprint != "" {
+		fingerprint, err := lxcClient.GetImageFingerprint(ctx, lxcImage.GetImageSource(), lxcImage.Spec.InstanceType)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to check image: %w", err)
+		}
+		switch {
+		case fingerprint == "":
+			log.FromContext(ctx).WithValues("fingerprint", lxcImage.Status.Fingerprint).Info("Image does not exist on the server anymore")
+			lxcImage.Status.Ready = false
+			lxcImage.Status.Fingerprint = ""
+			lxcImage.Status.Members = nil
+		case incus.IsLocalImage(lxcImage.Spec.Image) || membersReady(lxcImage, members):
+			lxcImage.Status.Ready = true
+			conditions.MarkTrue(lxcImage, infrav1.ImageReadyCondition)
+			return ctrl.Result{RequeueAfter: imageCheckInterval}, nil
+		default:
+			log.FromContext(ctx).Info("Copying image to new cluster members")
+		}
+	}
+
+	// Images without a remote server are not copied, and must already exist.
+	if incus.IsLocalImage(lxcImage.Spec.Image) {
+		fingerprint, err := lxcClient.GetImageFingerprint(ctx, lxcImage.Spec.Image, lxcImage.Spec.InstanceType)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to find image: %w", err)
+		}
+		if fingerprint == "" {
+			log.FromContext(ctx).Info("Image does not exist on the server")
+			conditions.MarkFalse(lxcImage, infrav1.ImageReadyCondition, infrav1.ImageNotFoundReason, clusterv1.ConditionSeverityWarning, "Image does not exist on the server")
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+
+		lxcImage.Status.Ready = true
+		lxcImage.Status.Fingerprint = fingerprint
+		conditions.MarkTrue(lxcImage, infrav1.ImageReadyCondition)
+		return ctrl.Result{RequeueAfter: imageCheckInterval}, nil
+	}
+
+	// Copy the image into the server, or into each online cluster member.
+	type download struct {
+		// target is the cluster member that pulls the image. It is empty for servers that are not clustered.
+		target string
+		status *infrav1.LXCImageDownloadStatus
+		ready  *bool
+	}
+	downloads := []download{{status: &lxcImage.Status.LXCImageDownloadStatus}}
+	lxcImage.Status.Members = filterMembers(lxcImage.Status.Members, members)
+	if len(members) > 0 {
+		downloads = nil
+		for idx := range lxcImage.Status.Members {
+			if member := &lxcImage.Status.Members[idx]; !member.Ready {
+				downloads = append(downloads, download{target: member.Name, status: &member.LXCImageDownloadStatus, ready: &member.Ready})
+			}
+		}
+	}
+
+	var pending bool
+	var progress []string
+	for _, download := range downloads {
+		ctx := ctx
+		if download.target != "" {
+			ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("target", download.target))
+		}
+
+		fingerprint, err := r.reconcileDownload(ctx, lxcImage, lxcClient, download.target, download.status)
+		if err != nil {
+			if incus.IsTerminalError(err) {
+				log.FromContext(ctx).Error(err, "Fatal error while downloading image")
+				conditions.MarkFalse(lxcImage, infrav1.ImageReadyCondition, infrav1.ImageDownloadFailedReason, clusterv1.ConditionSeverityError, "%s", err)
+				return ctrl.Result{}, nil
+			}
+			conditions.MarkFalse(lxcImage, infrav1.ImageReadyCondition, infrav1.ImageDownloadFailedReason, clusterv1.ConditionSeverityWarning, "%s", err)
+			return ctrl.Result{}, err
+		}
+		switch {
+		case fingerprint != "":
+			lxcImage.Status.Fingerprint = fingerprint
+			if download.ready != nil {
+				*download.ready = true
+			}
+		case download.status.Progress != "" && download.target != "":
+			pending = true
+			progress = append(progress, fmt.Sprintf("%s: %s", download.target, download.status.Progress))
+		default:
+			pending = true
+			if download.status.Progress != "" {
+				progress = append(progress, download.status.Progress)
+			}
+		}
+	}
+
+	if pending {
+		if !lxcImage.Status.Ready {
+			conditions.MarkFalse(lxcImage, infrav1.ImageReadyCondition, infrav1.ImageDownloadingReason, clusterv1.ConditionSeverityInfo, "%s", strings.Join(progress, ", "))
+		}
+		return ctrl.Result{RequeueAfter: imageDownloadPollInterval}, nil
+	}
+
+	lxcImage.Status.Ready = true
+	conditions.MarkTrue(lxcImage, infrav1.ImageReadyCondition)
+	return ctrl.Result{RequeueAfter: imageCheckInterval}, nil
+}
+
+// reconcileDownload checks the progress of an image download on a cluster member (or on the server, if target is
+// empty), and starts the download if it is not in progress. The fingerprint of the image is returned once the download
+// is done, otherwise an empty fingerprint is returned and the progress is recorded in download.
+func (r *LXCImageReconciler) reconcileDownload(ctx context.Context, lxcImage *infrav1.LXCImage, lxcClient *incus.Client, target string, download *infrav1.LXCImageDownloadStatus) (string, error) {
+	if download.Operation != "" {
+		result, err := lxcClient.GetImageDownload(ctx, download.Operation, target)
+		switch {
+		case err != nil:
+			log.FromContext(ctx).Error(err, "Image download failed")
+			download.Operation = ""
+			download.Progress = ""
+			return "", fmt.Errorf("failed to download image: %w", err)
+		case result == nil:
+			log.FromContext(ctx).WithValues("operation", download.Operation).Info("Image download operation not found, restarting download")
+			download.Operation = ""
+			download.Progress = ""
+		case result.Done:
+			log.FromContext(ctx).WithValues("fingerprint", result.Fingerprint).Info("Image download completed")
+			download.Operation = ""
+			download.Progress = ""
+			return result.Fingerprint, nil
+		default:
+			download.Progress = result.Progress
+			return "", nil
+		}
+	}
+
+	// Start copying the image into the server.
+	log.FromContext(ctx).Info("Downloading image")
+	operation, err := lxcClient.StartImageDownload(ctx, lxcImage.Spec.Image, lxcImage.Spec.InstanceType, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to start image download: %w", err)
+	}
+	download.Operation = operation
+	return "", nil
+}
+
+// membersReady returns true if the image has been copied to all of the specified cluster members.
+func membersReady(lxcImage *infrav1.LXCImage, members []string) bool {
+	for _, name := range members {
+		if !slices.ContainsFunc(lxcImage.Status.Members, func(m infrav1.LXCImageMemberStatus) bool { return m.Name == name && m.Ready }) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMembers returns the status of the specified cluster members, keeping the existing status of each member. It
+// returns nil if there are no members.
+func filterMembers(current []infrav1.LXCImageMemberStatus, members []string) []infrav1.LXCImageMemberStatus {
+	if len(members) == 0 {
+		return nil
+	}
+	result := make([]infrav1.LXCImageMemberStatus, 0, len(members))
+	for _, name := range members {
+		if idx := slices.IndexFunc(current, func(m infrav1.LXCImageMemberStatus) bool { return m.Name == name }); idx >= 0 {
+			result = append(result, current[idx])
+		} else {
+			result = append(result, infrav1.LXCImageMemberStatus{Name: name})
+		}
+	}
+	return result
+}
@@ -0,0 +1,285 @@
+package lxcimage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lxc/incus/v6/shared/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcimage"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+
+	. "github.com/onsi/gomega"
+)
+
+const imageServer = "https://images.example.com"
+
+// createTestImage creates a namespace with an infrastructure credentials secret and a LXCImage.
+func createTestImage(g *WithT, image infrav1.LXCMachineImageSource) *infrav1.LXCImage {
+	ctx := context.TODO()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "lxcimage-"}}
+	g.Expect(testClient.Create(ctx, ns)).To(Succeed())
+
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "lxc-secret", Namespace: ns.Name},
+		Data:       map[string][]byte{"server": []byte("https://fake:8443")},
+	})).To(Succeed())
+
+	lxcImage := &infrav1.LXCImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "image", Namespace: ns.Name},
+		Spec: infrav1.LXCImageSpec{
+			SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+			Image:     image,
+		},
+	}
+	g.Expect(testClient.Create(ctx, lxcImage)).To(Succeed())
+	return lxcImage
+}
+
+func reconcile(g Gomega, r *lxcimage.LXCImageReconciler, lxcImage *infrav1.LXCImage) (ctrl.Result, error) {
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcImage)})
+	g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcImage), lxcImage)).To(Succeed())
+	return result, err
+}
+
+func newReconciler(server *fake.Server) *lxcimage.LXCImageReconciler {
+	return &lxcimage.LXCImageReconciler{
+		Client: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+}
+
+func TestLXCImageReconciler(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithRemoteImage(imageServer, "kubeadm/v1.32.0", "f1"))
+	r := newReconciler(server)
+
+	lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: imageServer, Protocol: "simplestreams"})
+
+	t.Run("Download", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, err := reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.RequeueAfter).ToNot(BeZero())
+		g.Expect(lxcImage.Status.Ready).To(BeFalse())
+		g.Expect(lxcImage.Status.Operation).ToNot(BeEmpty())
+		g.Expect(conditions.GetReason(lxcImage, infrav1.ImageReadyCondition)).To(Equal(infrav1.ImageDownloadingReason))
+
+		server.SetImageDownloadProgress("rootfs: 45% (12.50MB/s)")
+		_, err = reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lxcImage.Status.Progress).To(Equal("rootfs: 45% (12.50MB/s)"))
+		g.Expect(lxcImage.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server.FinishImageDownloads()
+		_, err := reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lxcImage.Status.Ready).To(BeTrue())
+		g.Expect(lxcImage.Status.Fingerprint).To(Equal("f1"))
+		g.Expect(lxcImage.Status.Operation).To(BeEmpty())
+		g.Expect(lxcImage.Status.Progress).To(BeEmpty())
+		g.Expect(conditions.IsTrue(lxcImage, clusterv1.ReadyCondition)).To(BeTrue())
+
+		_, _, err = server.GetImage("f1")
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("ImageDeleted", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := server.DeleteImage("f1")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lxcImage.Status.Ready).To(BeFalse())
+		g.Expect(lxcImage.Status.Fingerprint).To(BeEmpty())
+		g.Expect(lxcImage.Status.Operation).ToNot(BeEmpty())
+
+		server.FinishImageDownloads()
+		_, err = reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lxcImage.Status.Ready).To(BeTrue())
+		g.Expect(lxcImage.Status.Fingerprint).To(Equal("f1"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(context.TODO(), lxcImage)).To(Succeed())
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcImage)})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// images are kept on the server
+		_, _, err = server.GetImage("f1")
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func TestLXCImageReconciler_Clustered(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithRemoteImage(imageServer, "kubeadm/v1.32.0", "f1"), fake.WithClusterMembers(
+		api.ClusterMember{ServerName: "w02", Status: "Online"},
+		api.ClusterMember{ServerName: "w01", Status: "Online"},
+		api.ClusterMember{ServerName: "w03", Status: "Offline"},
+	))
+	r := newReconciler(server)
+
+	lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: imageServer, Protocol: "simplestreams"})
+
+	// the image is pulled on each online cluster member
+	_, err := reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(server.ImageDownloadTargets()).To(Equal([]string{"w01", "w02"}))
+	g.Expect(lxcImage.Status.Members).To(HaveLen(2))
+	for idx, name := range []string{"w01", "w02"} {
+		g.Expect(lxcImage.Status.Members[idx].Name).To(Equal(name))
+		g.Expect(lxcImage.Status.Members[idx].Operation).ToNot(BeEmpty())
+		g.Expect(lxcImage.Status.Members[idx].Ready).To(BeFalse())
+	}
+	g.Expect(lxcImage.Status.Operation).To(BeEmpty())
+
+	server.SetImageDownloadProgress("rootfs: 45% (12.50MB/s)")
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Members[0].Progress).To(Equal("rootfs: 45% (12.50MB/s)"))
+	g.Expect(lxcImage.Status.Members[1].Progress).To(Equal("rootfs: 45% (12.50MB/s)"))
+	g.Expect(conditions.GetMessage(lxcImage, infrav1.ImageReadyCondition)).To(Equal("w01: rootfs: 45% (12.50MB/s), w02: rootfs: 45% (12.50MB/s)"))
+
+	// the image is not ready until it is available on all members
+	server.FinishImageDownloads("w01")
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Members[0].Ready).To(BeTrue())
+	g.Expect(lxcImage.Status.Members[0].Operation).To(BeEmpty())
+	g.Expect(lxcImage.Status.Members[1].Ready).To(BeFalse())
+	g.Expect(lxcImage.Status.Ready).To(BeFalse())
+	g.Expect(conditions.GetMessage(lxcImage, infrav1.ImageReadyCondition)).To(Equal("w02: rootfs: 45% (12.50MB/s)"))
+
+	server.FinishImageDownloads("w02")
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Ready).To(BeTrue())
+	g.Expect(lxcImage.Status.Fingerprint).To(Equal("f1"))
+	g.Expect(lxcImage.Status.Members[1].Ready).To(BeTrue())
+
+	// ready images are not downloaded again
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Ready).To(BeTrue())
+	g.Expect(server.ImageDownloadTargets()).To(BeEmpty())
+}
+
+func TestLXCImageReconciler_OperationLost(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer(fake.WithRemoteImage(imageServer, "kubeadm/v1.32.0", "f1"))
+	r := newReconciler(server)
+
+	lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: imageServer, Protocol: "simplestreams"})
+
+	_, err := reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	operation := lxcImage.Status.Operation
+	g.Expect(operation).ToNot(BeEmpty())
+
+	server.ForgetOperations()
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Operation).ToNot(BeEmpty())
+	g.Expect(lxcImage.Status.Operation).ToNot(Equal(operation))
+
+	server.FinishImageDownloads()
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Ready).To(BeTrue())
+}
+
+func TestLXCImageReconciler_DownloadFailed(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer()
+	r := newReconciler(server)
+
+	lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "does-not-exist", Server: imageServer, Protocol: "simplestreams"})
+
+	_, err := reconcile(g, r, lxcImage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lxcImage.Status.Operation).ToNot(BeEmpty())
+
+	_, err = reconcile(g, r, lxcImage)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(lxcImage.Status.Ready).To(BeFalse())
+	g.Expect(lxcImage.Status.Operation).To(BeEmpty())
+	g.Expect(conditions.GetReason(lxcImage, infrav1.ImageReadyCondition)).To(Equal(infrav1.ImageDownloadFailedReason))
+}
+
+func TestLXCImageReconciler_LocalImage(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+
+	t.Run("Exists", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server := fake.NewServer(fake.WithImages(api.Image{
+			Fingerprint: "f2",
+			Type:        "container",
+			Aliases:     []api.ImageAlias{{Name: "custom-image"}},
+		}))
+		r := newReconciler(server)
+
+		lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "custom-image"})
+
+		_, err := reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lxcImage.Status.Ready).To(BeTrue())
+		g.Expect(lxcImage.Status.Fingerprint).To(Equal("f2"))
+		g.Expect(lxcImage.Status.Operation).To(BeEmpty())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		g := NewWithT(t)
+
+		server := fake.NewServer()
+		r := newReconciler(server)
+
+		lxcImage := createTestImage(g, infrav1.LXCMachineImageSource{Name: "custom-image"})
+
+		result, err := reconcile(g, r, lxcImage)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.RequeueAfter).ToNot(BeZero())
+		g.Expect(lxcImage.Status.Ready).To(BeFalse())
+		g.Expect(conditions.GetReason(lxcImage, infrav1.ImageReadyCondition)).To(Equal(infrav1.ImageNotFoundReason))
+	})
+}
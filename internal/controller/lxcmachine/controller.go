@@ -24,9 +24,11 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/clustercache"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/finalizers"
 	utillog "sigs.k8s.io/cluster-api/util/log"
@@ -52,15 +54,36 @@ type LXCMachineReconciler struct {
 	// CachingClient is a client that can cache responses, will be used for retrieving secrets.
 	CachingClient client.Client
 
+	// Recorder is used to emit Kubernetes events, e.g. with the cloud-init errors of failed bootstraps.
+	// If nil, no events are emitted.
+	Recorder record.EventRecorder
+
 	// WatchFilterValue is the label value used to filter events prior to reconciliation.
 	WatchFilterValue string
+
+	// InstanceHealthCheckInterval is the interval at which the state of the instances of provisioned machines is
+	// checked. If zero, instances are only checked when the LXCMachine is reconciled (e.g. on every sync period).
+	InstanceHealthCheckInterval time.Duration
+
+	// BootstrapTimeout is the default bootstrap timeout for LXCMachines that do not set spec.bootstrapTimeout.
+	// If zero, the controller waits indefinitely for the bootstrap to complete.
+	BootstrapTimeout time.Duration
+
+	// NewIncusClient creates the client used to interact with the infrastructure. Defaults to incus.New.
+	// It is mainly used to inject a fake Incus server in tests.
+	NewIncusClient func(ctx context.Context, opts incus.Options) (*incus.Client, error)
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcmachines/finalizers,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcimages,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=lxcmachinetemplates,verbs=get;list;watch;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;machinesets;machines,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -139,7 +162,11 @@ func (r *LXCMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.WithValues("secret", lxcCluster.GetLXCSecretNamespacedName()).Error(err, "Failed to fetch LXC credentials secret")
 		return ctrl.Result{}, fmt.Errorf("failed to fetch LXC credentials: %w", err)
 	}
-	lxcClient, err := incus.New(ctx, incus.NewOptionsFromSecret(lxcSecret))
+	newIncusClient := r.NewIncusClient
+	if newIncusClient == nil {
+		newIncusClient = incus.New
+	}
+	lxcClient, err := newIncusClient(ctx, incus.NewOptionsFromSecret(lxcSecret))
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to create incus client: %w", err)
 	}
@@ -200,6 +227,7 @@ func (r *LXCMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 		For(&infrav1.LXCMachine{}).
 		WithOptions(options).
 		WithEventFilter(predicates.ResourceHasFilterLabel(mgr.GetScheme(), predicateLog, r.WatchFilterValue)).
+		Owns(&ipamv1.IPAddressClaim{}).
 		Watches(
 			&clusterv1.Machine{},
 			handler.EnqueueRequestsFromMapFunc(util.MachineToInfrastructureMapFunc(infrav1.GroupVersion.WithKind("LXCMachine"))),
@@ -13,6 +13,7 @@ import (
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	lxcutil "github.com/neoaggelos/cluster-api-provider-lxc/internal/util"
 )
 
 func (r *LXCMachineReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, machine *clusterv1.Machine, lxcMachine *infrav1.LXCMachine, lxcClient *incus.Client) error {
@@ -35,11 +36,18 @@ func (r *LXCMachineReconciler) reconcileDelete(ctx context.Context, cluster *clu
 		return fmt.Errorf("failed to delete the instance: %w", err)
 	}
 
-	// If the deleted machine is a control-plane node, remove it from the load balancer configuration (unless the cluster is getting deleted)
-	if util.IsControlPlaneMachine(machine) && cluster.ObjectMeta.DeletionTimestamp.IsZero() {
-		log.FromContext(ctx).Info("Reconfigure load balancer after removing control plane machine")
-		if err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx); err != nil {
-			return fmt.Errorf("failed to reconfigure load balancer after removing control plane node: %w", err)
+	// Release static addresses allocated from IPAM pools
+	for idx := range lxcMachine.Spec.AddressesFromPools {
+		if err := lxcutil.DeleteIPAddressClaim(ctx, r.Client, lxcMachine.Namespace, lxcMachine.GetIPAddressClaimName(idx)); err != nil {
+			return fmt.Errorf("failed to release static address: %w", err)
+		}
+	}
+
+	// If the deleted machine is a load balancer backend, remove it from the load balancer configuration (unless the cluster is getting deleted)
+	if (util.IsControlPlaneMachine(machine) || lxcCluster.HasWorkerLoadBalancerPorts()) && cluster.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.FromContext(ctx).Info("Reconfigure load balancer after removing machine")
+		if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
+			return fmt.Errorf("failed to reconfigure load balancer after removing machine: %w", err)
 		}
 	}
 
@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lxc/incus/v6/shared/api"
+	corev1 "k8s.io/api/core/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,40 +31,26 @@ func (r *LXCMachineReconciler) reconcileNormal(ctx context.Context, cluster *clu
 		return ctrl.Result{}, nil
 	}
 
-	// TODO(neoaggelos): enable this code from capd, and adjust for LXC
-	/*
-		// if the corresponding machine is deleted but the docker machine not yet, update load balancer configuration to divert all traffic from this instance
-		if util.IsControlPlaneMachine(machine) && !machine.DeletionTimestamp.IsZero() && dockerMachine.DeletionTimestamp.IsZero() {
-			if _, ok := dockerMachine.Annotations["dockermachine.infrastructure.cluster.x-k8s.io/weight"]; !ok {
-				if err := r.reconcileLoadBalancerConfiguration(ctx, cluster, dockerCluster, externalLoadBalancer); err != nil {
-					return ctrl.Result{}, err
-				}
+	// if the corresponding machine is deleted but the lxc machine not yet, update load balancer configuration to divert all traffic from this instance
+	if util.IsControlPlaneMachine(machine) && !machine.DeletionTimestamp.IsZero() && lxcMachine.DeletionTimestamp.IsZero() && cluster.DeletionTimestamp.IsZero() {
+		if _, ok := lxcMachine.Annotations[infrav1.LoadBalancerWeightAnnotation]; !ok {
+			log.FromContext(ctx).Info("Removing control plane machine from load balancer backends")
+			if err := lxcClient.SetLoadBalancerWeight(ctx, lxcMachine, 0); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to set load balancer weight: %w", err)
 			}
-			if dockerMachine.Annotations == nil {
-				dockerMachine.Annotations = map[string]string{}
+			if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to update loadbalancer configuration: %w", err)
 			}
-			dockerMachine.Annotations["dockermachine.infrastructure.cluster.x-k8s.io/weight"] = "0"
 		}
-	*/
+		if lxcMachine.Annotations == nil {
+			lxcMachine.Annotations = map[string]string{}
+		}
+		lxcMachine.Annotations[infrav1.LoadBalancerWeightAnnotation] = "0"
+	}
 
-	// if the machine is already provisioned, return
+	// if the machine is already provisioned, observe the state of the instance and return
 	if lxcMachine.Spec.ProviderID != nil {
-		state, _, err := lxcClient.Client.GetInstanceState(lxcMachine.GetInstanceName())
-		if err != nil {
-			if strings.Contains(err.Error(), "Instance not found") {
-				lxcMachine.Status.Ready = false
-				conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.InstanceDeletedReason, clusterv1.ConditionSeverityError, "Instance %s does not exist anymore", lxcMachine.GetInstanceName())
-				return ctrl.Result{}, nil
-			}
-
-			log.FromContext(ctx).Error(err, "Failed to check instance state")
-			return ctrl.Result{}, err
-		} else {
-			lxcMachine.Status.Ready = true
-			conditions.MarkTrue(lxcMachine, infrav1.InstanceProvisionedCondition)
-			r.setLXCMachineAddresses(lxcMachine, lxcClient.ParseActiveMachineAddresses(state))
-			return ctrl.Result{}, nil
-		}
+		return r.reconcileInstanceState(ctx, lxcCluster, lxcMachine, lxcClient)
 	}
 
 	dataSecretName := machine.Spec.Bootstrap.DataSecretName
@@ -79,14 +68,54 @@ func (r *LXCMachineReconciler) reconcileNormal(ctx context.Context, cluster *clu
 		return ctrl.Result{}, nil
 	}
 
+	// Allocate static addresses from IPAM pools
+	staticAddresses, allocated, err := r.getStaticAddresses(ctx, cluster, lxcMachine)
+	if err != nil {
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.IPAddressClaimFailedReason, clusterv1.ConditionSeverityWarning, "Failed to allocate static addresses: %s", err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to allocate static addresses: %w", err)
+	}
+	if !allocated {
+		log.FromContext(ctx).Info("Waiting for the IPAM provider to allocate static addresses")
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.WaitingForIPAddressesReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	// Wait for the LXCImage referenced by the machine, if any
+	image, imageReady, err := r.getImageSource(ctx, lxcMachine)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to retrieve image: %w", err)
+	}
+	if !imageReady {
+		log.FromContext(ctx).WithValues("image", lxcMachine.Spec.ImageRef.Name).Info("Waiting for LXCImage to be ready")
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.WaitingForImageReason, clusterv1.ConditionSeverityInfo, "Waiting for LXCImage %s", lxcMachine.Spec.ImageRef.Name)
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
 	// Create the lxc instance hosting the machine
 	log.FromContext(ctx).Info("Creating instance")
-	cloudInit, err := r.getBootstrapData(ctx, lxcMachine.Namespace, *dataSecretName)
+	bootstrapData, bootstrapFormat, err := r.getBootstrapData(ctx, lxcMachine.Namespace, *dataSecretName)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to retrieve bootstrap data: %w", err)
 	}
+	networkConfig, vendorData, err := r.renderCloudInitConfig(ctx, cluster, lxcMachine, staticAddresses)
+	if err != nil {
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.CloudInitConfigInvalidReason, clusterv1.ConditionSeverityWarning, "Failed to render cloud-init configuration: %s", err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to render cloud-init configuration: %w", err)
+	}
+	opts := incus.CreateInstanceOptions{
+		Image:           image,
+		Bootstrap:       incus.BootstrapData{Format: bootstrapFormat, Data: bootstrapData, NetworkConfig: networkConfig, VendorData: vendorData},
+		StaticAddresses: staticAddresses,
+	}
+
+	// Use the image fingerprint that is pinned on the LXCMachineTemplate, if any
+	if lxcMachine.Status.ImageFingerprint == "" {
+		if lxcMachine.Status.ImageFingerprint, err = r.getPinnedImageFingerprint(ctx, lxcMachine); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to retrieve pinned image fingerprint: %w", err)
+		}
+	}
 
-	addresses, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, cloudInit)
+	addresses, fingerprint, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, opts)
 	if err != nil {
 		if incus.IsTerminalError(err) {
 			log.FromContext(ctx).Error(err, "Fatal error while creating instance")
@@ -101,32 +130,72 @@ func (r *LXCMachineReconciler) reconcileNormal(ctx context.Context, cluster *clu
 		conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.InstanceProvisioningFailedReason, clusterv1.ConditionSeverityWarning, "Failed to create instance: %s", err.Error())
 		return ctrl.Result{}, fmt.Errorf("failed to create instance: %w", err)
 	}
-	r.setLXCMachineAddresses(lxcMachine, addresses)
+	if fingerprint != "" && lxcMachine.Status.ImageFingerprint != fingerprint {
+		if err := r.pinTemplateImage(ctx, lxcMachine, fingerprint); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to pin image on LXCMachineTemplate: %w", err)
+		}
+		lxcMachine.Status.ImageFingerprint = fingerprint
+	}
+	r.setLXCMachineAddresses(lxcMachine, addresses, lxcCluster.Spec.AddressFamily)
 	conditions.MarkTrue(lxcMachine, infrav1.InstanceProvisionedCondition)
 
 	// update load balancer
-	if util.IsControlPlaneMachine(machine) && !lxcMachine.Status.LoadBalancerConfigured {
-		if err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx); err != nil {
+	if (util.IsControlPlaneMachine(machine) || lxcCluster.HasWorkerLoadBalancerPorts()) && !lxcMachine.Status.LoadBalancerConfigured {
+		if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to update loadbalancer configuration: %w", err)
 		}
 		lxcMachine.Status.LoadBalancerConfigured = true
 	}
 
-	// check cloud-init status on the node
-	cloudInitStatus, err := lxcClient.CheckCloudInitStatus(ctx, lxcMachine.GetInstanceName())
+	// bootstrap timeouts are terminal, even if the bootstrap completes afterwards
+	if conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition) == infrav1.BootstrapTimedOutReason {
+		return ctrl.Result{}, nil
+	}
+
+	// check bootstrap status on the node
+	cloudInitStatus, err := lxcClient.CheckBootstrapStatus(ctx, lxcMachine.GetInstanceName(), bootstrapFormat)
 	if err != nil || cloudInitStatus == cloudinit.StatusUnknown {
-		log.FromContext(ctx).Error(err, "Could not retrieve cloud-init status")
+		log.FromContext(ctx).Error(err, "Could not retrieve bootstrap status")
 		conditions.MarkUnknown(lxcMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrappingUnknownStatusReason, "%s", err)
 	}
+
+	// the bootstrap timeout applies until the bootstrap is finished, including while the status cannot be retrieved
+	if cloudInitStatus != cloudinit.StatusDone && cloudInitStatus != cloudinit.StatusError {
+		if timedOut, err := r.bootstrapTimedOut(lxcMachine, lxcClient); err != nil {
+			log.FromContext(ctx).Error(err, "Could not check bootstrap timeout")
+		} else if timedOut {
+			message := fmt.Sprintf("Bootstrap did not complete within %s", r.bootstrapTimeout(lxcMachine))
+			log.FromContext(ctx).Info("Bootstrap timed out, marking machine as failed")
+			lxcMachine.Status.FailureReason = ptr.To(capierrors.CreateMachineError)
+			lxcMachine.Status.FailureMessage = ptr.To(message)
+			conditions.MarkFalse(lxcMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapTimedOutReason, clusterv1.ConditionSeverityError, "%s", message)
+			return ctrl.Result{}, nil
+		}
+	}
+
 	switch cloudInitStatus {
 	case cloudinit.StatusRunning:
 		log.FromContext(ctx).Info("Waiting for bootstrap script to complete")
 		conditions.MarkFalse(lxcMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrappingReason, clusterv1.ConditionSeverityInfo, "")
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	case cloudinit.StatusUnknown:
+		// the machine is not ready until the bootstrap is finished, retry until the status can be retrieved
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	case cloudinit.StatusError:
-		err := fmt.Errorf("bootstrap failed since cloud-init finished with error status")
-		log.FromContext(ctx).Error(err, "Bootstrap failed, marking machine as failed")
-		conditions.MarkFalse(lxcMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "%s", err)
+		// failure details are only collected once, the instance will not recover from a failed bootstrap
+		if conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition) == infrav1.BootstrapFailedReason {
+			return ctrl.Result{}, nil
+		}
+		message, err := lxcClient.GetCloudInitFailure(ctx, lxcMachine.GetInstanceName())
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Could not retrieve cloud-init failure details")
+			message = "cloud-init finished with error status"
+		}
+		log.FromContext(ctx).Error(fmt.Errorf("bootstrap failed: %s", message), "Bootstrap failed, marking machine as failed")
+		conditions.MarkFalse(lxcMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "Bootstrap failed: %s", message)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(lxcMachine, corev1.EventTypeWarning, infrav1.BootstrapFailedReason, "Bootstrap failed: %s", message)
+		}
 		return ctrl.Result{}, nil
 	case cloudinit.StatusDone:
 		log.FromContext(ctx).Info("Bootstrap finished successfully")
@@ -135,7 +204,14 @@ func (r *LXCMachineReconciler) reconcileNormal(ctx context.Context, cluster *clu
 		// This should never happen, but not adding a panic on purpose. If only Go had enums :)
 	}
 
-	// TODO(neoaggelos): consider editing the instance and unsetting "cloud-init.user-data" configuration key.
+	// remove bootstrap secrets from the instance configuration
+	if cloudInitStatus == cloudinit.StatusDone && !lxcMachine.Status.BootstrapDataScrubbed {
+		scrubbed, err := lxcClient.ScrubBootstrapData(ctx, lxcMachine.GetInstanceName())
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to remove bootstrap data from instance: %w", err)
+		}
+		lxcMachine.Status.BootstrapDataScrubbed = scrubbed
+	}
 
 	if !lxcCluster.Spec.SkipCloudProviderNodePatch {
 		// If the Cluster is using a control plane and the control plane is not yet initialized, there is no API server
@@ -162,7 +238,82 @@ func (r *LXCMachineReconciler) reconcileNormal(ctx context.Context, cluster *clu
 	}
 
 	lxcMachine.Status.Ready = true
+	lxcMachine.Status.InstanceState = "Running"
 	lxcMachine.Spec.ProviderID = ptr.To(lxcMachine.GetExpectedProviderID())
+	conditions.MarkTrue(lxcMachine, infrav1.InstanceRunningCondition)
+
+	return ctrl.Result{RequeueAfter: r.InstanceHealthCheckInterval}, nil
+}
+
+// reconcileInstanceState observes the state of the instance of a provisioned LXCMachine. The LXCMachine is ready only
+// while the instance is running. Stopped or frozen instances are started again if AutoRestart is set, otherwise they
+// are reported through the InstanceRunning condition. Deleted instances and instances in error state are reported
+// as terminal failures, such that the machine is remediated by MachineHealthCheck.
+func (r *LXCMachineReconciler) reconcileInstanceState(ctx context.Context, lxcCluster *infrav1.LXCCluster, lxcMachine *infrav1.LXCMachine, lxcClient *incus.Client) (ctrl.Result, error) {
+	state, _, err := lxcClient.Client.GetInstanceState(lxcMachine.GetInstanceName())
+	if err != nil {
+		if strings.Contains(err.Error(), "Instance not found") {
+			lxcMachine.Status.Ready = false
+			lxcMachine.Status.InstanceState = ""
+			lxcMachine.Status.FailureReason = ptr.To(capierrors.UpdateMachineError)
+			lxcMachine.Status.FailureMessage = ptr.To(fmt.Sprintf("Instance %s does not exist anymore", lxcMachine.GetInstanceName()))
+			conditions.MarkFalse(lxcMachine, infrav1.InstanceProvisionedCondition, infrav1.InstanceDeletedReason, clusterv1.ConditionSeverityError, "Instance %s does not exist anymore", lxcMachine.GetInstanceName())
+			return ctrl.Result{}, nil
+		}
+
+		log.FromContext(ctx).Error(err, "Failed to check instance state")
+		return ctrl.Result{}, err
+	}
+
+	lxcMachine.Status.InstanceState = state.Status
+	r.setLXCMachineAddresses(lxcMachine, lxcClient.ParseActiveMachineAddresses(state), lxcCluster.Spec.AddressFamily)
+	conditions.MarkTrue(lxcMachine, infrav1.InstanceProvisionedCondition)
+
+	switch state.StatusCode {
+	case api.Running:
+		lxcMachine.Status.Ready = true
+		conditions.MarkTrue(lxcMachine, infrav1.InstanceRunningCondition)
+		return ctrl.Result{RequeueAfter: r.InstanceHealthCheckInterval}, nil
+	case api.Stopped, api.Frozen:
+		lxcMachine.Status.Ready = false
+
+		// the Ignition config of virtual machines can only be removed while the instance is stopped
+		if state.StatusCode == api.Stopped && !lxcMachine.Status.BootstrapDataScrubbed {
+			scrubbed, err := lxcClient.ScrubBootstrapData(ctx, lxcMachine.GetInstanceName())
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove bootstrap data from instance: %w", err)
+			}
+			lxcMachine.Status.BootstrapDataScrubbed = scrubbed
+		}
+
+		if lxcMachine.Spec.AutoRestart {
+			log.FromContext(ctx).Info("Starting instance", "status", state.Status)
+			conditions.MarkFalse(lxcMachine, infrav1.InstanceRunningCondition, infrav1.InstanceRestartingReason, clusterv1.ConditionSeverityWarning, "Instance was found %s, starting", strings.ToLower(state.Status))
+			if err := lxcClient.StartInstance(ctx, lxcMachine); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to start instance: %w", err)
+			}
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
 
-	return ctrl.Result{}, nil
+		reason := infrav1.InstanceStoppedReason
+		if state.StatusCode == api.Frozen {
+			reason = infrav1.InstanceFrozenReason
+		}
+		log.FromContext(ctx).Info("Instance is not running", "status", state.Status)
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceRunningCondition, reason, clusterv1.ConditionSeverityError, "Instance is %s", strings.ToLower(state.Status))
+		return ctrl.Result{RequeueAfter: r.InstanceHealthCheckInterval}, nil
+	case api.Error:
+		lxcMachine.Status.Ready = false
+		lxcMachine.Status.FailureReason = ptr.To(capierrors.UpdateMachineError)
+		lxcMachine.Status.FailureMessage = ptr.To(fmt.Sprintf("Instance %s is in error state", lxcMachine.GetInstanceName()))
+		log.FromContext(ctx).Info("Instance is in error state")
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceRunningCondition, infrav1.InstanceErrorReason, clusterv1.ConditionSeverityError, "Instance is in error state")
+		return ctrl.Result{}, nil
+	default:
+		// instance is changing state (e.g. starting, stopping, freezing), check again shortly
+		lxcMachine.Status.Ready = false
+		log.FromContext(ctx).Info("Instance is changing state", "status", state.Status)
+		conditions.MarkFalse(lxcMachine, infrav1.InstanceRunningCondition, infrav1.InstanceStateTransitioningReason, clusterv1.ConditionSeverityInfo, "Instance is %s", strings.ToLower(state.Status))
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
 }
@@ -0,0 +1,993 @@
+package lxcmachine_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	incusclient "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcmachine"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+
+	. "github.com/onsi/gomega"
+)
+
+// testMachineFinalizer keeps Machine objects around after deletion, so that the LXCMachine controller can observe them.
+const testMachineFinalizer = "test.cluster.x-k8s.io/finalizer"
+
+// setupTestCluster creates a namespace with an infrastructure credentials secret, an infrastructure ready Cluster and an
+// LXCCluster using the "lxc" load balancer. The load balancer instance is created on the fake server.
+func setupTestCluster(g *WithT, server *fake.Server) (*clusterv1.Cluster, *infrav1.LXCCluster) {
+	ctx := context.TODO()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "lxcmachine-"}}
+	g.Expect(testClient.Create(ctx, ns)).To(Succeed())
+
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "lxc-secret", Namespace: ns.Name},
+		Data:       map[string][]byte{"server": []byte("https://fake:8443")},
+	})).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: ns.Name},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{APIVersion: infrav1.GroupVersion.String(), Kind: "LXCCluster", Name: "c1", Namespace: ns.Name},
+		},
+	}
+	g.Expect(testClient.Create(ctx, cluster)).To(Succeed())
+	cluster.Status.InfrastructureReady = true
+	g.Expect(testClient.Status().Update(ctx, cluster)).To(Succeed())
+
+	lxcCluster := &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "c1",
+			Namespace: ns.Name,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: cluster.Name, UID: cluster.UID},
+			},
+		},
+		Spec: infrav1.LXCClusterSpec{
+			SecretRef:                  infrav1.SecretRef{Name: "lxc-secret"},
+			LoadBalancer:               infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+			SkipDefaultKubeadmProfile:  true,
+			SkipCloudProviderNodePatch: true,
+		},
+	}
+	g.Expect(testClient.Create(ctx, lxcCluster)).To(Succeed())
+
+	_, err := (&incus.Client{Client: server}).LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return cluster, lxcCluster
+}
+
+// createTestMachine creates a control plane Machine with bootstrap data and an owned LXCMachine.
+func createTestMachine(g *WithT, cluster *clusterv1.Cluster, name string) *infrav1.LXCMachine {
+	ctx := context.TODO()
+
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-bootstrap", name), Namespace: cluster.Namespace},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	})).To(Succeed())
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  cluster.Namespace,
+			Finalizers: []string{testMachineFinalizer},
+			Labels: map[string]string{
+				clusterv1.ClusterNameLabel:         cluster.Name,
+				clusterv1.MachineControlPlaneLabel: "",
+			},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: cluster.Name,
+			Version:     ptr.To("v1.32.0"),
+			Bootstrap:   clusterv1.Bootstrap{DataSecretName: ptr.To(fmt.Sprintf("%s-bootstrap", name))},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       "LXCMachine",
+				Name:       name,
+				Namespace:  cluster.Namespace,
+			},
+		},
+	}
+	g.Expect(testClient.Create(ctx, machine)).To(Succeed())
+
+	lxcMachine := &infrav1.LXCMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cluster.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Machine", Name: machine.Name, UID: machine.UID},
+			},
+		},
+		Spec: infrav1.LXCMachineSpec{
+			Image: infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://images.example.com", Protocol: "simplestreams"},
+		},
+	}
+	g.Expect(testClient.Create(ctx, lxcMachine)).To(Succeed())
+
+	return lxcMachine
+}
+
+// reconcileUntil reconciles the LXCMachine until the check succeeds.
+func reconcileUntil(g *WithT, r *lxcmachine.LXCMachineReconciler, lxcMachine *infrav1.LXCMachine, check func(g Gomega, lxcMachine *infrav1.LXCMachine)) {
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachine)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcMachine), lxcMachine)).To(Succeed())
+		check(g, lxcMachine)
+	}).Should(Succeed())
+}
+
+func readInstanceFile(g Gomega, server *fake.Server, instanceName string, path string) string {
+	reader, _, err := server.GetInstanceFile(instanceName, path)
+	g.Expect(err).ToNot(HaveOccurred())
+	b, err := io.ReadAll(reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	return string(b)
+}
+
+func TestLXCMachineReconciler(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, lxcCluster := setupTestCluster(g, server)
+	lxcCluster.Status.KubeadmProfileMode = infrav1.KubeadmProfileModeUnprivileged
+	g.Expect(testClient.Status().Update(ctx, lxcCluster)).To(Succeed())
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+	lbName := lxcCluster.GetLoadBalancerInstanceName()
+
+	var address string
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("user.cluster-role", "control-plane"))
+		g.Expect(instance.Config).To(HaveKeyWithValue("cloud-init.user-data", "#cloud-config"))
+
+		// the cluster uses the unprivileged kubeadm profile, so the instance is configured as an unprivileged container
+		g.Expect(readInstanceFile(g, server, lxcMachine.GetInstanceName(), "/etc/systemd/system/kubelet.service.d/20-cluster-api-lxc-unprivileged.conf")).To(ContainSubstring("KubeletInUserNamespace=true"))
+
+		g.Expect(lxcMachine.Status.Addresses).To(ContainElement(HaveField("Type", clusterv1.MachineInternalIP)))
+		for _, addr := range lxcMachine.Status.Addresses {
+			if addr.Type == clusterv1.MachineInternalIP {
+				address = addr.Address
+			}
+		}
+		g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("LoadBalancer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(lxcMachine.Status.LoadBalancerConfigured).To(BeTrue())
+		g.Expect(readInstanceFile(g, server, lbName, "/etc/haproxy/haproxy.cfg")).To(ContainSubstring("%s:6443 weight 100", address))
+	})
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		g := NewWithT(t)
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		instanceID := instance.Config["volatile.cloud-init.instance-id"]
+		g.Expect(instanceID).ToNot(BeEmpty())
+
+		g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName())).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeTrue())
+		})
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+		g.Expect(lxcMachine.Spec.ProviderID).To(Equal(ptr.To(lxcMachine.GetExpectedProviderID())))
+
+		// bootstrap secrets are removed from the instance configuration
+		g.Expect(lxcMachine.Status.BootstrapDataScrubbed).To(BeTrue())
+		instance, _, err = server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).ToNot(HaveKey("cloud-init.user-data"))
+		g.Expect(instance.Config).To(HaveKeyWithValue("user.cluster-role", "control-plane"))
+
+		// the cloud-init instance-id is kept, so that cloud-init does not run again when the instance restarts
+		g.Expect(instance.Config).To(HaveKeyWithValue("volatile.cloud-init.instance-id", instanceID))
+	})
+
+	t.Run("DrainLoadBalancer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(ctx, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: lxcMachine.Name, Namespace: lxcMachine.Namespace}})).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Annotations).To(HaveKeyWithValue(infrav1.LoadBalancerWeightAnnotation, "0"))
+		})
+		g.Expect(readInstanceFile(g, server, lbName, "/etc/haproxy/haproxy.cfg")).To(ContainSubstring("%s:6443 weight 0", address))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(ctx, lxcMachine)).To(Succeed())
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachine)})
+			g.Expect(err).ToNot(HaveOccurred())
+			err = testClient.Get(ctx, client.ObjectKeyFromObject(lxcMachine), &infrav1.LXCMachine{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+
+		g.Expect(server.InstanceNames()).To(ConsistOf(lbName))
+		g.Expect(readInstanceFile(g, server, lbName, "/etc/haproxy/haproxy.cfg")).ToNot(ContainSubstring(address))
+	})
+}
+
+func TestLXCMachineReconciler_BootstrapFailed(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer()
+	recorder := record.NewFakeRecorder(10)
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		Recorder:      recorder,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+	})
+
+	// the cluster does not use the unprivileged kubeadm profile
+	_, _, err := server.GetInstanceFile(lxcMachine.GetInstanceName(), "/etc/systemd/system/kubelet.service.d/20-cluster-api-lxc-unprivileged.conf")
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(server.CreateInstanceFile(lxcMachine.GetInstanceName(), "/var/log/cloud-init-output.log", incusclient.InstanceFileArgs{
+		Content: strings.NewReader("[preflight] Running pre-flight checks\nerror execution phase preflight: port 6443 is in use\n"),
+	})).To(Succeed())
+	g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName(), "failed to run kubeadm init")).To(Succeed())
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapFailedReason))
+	})
+	g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+
+	message := conditions.GetMessage(lxcMachine, infrav1.BootstrapSucceededCondition)
+	g.Expect(message).To(ContainSubstring("cloud-init failed in stage modules-final: failed to run kubeadm init"))
+	g.Expect(message).To(ContainSubstring("error execution phase preflight: port 6443 is in use"))
+
+	g.Expect(recorder.Events).To(HaveLen(1))
+	event := <-recorder.Events
+	g.Expect(event).To(HavePrefix("Warning " + infrav1.BootstrapFailedReason))
+	g.Expect(event).To(ContainSubstring("failed to run kubeadm init"))
+
+	// failure details are only reported once
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapFailedReason))
+	})
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	state, _, err := server.GetInstanceState(lxcMachine.GetInstanceName())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(state.StatusCode).To(Equal(api.Running))
+}
+
+func TestLXCMachineReconciler_BootstrapTimeout(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:           testClient,
+		CachingClient:    testClient,
+		BootstrapTimeout: time.Hour,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(NewWithT(t), server)
+
+	t.Run("Default", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createTestMachine(g, cluster, "c1-md-0-0")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+
+		g.Expect(server.SetInstanceCreatedAt(lxcMachine.GetInstanceName(), time.Now().Add(-2*time.Hour))).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapTimedOutReason))
+		})
+		g.Expect(conditions.GetSeverity(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(ptr.To(clusterv1.ConditionSeverityError)))
+		g.Expect(lxcMachine.Status.FailureReason).To(Equal(ptr.To(capierrors.CreateMachineError)))
+		g.Expect(lxcMachine.Status.FailureMessage).To(Equal(ptr.To("Bootstrap did not complete within 1h0m0s")))
+
+		// bootstrap completing after the timeout does not recover the machine
+		g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName())).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapTimedOutReason))
+		})
+		g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("UnknownStatus", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createTestMachine(g, cluster, "c1-md-0-2")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+
+		// the cloud-init status cannot be parsed
+		g.Expect(server.CreateInstanceFile(lxcMachine.GetInstanceName(), "/var/lib/cloud/data/status.json", incusclient.InstanceFileArgs{
+			Content:   strings.NewReader("invalid"),
+			WriteMode: "overwrite",
+		})).To(Succeed())
+
+		// the machine is not ready while the status cannot be retrieved
+		result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachine)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.RequeueAfter).ToNot(BeZero())
+		g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcMachine), lxcMachine)).To(Succeed())
+		g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingUnknownStatusReason))
+		g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+		g.Expect(lxcMachine.Spec.ProviderID).To(BeNil())
+
+		g.Expect(server.SetInstanceCreatedAt(lxcMachine.GetInstanceName(), time.Now().Add(-2*time.Hour))).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapTimedOutReason))
+		})
+		g.Expect(lxcMachine.Status.FailureReason).To(Equal(ptr.To(capierrors.CreateMachineError)))
+		g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createTestMachine(g, cluster, "c1-md-0-1")
+		lxcMachine.Spec.BootstrapTimeout = &metav1.Duration{}
+		g.Expect(testClient.Update(context.TODO(), lxcMachine)).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+
+		g.Expect(server.SetInstanceCreatedAt(lxcMachine.GetInstanceName(), time.Now().Add(-2*time.Hour))).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+		g.Expect(lxcMachine.Status.FailureReason).To(BeNil())
+
+		g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName())).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeTrue())
+		})
+	})
+}
+
+func TestLXCMachineReconciler_InstanceState(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+	})
+	g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName())).To(Succeed())
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(lxcMachine.Status.Ready).To(BeTrue())
+	})
+	g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceRunningCondition)).To(BeTrue())
+	g.Expect(lxcMachine.Status.InstanceState).To(Equal("Running"))
+
+	t.Run("Stopped", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(server.SetInstanceStatus(lxcMachine.GetInstanceName(), api.Stopped)).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+		})
+		g.Expect(lxcMachine.Status.InstanceState).To(Equal("Stopped"))
+		g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceRunningCondition)).To(Equal(infrav1.InstanceStoppedReason))
+		g.Expect(conditions.GetSeverity(lxcMachine, infrav1.InstanceRunningCondition)).To(Equal(ptr.To(clusterv1.ConditionSeverityError)))
+		g.Expect(lxcMachine.Status.FailureReason).To(BeNil())
+	})
+
+	t.Run("AutoRestart", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine.Spec.AutoRestart = true
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeTrue())
+		})
+		g.Expect(lxcMachine.Status.InstanceState).To(Equal("Running"))
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceRunningCondition)).To(BeTrue())
+
+		state, _, err := server.GetInstanceState(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(state.StatusCode).To(Equal(api.Running))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(server.SetInstanceStatus(lxcMachine.GetInstanceName(), api.Error)).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeFalse())
+		})
+		g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceRunningCondition)).To(Equal(infrav1.InstanceErrorReason))
+		g.Expect(lxcMachine.Status.FailureReason).ToNot(BeNil())
+		g.Expect(lxcMachine.Status.FailureMessage).ToNot(BeNil())
+	})
+}
+
+func TestLXCMachineReconciler_WorkerLoadBalancerPorts(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, lxcCluster := setupTestCluster(g, server)
+	lxcCluster.Spec.AdditionalLoadBalancerPorts = []infrav1.LXCLoadBalancerPort{
+		{Name: "ingress", Port: 30000, EndPort: 30100, TargetRole: infrav1.LoadBalancerPortTargetRoleWorker},
+	}
+	g.Expect(testClient.Update(ctx, lxcCluster)).To(Succeed())
+
+	lxcMachine := createTestMachine(g, cluster, "c1-md-0")
+	machine := &clusterv1.Machine{}
+	g.Expect(testClient.Get(ctx, client.ObjectKey{Name: lxcMachine.Name, Namespace: lxcMachine.Namespace}, machine)).To(Succeed())
+	delete(machine.Labels, clusterv1.MachineControlPlaneLabel)
+	g.Expect(testClient.Update(ctx, machine)).To(Succeed())
+
+	reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+	})
+	g.Expect(lxcMachine.Status.LoadBalancerConfigured).To(BeTrue())
+
+	haproxyCfg := readInstanceFile(g, server, lxcCluster.GetLoadBalancerInstanceName(), "/etc/haproxy/haproxy.cfg")
+	g.Expect(haproxyCfg).To(ContainSubstring("bind *:30000-30100"))
+	g.Expect(haproxyCfg).To(ContainSubstring("server %s ", lxcMachine.GetInstanceName()))
+}
+
+func TestLXCMachineReconciler_AddressesFromPools(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer(fake.WithNetworks(api.Network{Name: "br0", Type: "bridge", Managed: true}))
+	g.Expect(server.CreateProfile(api.ProfilesPost{Name: "static", ProfilePut: api.ProfilePut{Devices: map[string]map[string]string{
+		"eth0": {"type": "nic", "network": "br0"},
+		"eth1": {"type": "nic", "nictype": "macvlan", "parent": "enp5s0"},
+	}}})).To(Succeed())
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+	poolRef := corev1.TypedLocalObjectReference{APIGroup: ptr.To("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"}
+	lxcMachine.Spec.Profiles = []string{"static"}
+	lxcMachine.Spec.AddressesFromPools = []infrav1.LXCMachineAddressFromPool{
+		{Device: "eth0", PoolRef: poolRef},
+		{Device: "eth1", PoolRef: poolRef},
+	}
+	g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+	t.Run("WaitForAddresses", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.WaitingForIPAddressesReason))
+		})
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(lxcMachine.GetInstanceName()))
+
+		for idx := range lxcMachine.Spec.AddressesFromPools {
+			claim := &ipamv1.IPAddressClaim{}
+			g.Expect(testClient.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: lxcMachine.GetIPAddressClaimName(idx)}, claim)).To(Succeed())
+			g.Expect(claim.Spec.PoolRef).To(Equal(poolRef))
+			g.Expect(claim.Spec.ClusterName).To(Equal(cluster.Name))
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// simulate the IPAM provider allocating the addresses
+		for idx, address := range []string{"10.100.0.10", "192.168.1.10"} {
+			claim := &ipamv1.IPAddressClaim{}
+			g.Expect(testClient.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: lxcMachine.GetIPAddressClaimName(idx)}, claim)).To(Succeed())
+			g.Expect(testClient.Create(ctx, &ipamv1.IPAddress{
+				ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+				Spec: ipamv1.IPAddressSpec{
+					ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+					PoolRef:  poolRef,
+					Address:  address,
+					Prefix:   24,
+					Gateway:  "192.168.1.1",
+				},
+			})).To(Succeed())
+			claim.Status.AddressRef = corev1.LocalObjectReference{Name: claim.Name}
+			g.Expect(testClient.Status().Update(ctx, claim)).To(Succeed())
+		}
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Devices).To(HaveKeyWithValue("eth0", HaveKeyWithValue("ipv4.address", "10.100.0.10")))
+		g.Expect(instance.Devices).ToNot(HaveKey("eth1"))
+		g.Expect(instance.Config).To(HaveKeyWithValue("cloud-init.network-config", MatchYAML(`
+version: 2
+ethernets:
+  eth0:
+    dhcp4: true
+    dhcp6: true
+  eth1:
+    addresses: [192.168.1.10/24]
+    routes: [{to: 0.0.0.0/0, via: 192.168.1.1}]
+`)))
+	})
+
+	t.Run("CloudInit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// the network-config is rendered from the ConfigMap and replaces the generated configuration
+		lxcMachine := createTestMachine(g, cluster, "c1-control-plane-1")
+		g.Expect(testClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cloud-init", Namespace: lxcMachine.Namespace},
+			Data: map[string]string{"network-config": `version: 2
+ethernets:
+{{- range .Addresses }}
+  {{ .Device }}: {addresses: [{{ .Address }}/{{ .Prefix }}]}
+{{- end }}`},
+		})).To(Succeed())
+		lxcMachine.Spec.AddressesFromPools = []infrav1.LXCMachineAddressFromPool{{Device: "eth1", PoolRef: poolRef}}
+		lxcMachine.Spec.Profiles = []string{"static"}
+		lxcMachine.Spec.CloudInit = &infrav1.LXCMachineCloudInit{
+			NetworkConfig: &infrav1.LXCMachineCloudInitSource{ConfigMapKeyRef: &infrav1.ConfigMapKeyRef{Name: "cloud-init"}},
+			VendorData:    &infrav1.LXCMachineCloudInitSource{Inline: "#cloud-config\nhostname: {{ .InstanceName }}\n"},
+		}
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.WaitingForIPAddressesReason))
+		})
+		claim := &ipamv1.IPAddressClaim{}
+		g.Expect(testClient.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: lxcMachine.GetIPAddressClaimName(0)}, claim)).To(Succeed())
+		g.Expect(testClient.Create(ctx, &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+			Spec:       ipamv1.IPAddressSpec{ClaimRef: corev1.LocalObjectReference{Name: claim.Name}, PoolRef: poolRef, Address: "192.168.1.11", Prefix: 24},
+		})).To(Succeed())
+		claim.Status.AddressRef = corev1.LocalObjectReference{Name: claim.Name}
+		g.Expect(testClient.Status().Update(ctx, claim)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("cloud-init.network-config", "version: 2\nethernets:\n  eth1: {addresses: [192.168.1.11/24]}"))
+		g.Expect(instance.Config).To(HaveKeyWithValue("cloud-init.vendor-data", "#cloud-config\nhostname: c1-control-plane-1\n"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(ctx, lxcMachine)).To(Succeed())
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachine)})
+			g.Expect(err).ToNot(HaveOccurred())
+			err = testClient.Get(ctx, client.ObjectKeyFromObject(lxcMachine), &infrav1.LXCMachine{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+
+		for idx := range lxcMachine.Spec.AddressesFromPools {
+			err := testClient.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: lxcMachine.GetIPAddressClaimName(idx)}, &ipamv1.IPAddressClaim{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+	})
+}
+
+func TestLXCMachineReconciler_Ignition(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+
+	// createIgnitionMachine creates a machine with ignition bootstrap data
+	createIgnitionMachine := func(g *WithT, name string, instanceType string) *infrav1.LXCMachine {
+		lxcMachine := createTestMachine(g, cluster, name)
+		g.Expect(testClient.Update(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-bootstrap", name), Namespace: cluster.Namespace},
+			Data:       map[string][]byte{"value": []byte(`{"ignition":{"version":"3.4.0"}}`), "format": []byte("ignition")},
+		})).To(Succeed())
+		lxcMachine.Spec.InstanceType = instanceType
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+		return lxcMachine
+	}
+
+	t.Run("VirtualMachine", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createIgnitionMachine(g, "c1-control-plane-0", "virtual-machine")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrappingReason))
+		})
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("raw.qemu", ContainSubstring(`name=opt/com.coreos/config,string={"ignition":{"version":"3.4.0"}}`)))
+		g.Expect(instance.Config).ToNot(HaveKey("cloud-init.user-data"))
+
+		// bootstrap is complete once the sentinel file is written
+		g.Expect(server.CreateInstanceFile(lxcMachine.GetInstanceName(), "/run/cluster-api/bootstrap-success.complete", incusclient.InstanceFileArgs{Content: strings.NewReader("success")})).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.Ready).To(BeTrue())
+		})
+		g.Expect(conditions.IsTrue(lxcMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+
+		// the ignition config cannot be removed while the virtual machine is running
+		g.Expect(lxcMachine.Status.BootstrapDataScrubbed).To(BeFalse())
+		instance, _, err = server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKey("raw.qemu"))
+
+		// the ignition config is removed from the instance configuration once the instance is stopped
+		g.Expect(server.SetInstanceStatus(lxcMachine.GetInstanceName(), api.Stopped)).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(lxcMachine.Status.BootstrapDataScrubbed).To(BeTrue())
+		})
+		instance, _, err = server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).ToNot(HaveKey("raw.qemu"))
+	})
+
+	t.Run("Container", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createIgnitionMachine(g, "c1-control-plane-1", "container")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.InstanceProvisioningAbortedReason))
+		})
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(lxcMachine.GetInstanceName()))
+	})
+
+	t.Run("CloudInitConfig", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createIgnitionMachine(g, "c1-control-plane-2", "virtual-machine")
+		lxcMachine.Spec.CloudInit = &infrav1.LXCMachineCloudInit{
+			NetworkConfig: &infrav1.LXCMachineCloudInitSource{Inline: "version: 2"},
+		}
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.InstanceProvisioningAbortedReason))
+		})
+		g.Expect(conditions.GetMessage(lxcMachine, infrav1.InstanceProvisionedCondition)).To(ContainSubstring("not supported with ignition bootstrap data"))
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(lxcMachine.GetInstanceName()))
+	})
+}
+
+func TestLXCMachineReconciler_Volumes(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer(fake.WithStoragePools("default", "fast"))
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+	lxcMachine.Spec.Volumes = []infrav1.LXCMachineVolume{
+		{Name: "containerd", Pool: "default", Size: "20GiB", Path: "/var/lib/containerd"},
+		{Name: "etcd", Pool: "fast", Path: "/var/lib/etcd", DeletionPolicy: infrav1.VolumeDeletionPolicyRetain},
+	}
+	g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+	name := lxcMachine.GetInstanceName()
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+
+		volume, _, err := server.GetStoragePoolVolume("default", "custom", name+"-containerd")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(volume.ContentType).To(Equal("filesystem"))
+		g.Expect(volume.Config).To(HaveKeyWithValue("size", "20GiB"))
+		g.Expect(volume.Config).To(HaveKeyWithValue("user.cluster-name", cluster.Name))
+
+		volume, _, err = server.GetStoragePoolVolume("fast", "custom", name+"-etcd")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(volume.Config).ToNot(HaveKey("size"))
+
+		instance, _, err := server.GetInstance(name)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Devices).To(HaveKeyWithValue("containerd", map[string]string{"type": "disk", "pool": "default", "source": name + "-containerd", "path": "/var/lib/containerd"}))
+		g.Expect(instance.Devices).To(HaveKeyWithValue("etcd", map[string]string{"type": "disk", "pool": "fast", "source": name + "-etcd", "path": "/var/lib/etcd"}))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(ctx, lxcMachine)).To(Succeed())
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachine)})
+			g.Expect(err).ToNot(HaveOccurred())
+			err = testClient.Get(ctx, client.ObjectKeyFromObject(lxcMachine), &infrav1.LXCMachine{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+
+		_, _, err := server.GetStoragePoolVolume("default", "custom", name+"-containerd")
+		g.Expect(err).To(MatchError(ContainSubstring("not found")))
+
+		// retained volumes are kept after the machine is deleted
+		_, _, err = server.GetStoragePoolVolume("fast", "custom", name+"-etcd")
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("BlockVolumeOnContainer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createTestMachine(g, cluster, "c1-control-plane-1")
+		lxcMachine.Spec.Volumes = []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", ContentType: infrav1.VolumeContentTypeBlock}}
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.InstanceProvisioningAbortedReason))
+		})
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(lxcMachine.GetInstanceName()))
+	})
+}
+
+func TestLXCMachineReconciler_ImageRef(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+	lxcMachine := createTestMachine(g, cluster, "c1-control-plane-0")
+	lxcMachine.Spec.Image = infrav1.LXCMachineImageSource{}
+	lxcMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "kubeadm-v1.32.0"}
+	g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+
+	lxcImage := &infrav1.LXCImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-v1.32.0", Namespace: cluster.Namespace},
+		Spec: infrav1.LXCImageSpec{
+			SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+			Image:     infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://images.example.com", Protocol: "simplestreams"},
+		},
+	}
+
+	t.Run("WaitForImage", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// the LXCImage does not exist yet
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.WaitingForImageReason))
+		})
+
+		// the LXCImage is not ready
+		g.Expect(testClient.Create(ctx, lxcImage)).To(Succeed())
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.GetReason(lxcMachine, infrav1.InstanceProvisionedCondition)).To(Equal(infrav1.WaitingForImageReason))
+		})
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(lxcMachine.GetInstanceName()))
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcImage.Status.Ready = true
+		lxcImage.Status.Fingerprint = "f1"
+		g.Expect(testClient.Status().Update(ctx, lxcImage)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("volatile.base_image", "f1"))
+	})
+}
+
+func TestLXCMachineReconciler_ImageFingerprint(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer(fake.WithRemoteImage("https://images.example.com", "kubeadm/v1.32.0", "f1"))
+	r := &lxcmachine.LXCMachineReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	cluster, _ := setupTestCluster(g, server)
+
+	lxcMachineTemplate := &infrav1.LXCMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "control-plane",
+			Namespace:   cluster.Namespace,
+			Annotations: map[string]string{infrav1.PinImageAnnotation: "true"},
+		},
+		Spec: infrav1.LXCMachineTemplateSpec{Template: infrav1.LXCMachineTemplateResource{Spec: infrav1.LXCMachineSpec{
+			Image: infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://images.example.com", Protocol: "simplestreams"},
+		}}},
+	}
+	g.Expect(testClient.Create(ctx, lxcMachineTemplate)).To(Succeed())
+
+	createClonedMachine := func(g *WithT, name string) *infrav1.LXCMachine {
+		lxcMachine := createTestMachine(g, cluster, name)
+		lxcMachine.Annotations = map[string]string{
+			clusterv1.TemplateClonedFromNameAnnotation:      lxcMachineTemplate.Name,
+			clusterv1.TemplateClonedFromGroupKindAnnotation: "LXCMachineTemplate.infrastructure.cluster.x-k8s.io",
+		}
+		g.Expect(testClient.Update(ctx, lxcMachine)).To(Succeed())
+		return lxcMachine
+	}
+
+	t.Run("Resolve", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcMachine := createClonedMachine(g, "c1-control-plane-0")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+		g.Expect(lxcMachine.Status.ImageFingerprint).To(Equal("f1"))
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("user.cluster-image-fingerprint", "f1"))
+
+		g.Expect(testClient.Get(ctx, client.ObjectKeyFromObject(lxcMachineTemplate), lxcMachineTemplate)).To(Succeed())
+		g.Expect(lxcMachineTemplate.Annotations).To(HaveKeyWithValue(infrav1.ImageFingerprintAnnotation, "f1"))
+	})
+
+	t.Run("Pinned", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// the upstream alias moves to a new image build
+		fake.WithRemoteImage("https://images.example.com", "kubeadm/v1.32.0", "f2")(server)
+
+		lxcMachine := createClonedMachine(g, "c1-control-plane-1")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+		g.Expect(lxcMachine.Status.ImageFingerprint).To(Equal("f1"))
+
+		instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(instance.Config).To(HaveKeyWithValue("volatile.base_image", "f1"))
+		g.Expect(instance.Config).To(HaveKeyWithValue("user.cluster-image-fingerprint", "f1"))
+	})
+
+	t.Run("NotPinned", func(t *testing.T) {
+		g := NewWithT(t)
+
+		// machines that are not cloned from a template with the pin-image annotation use the latest image build
+		lxcMachine := createTestMachine(g, cluster, "c1-control-plane-2")
+		reconcileUntil(g, r, lxcMachine, func(g Gomega, lxcMachine *infrav1.LXCMachine) {
+			g.Expect(conditions.IsTrue(lxcMachine, infrav1.InstanceProvisionedCondition)).To(BeTrue())
+		})
+		g.Expect(lxcMachine.Status.ImageFingerprint).To(Equal("f2"))
+	})
+}
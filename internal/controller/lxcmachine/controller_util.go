@@ -4,20 +4,27 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/util"
 )
 
 func patchLXCMachine(ctx context.Context, patchHelper *patch.Helper, lxcMachine *infrav1.LXCMachine) error {
 	infraConditions := []clusterv1.ConditionType{
 		infrav1.InstanceProvisionedCondition,
 		infrav1.BootstrapSucceededCondition,
+		infrav1.InstanceRunningCondition,
 	}
 	hasInfraConditionError := false
 	for _, condition := range lxcMachine.GetConditions() {
@@ -43,37 +50,199 @@ func patchLXCMachine(ctx context.Context, patchHelper *patch.Helper, lxcMachine
 	)
 }
 
-func (r *LXCMachineReconciler) getBootstrapData(ctx context.Context, namespace string, dataSecretName string) (string, error) {
+// getStaticAddresses returns the static addresses of the LXCMachine allocated from IPAM pools, creating any missing
+// IPAddressClaim objects. It returns nil if not all addresses have been allocated yet.
+func (r *LXCMachineReconciler) getStaticAddresses(ctx context.Context, cluster *clusterv1.Cluster, lxcMachine *infrav1.LXCMachine) ([]incus.StaticAddress, bool, error) {
+	staticAddresses := make([]incus.StaticAddress, 0, len(lxcMachine.Spec.AddressesFromPools))
+	allocated := true
+	for idx, entry := range lxcMachine.Spec.AddressesFromPools {
+		address, err := util.GetOrCreateIPAddressClaim(ctx, r.Client, lxcMachine, infrav1.GroupVersion.WithKind("LXCMachine"), lxcMachine.GetIPAddressClaimName(idx), cluster.Name, entry.PoolRef)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get address for device %q: %w", entry.Device, err)
+		}
+		if address == nil {
+			allocated = false
+			continue
+		}
+		staticAddresses = append(staticAddresses, incus.StaticAddress{
+			Device:  entry.Device,
+			Address: address.Spec.Address,
+			Prefix:  address.Spec.Prefix,
+			Gateway: address.Spec.Gateway,
+		})
+	}
+	if !allocated {
+		return nil, false, nil
+	}
+	return staticAddresses, true, nil
+}
+
+// getImageSource returns the image source of the LXCMachine instance. For machines that reference a LXCImage, this is
+// the copy of the image on the LXC server. It returns false if the LXCImage does not exist or is not ready yet.
+func (r *LXCMachineReconciler) getImageSource(ctx context.Context, lxcMachine *infrav1.LXCMachine) (infrav1.LXCMachineImageSource, bool, error) {
+	if lxcMachine.Spec.ImageRef == nil {
+		return lxcMachine.Spec.Image, true, nil
+	}
+
+	lxcImage := &infrav1.LXCImage{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: lxcMachine.Spec.ImageRef.Name}, lxcImage); err != nil {
+		if apierrors.IsNotFound(err) {
+			return infrav1.LXCMachineImageSource{}, false, nil
+		}
+		return infrav1.LXCMachineImageSource{}, false, fmt.Errorf("failed to retrieve LXCImage %q: %w", lxcMachine.Spec.ImageRef.Name, err)
+	}
+	if !lxcImage.Status.Ready {
+		return infrav1.LXCMachineImageSource{}, false, nil
+	}
+	return lxcImage.GetImageSource(), true, nil
+}
+
+// getLXCMachineTemplate returns the LXCMachineTemplate that the LXCMachine was cloned from. It returns nil if the
+// LXCMachine was not cloned from a LXCMachineTemplate, or if the template does not exist anymore.
+func (r *LXCMachineReconciler) getLXCMachineTemplate(ctx context.Context, lxcMachine *infrav1.LXCMachine) (*infrav1.LXCMachineTemplate, error) {
+	name := lxcMachine.Annotations[clusterv1.TemplateClonedFromNameAnnotation]
+	groupKind := lxcMachine.Annotations[clusterv1.TemplateClonedFromGroupKindAnnotation]
+	if name == "" || groupKind != infrav1.GroupVersion.WithKind("LXCMachineTemplate").GroupKind().String() {
+		return nil, nil
+	}
+
+	lxcMachineTemplate := &infrav1.LXCMachineTemplate{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: lxcMachine.Namespace, Name: name}, lxcMachineTemplate); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve LXCMachineTemplate %q: %w", name, err)
+	}
+	return lxcMachineTemplate, nil
+}
+
+// getPinnedImageFingerprint returns the image fingerprint that is pinned on the LXCMachineTemplate of the LXCMachine,
+// if any. See infrav1.PinImageAnnotation.
+func (r *LXCMachineReconciler) getPinnedImageFingerprint(ctx context.Context, lxcMachine *infrav1.LXCMachine) (string, error) {
+	lxcMachineTemplate, err := r.getLXCMachineTemplate(ctx, lxcMachine)
+	if err != nil || lxcMachineTemplate == nil {
+		return "", err
+	}
+	if lxcMachineTemplate.Annotations[infrav1.PinImageAnnotation] != "true" {
+		return "", nil
+	}
+	return lxcMachineTemplate.Annotations[infrav1.ImageFingerprintAnnotation], nil
+}
+
+// pinTemplateImage records the image fingerprint of the LXCMachine instance on its LXCMachineTemplate, if the template
+// has the infrav1.PinImageAnnotation and does not have a pinned image fingerprint yet.
+func (r *LXCMachineReconciler) pinTemplateImage(ctx context.Context, lxcMachine *infrav1.LXCMachine, fingerprint string) error {
+	lxcMachineTemplate, err := r.getLXCMachineTemplate(ctx, lxcMachine)
+	if err != nil || lxcMachineTemplate == nil {
+		return err
+	}
+	if lxcMachineTemplate.Annotations[infrav1.PinImageAnnotation] != "true" || lxcMachineTemplate.Annotations[infrav1.ImageFingerprintAnnotation] != "" {
+		return nil
+	}
+
+	log.FromContext(ctx).WithValues("lxcMachineTemplate", lxcMachineTemplate.Name, "fingerprint", fingerprint).Info("Pinning image on LXCMachineTemplate")
+
+	// use an optimistic lock, so that concurrent machines do not overwrite the pinned image fingerprint
+	patch := client.MergeFromWithOptions(lxcMachineTemplate.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	lxcMachineTemplate.Annotations[infrav1.ImageFingerprintAnnotation] = fingerprint
+	if err := r.Client.Patch(ctx, lxcMachineTemplate, patch); err != nil {
+		return fmt.Errorf("failed to patch LXCMachineTemplate %q: %w", lxcMachineTemplate.Name, err)
+	}
+	return nil
+}
+
+// renderCloudInitConfig renders the cloud-init network-config and vendor-data of the LXCMachine instance.
+func (r *LXCMachineReconciler) renderCloudInitConfig(ctx context.Context, cluster *clusterv1.Cluster, lxcMachine *infrav1.LXCMachine, staticAddresses []incus.StaticAddress) (string, string, error) {
+	data := cloudinit.TemplateData{
+		InstanceName: lxcMachine.GetInstanceName(),
+		ClusterName:  cluster.Name,
+		Namespace:    cluster.Namespace,
+		Addresses:    make([]cloudinit.TemplateAddress, 0, len(staticAddresses)),
+	}
+	for _, address := range staticAddresses {
+		data.Addresses = append(data.Addresses, cloudinit.TemplateAddress{
+			Device:  address.Device,
+			Address: address.Address,
+			Prefix:  address.Prefix,
+			Gateway: address.Gateway,
+		})
+	}
+	return util.RenderCloudInitConfig(ctx, r.Client, lxcMachine.Namespace, lxcMachine.Spec.CloudInit, data)
+}
+
+// getBootstrapData returns the bootstrap data and its format from the bootstrap data secret. The format defaults to cloud-config.
+func (r *LXCMachineReconciler) getBootstrapData(ctx context.Context, namespace string, dataSecretName string) (string, string, error) {
 	s := &corev1.Secret{}
 	key := client.ObjectKey{Namespace: namespace, Name: dataSecretName}
 	if err := r.Client.Get(ctx, key, s); err != nil {
-		return "", fmt.Errorf("failed to retrieve bootstrap data secret %q: %w", dataSecretName, err)
+		return "", "", fmt.Errorf("failed to retrieve bootstrap data secret %q: %w", dataSecretName, err)
 	}
 
 	value, ok := s.Data["value"]
 	if !ok {
-		return "", fmt.Errorf("secret %q is missing value key", dataSecretName)
+		return "", "", fmt.Errorf("secret %q is missing value key", dataSecretName)
 	}
 
-	return string(value), nil
+	format := string(s.Data["format"])
+	if format == "" {
+		format = incus.BootstrapFormatCloudConfig
+	}
+
+	return string(value), format, nil
+}
+
+// reconfigureLoadBalancer updates the cluster load balancer configuration, using the custom config template of the cluster (if any).
+func (r *LXCMachineReconciler) reconfigureLoadBalancer(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client) error {
+	opts, err := util.GetLoadBalancerOptions(ctx, r.Client, lxcCluster)
+	if err != nil {
+		return err
+	}
+	return lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster, opts...).Reconfigure(ctx)
 }
 
-func (r *LXCMachineReconciler) setLXCMachineAddresses(lxcMachine *infrav1.LXCMachine, addrs []string) {
-	lxcMachine.Status.Addresses = make([]clusterv1.MachineAddress, 0, 1+2*len(addrs))
+// setLXCMachineAddresses sets the addresses of the LXCMachine. InternalIP addresses are filtered and ordered based
+// on the address family preference of the cluster, and are used by the cloud provider node patch.
+func (r *LXCMachineReconciler) setLXCMachineAddresses(lxcMachine *infrav1.LXCMachine, addrs []string, addressFamily string) {
+	internalAddrs := incus.FilterAddressesForFamily(addrs, addressFamily)
+
+	lxcMachine.Status.Addresses = make([]clusterv1.MachineAddress, 0, 1+len(internalAddrs)+len(addrs))
 	lxcMachine.Status.Addresses = append(lxcMachine.Status.Addresses, clusterv1.MachineAddress{
 		Type:    clusterv1.MachineHostName,
 		Address: lxcMachine.GetInstanceName(),
 	})
+	for _, address := range internalAddrs {
+		lxcMachine.Status.Addresses = append(lxcMachine.Status.Addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineInternalIP,
+			Address: address,
+		})
+	}
 	for _, address := range addrs {
-		lxcMachine.Status.Addresses = append(lxcMachine.Status.Addresses,
-			clusterv1.MachineAddress{
-				Type:    clusterv1.MachineInternalIP,
-				Address: address,
-			},
-			clusterv1.MachineAddress{
-				Type:    clusterv1.MachineExternalIP,
-				Address: address,
-			},
-		)
+		lxcMachine.Status.Addresses = append(lxcMachine.Status.Addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineExternalIP,
+			Address: address,
+		})
+	}
+}
+
+// bootstrapTimeout returns the bootstrap timeout of the LXCMachine. If not set, the controller default is used.
+func (r *LXCMachineReconciler) bootstrapTimeout(lxcMachine *infrav1.LXCMachine) time.Duration {
+	if lxcMachine.Spec.BootstrapTimeout != nil {
+		return lxcMachine.Spec.BootstrapTimeout.Duration
+	}
+	return r.BootstrapTimeout
+}
+
+// bootstrapTimedOut checks whether the bootstrap timeout of the LXCMachine has expired, based on the creation
+// time of the instance. It is always false if the bootstrap timeout is disabled.
+func (r *LXCMachineReconciler) bootstrapTimedOut(lxcMachine *infrav1.LXCMachine, lxcClient *incus.Client) (bool, error) {
+	timeout := r.bootstrapTimeout(lxcMachine)
+	if timeout <= 0 {
+		return false, nil
+	}
+
+	instance, _, err := lxcClient.Client.GetInstance(lxcMachine.GetInstanceName())
+	if err != nil {
+		return false, fmt.Errorf("failed to GetInstance: %w", err)
 	}
+	return time.Since(instance.CreatedAt) > timeout, nil
 }
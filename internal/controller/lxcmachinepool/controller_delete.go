@@ -0,0 +1,53 @@
+package lxcmachinepool
+
+import (
+	"context"
+	"fmt"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+)
+
+func (r *LXCMachinePoolReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcMachinePool *infrav1.LXCMachinePool, lxcClient *incus.Client) error {
+	// Set the InstancesReadyCondition reporting delete is started, and issue a patch in order to make
+	// this visible to the users.
+	patchHelper, err := patch.NewHelper(lxcMachinePool, r.Client)
+	if err != nil {
+		return err
+	}
+	conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+	if err := patchLXCMachinePool(ctx, patchHelper, lxcMachinePool); err != nil {
+		return fmt.Errorf("failed to patch LXCMachinePool: %w", err)
+	}
+
+	// Delete all instances of the machine pool
+	instances, err := lxcClient.GetMachinePoolInstances(ctx, cluster, lxcMachinePool)
+	if err != nil {
+		return fmt.Errorf("failed to list machine pool instances: %w", err)
+	}
+	for _, instance := range instances {
+		log.FromContext(ctx).Info("Deleting instance", "instance", instance.Name)
+		if err := lxcClient.DeleteMachinePoolInstance(ctx, instance.Name); err != nil {
+			return fmt.Errorf("failed to delete instance %q: %w", instance.Name, err)
+		}
+	}
+
+	// Remove the instances from the load balancer configuration (unless the cluster is getting deleted)
+	if len(instances) > 0 && lxcCluster.HasWorkerLoadBalancerPorts() && cluster.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.FromContext(ctx).Info("Reconfigure load balancer after removing instances")
+		if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
+			return fmt.Errorf("failed to reconfigure load balancer after removing instances: %w", err)
+		}
+	}
+
+	// Machine pool instances are deleted so remove the finalizer.
+	controllerutil.RemoveFinalizer(lxcMachinePool, infrav1.MachinePoolFinalizer)
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package lxcmachinepool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lxc/incus/v6/shared/api"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudprovider"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+	lxcutil "github.com/neoaggelos/cluster-api-provider-lxc/internal/util"
+)
+
+func (r *LXCMachinePoolReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, machinePool *expv1.MachinePool, lxcMachinePool *infrav1.LXCMachinePool, lxcClient *incus.Client) (ctrl.Result, error) {
+	// Check if the infrastructure is ready, otherwise return and wait for the cluster object to be updated
+	if !cluster.Status.InfrastructureReady {
+		log.FromContext(ctx).Info("Waiting for LXCCluster Controller to create cluster infrastructure")
+		conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	// Make sure bootstrap data is available and populated.
+	dataSecretName := machinePool.Spec.Template.Spec.Bootstrap.DataSecretName
+	if dataSecretName == nil {
+		log.FromContext(ctx).Info("Waiting for the Bootstrap provider controller to set bootstrap data")
+		conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	replicas := 1
+	if machinePool.Spec.Replicas != nil {
+		replicas = int(*machinePool.Spec.Replicas)
+	}
+
+	instances, err := lxcClient.GetMachinePoolInstances(ctx, cluster, lxcMachinePool)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list machine pool instances: %w", err)
+	}
+
+	bootstrapData, bootstrapFormat, err := r.getBootstrapData(ctx, lxcMachinePool.Namespace, *dataSecretName)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to retrieve bootstrap data: %w", err)
+	}
+
+	// Create missing instances
+	if len(instances) < replicas {
+		for idx := len(instances); idx < replicas; idx++ {
+			name := newInstanceName(lxcMachinePool)
+
+			// Spread instances across the failure domains of the machine pool.
+			var failureDomain *string
+			if failureDomains := machinePool.Spec.FailureDomains; len(failureDomains) > 0 {
+				failureDomain = ptr.To(failureDomains[idx%len(failureDomains)])
+			}
+
+			ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name))
+			log.FromContext(ctx).Info("Creating instance")
+			conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.ScalingUpReason, clusterv1.ConditionSeverityInfo, "Scaling up to %d replicas (actual %d)", replicas, idx)
+
+			networkConfig, vendorData, err := lxcutil.RenderCloudInitConfig(ctx, r.Client, lxcMachinePool.Namespace, lxcMachinePool.Spec.Template.CloudInit, cloudinit.TemplateData{
+				InstanceName: name,
+				ClusterName:  cluster.Name,
+				Namespace:    cluster.Namespace,
+			})
+			if err != nil {
+				conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.CloudInitConfigInvalidReason, clusterv1.ConditionSeverityWarning, "Failed to render cloud-init configuration: %s", err.Error())
+				return ctrl.Result{}, fmt.Errorf("failed to render cloud-init configuration: %w", err)
+			}
+			opts := incus.CreateInstanceOptions{
+				Image:     lxcMachinePool.Spec.Template.Image,
+				Bootstrap: incus.BootstrapData{Format: bootstrapFormat, Data: bootstrapData, NetworkConfig: networkConfig, VendorData: vendorData},
+			}
+
+			if _, err := lxcClient.CreateMachinePoolInstance(ctx, name, machinePool, lxcMachinePool, cluster, lxcCluster, failureDomain, opts); err != nil {
+				if incus.IsTerminalError(err) {
+					log.FromContext(ctx).Error(err, "Fatal error while creating instance")
+					conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.InstanceProvisioningAbortedReason, clusterv1.ConditionSeverityError, "Failed to create instance: %s", err.Error())
+					return ctrl.Result{}, nil
+				}
+				if strings.HasSuffix(err.Error(), "context deadline exceeded") {
+					log.FromContext(ctx).Error(err, "Instance creation timed out, retrying in 10 seconds")
+					conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.CreatingInstanceReason, clusterv1.ConditionSeverityWarning, "Instance creation still in progress: %s", err.Error())
+					return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+				}
+				conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.InstanceProvisioningFailedReason, clusterv1.ConditionSeverityWarning, "Failed to create instance: %s", err.Error())
+				return ctrl.Result{}, fmt.Errorf("failed to create instance: %w", err)
+			}
+		}
+
+		if instances, err = lxcClient.GetMachinePoolInstances(ctx, cluster, lxcMachinePool); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to list machine pool instances: %w", err)
+		}
+
+		if lxcCluster.HasWorkerLoadBalancerPorts() {
+			log.FromContext(ctx).Info("Reconfigure load balancer after adding instances")
+			if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to update loadbalancer configuration: %w", err)
+			}
+		}
+	}
+
+	// Observe the state of the instances
+	previous := make(map[string]infrav1.LXCMachinePoolInstanceStatus, len(lxcMachinePool.Status.Instances))
+	for _, instance := range lxcMachinePool.Status.Instances {
+		previous[instance.InstanceName] = instance
+	}
+	var errs []error
+	statuses := make([]infrav1.LXCMachinePoolInstanceStatus, 0, len(instances))
+	for _, instance := range instances {
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", instance.Name))
+		status, err := r.reconcileInstance(ctx, cluster, lxcCluster, lxcMachinePool, lxcClient, instance, bootstrapFormat, previous[instance.Name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instance %q: %w", instance.Name, err))
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Delete excess instances
+	if excess := len(statuses) - replicas; excess > 0 {
+		createdAt := make(map[string]time.Time, len(instances))
+		for _, instance := range instances {
+			createdAt[instance.Name] = instance.CreatedAt
+		}
+
+		// prefer deleting instances that are not ready, then the most recently created ones
+		slices.SortStableFunc(statuses, func(a, b infrav1.LXCMachinePoolInstanceStatus) int {
+			if a.Ready != b.Ready {
+				if !a.Ready {
+					return -1
+				}
+				return 1
+			}
+			return createdAt[b.InstanceName].Compare(createdAt[a.InstanceName])
+		})
+
+		conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.ScalingDownReason, clusterv1.ConditionSeverityInfo, "Scaling down to %d replicas (actual %d)", replicas, len(statuses))
+		for _, status := range statuses[:excess] {
+			log.FromContext(ctx).Info("Deleting instance", "instance", status.InstanceName)
+			if err := lxcClient.DeleteMachinePoolInstance(ctx, status.InstanceName); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete instance %q: %w", status.InstanceName, err)
+			}
+		}
+		statuses = statuses[excess:]
+
+		if lxcCluster.HasWorkerLoadBalancerPorts() {
+			log.FromContext(ctx).Info("Reconfigure load balancer after removing instances")
+			if err := r.reconfigureLoadBalancer(ctx, cluster, lxcCluster, lxcClient); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to update loadbalancer configuration: %w", err)
+			}
+		}
+	}
+
+	slices.SortFunc(statuses, func(a, b infrav1.LXCMachinePoolInstanceStatus) int {
+		return strings.Compare(a.InstanceName, b.InstanceName)
+	})
+
+	var failed []string
+	providerIDList := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Ready {
+			providerIDList = append(providerIDList, status.ProviderID)
+		}
+		if status.BootstrapStatus == string(cloudinit.StatusError) {
+			failed = append(failed, status.InstanceName)
+		}
+	}
+
+	lxcMachinePool.Spec.ProviderIDList = providerIDList
+	lxcMachinePool.Status.Instances = statuses
+	lxcMachinePool.Status.Replicas = int32(len(statuses))
+	lxcMachinePool.Status.Ready = len(providerIDList) == replicas
+
+	switch {
+	case len(failed) > 0:
+		conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "Bootstrap failed on instances %v", failed)
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	case len(providerIDList) < replicas:
+		log.FromContext(ctx).Info("Waiting for bootstrap script to complete", "ready", len(providerIDList), "replicas", replicas)
+		conditions.MarkFalse(lxcMachinePool, infrav1.InstancesReadyCondition, infrav1.WaitingForInstancesBootstrapReason, clusterv1.ConditionSeverityInfo, "%d of %d instances are ready", len(providerIDList), replicas)
+		if len(errs) > 0 {
+			return ctrl.Result{}, kerrors.NewAggregate(errs)
+		}
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	default:
+		conditions.MarkTrue(lxcMachinePool, infrav1.InstancesReadyCondition)
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+}
+
+// reconcileInstance observes the state of an instance of the machine pool. Instances are considered ready once
+// bootstrap has completed successfully. Before an instance is reported as ready for the first time, the matching
+// node on the workload cluster is patched with the instance providerID (unless disabled on the LXCCluster).
+func (r *LXCMachinePoolReconciler) reconcileInstance(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcMachinePool *infrav1.LXCMachinePool, lxcClient *incus.Client, instance api.InstanceFull, bootstrapFormat string, previous infrav1.LXCMachinePoolInstanceStatus) (infrav1.LXCMachinePoolInstanceStatus, error) {
+	status := infrav1.LXCMachinePoolInstanceStatus{
+		InstanceName:    instance.Name,
+		ProviderID:      lxcMachinePool.GetInstanceProviderID(instance.Name),
+		BootstrapStatus: string(cloudinit.StatusUnknown),
+		Addresses:       instanceAddresses(instance.Name, lxcClient.ParseActiveMachineAddresses(instance.State), lxcCluster.Spec.AddressFamily),
+	}
+
+	if instance.StatusCode != api.Running {
+		log.FromContext(ctx).Info("Instance is not running", "status", instance.Status)
+
+		// the Ignition config of virtual machines can only be removed while the instance is stopped
+		status.BootstrapDataScrubbed = previous.BootstrapDataScrubbed
+		if instance.StatusCode == api.Stopped && previous.BootstrapStatus == string(cloudinit.StatusDone) && !status.BootstrapDataScrubbed {
+			scrubbed, err := lxcClient.ScrubBootstrapData(ctx, instance.Name)
+			if err != nil {
+				return status, fmt.Errorf("failed to remove bootstrap data from instance: %w", err)
+			}
+			status.BootstrapDataScrubbed = scrubbed
+		}
+
+		if lxcMachinePool.Spec.Template.AutoRestart && (instance.StatusCode == api.Stopped || instance.StatusCode == api.Frozen) {
+			log.FromContext(ctx).Info("Starting instance", "status", instance.Status)
+			if err := lxcClient.StartInstance(ctx, instanceAsLXCMachine(lxcMachinePool, status)); err != nil {
+				return status, fmt.Errorf("failed to start instance: %w", err)
+			}
+		}
+		return status, nil
+	}
+
+	cloudInitStatus, err := lxcClient.CheckBootstrapStatus(ctx, instance.Name, bootstrapFormat)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Could not retrieve bootstrap status")
+	}
+	status.BootstrapStatus = string(cloudInitStatus)
+	if cloudInitStatus != cloudinit.StatusDone {
+		return status, nil
+	}
+
+	status.BootstrapDataScrubbed = previous.BootstrapDataScrubbed
+	if !status.BootstrapDataScrubbed {
+		scrubbed, err := lxcClient.ScrubBootstrapData(ctx, instance.Name)
+		if err != nil {
+			return status, fmt.Errorf("failed to remove bootstrap data from instance: %w", err)
+		}
+		status.BootstrapDataScrubbed = scrubbed
+	}
+
+	if !previous.Ready {
+		if !lxcCluster.Spec.SkipCloudProviderNodePatch {
+			remoteClient, err := r.ClusterCache.GetClient(ctx, client.ObjectKeyFromObject(cluster))
+			if err != nil {
+				return status, fmt.Errorf("failed to generate workload cluster client: %w", err)
+			}
+
+			if err := cloudprovider.PatchNode(ctx, remoteClient, instanceAsLXCMachine(lxcMachinePool, status)); err != nil {
+				return status, fmt.Errorf("failed to apply cloud-provider node patch: %w", err)
+			}
+		} else {
+			log.FromContext(ctx).Info("Skip cloud provider node patch")
+		}
+		log.FromContext(ctx).Info("Bootstrap finished successfully")
+	}
+
+	status.Ready = true
+	return status, nil
+}
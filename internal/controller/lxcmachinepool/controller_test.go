@@ -0,0 +1,261 @@
+package lxcmachinepool_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/controller/lxcmachinepool"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+
+	. "github.com/onsi/gomega"
+)
+
+// setupMachinePool creates a namespace with an infrastructure credentials secret, an infrastructure ready Cluster and
+// LXCCluster, and a MachinePool with bootstrap data that owns a LXCMachinePool.
+func setupMachinePool(g *WithT, replicas int32) (*expv1.MachinePool, *infrav1.LXCMachinePool) {
+	ctx := context.TODO()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "lxcmachinepool-"}}
+	g.Expect(testClient.Create(ctx, ns)).To(Succeed())
+
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "lxc-secret", Namespace: ns.Name},
+		Data:       map[string][]byte{"server": []byte("https://fake:8443")},
+	})).To(Succeed())
+	g.Expect(testClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mp-0-bootstrap", Namespace: ns.Name},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	})).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: ns.Name},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{APIVersion: infrav1.GroupVersion.String(), Kind: "LXCCluster", Name: "c1", Namespace: ns.Name},
+		},
+	}
+	g.Expect(testClient.Create(ctx, cluster)).To(Succeed())
+	cluster.Status.InfrastructureReady = true
+	g.Expect(testClient.Status().Update(ctx, cluster)).To(Succeed())
+
+	g.Expect(testClient.Create(ctx, &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "c1",
+			Namespace: ns.Name,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: cluster.Name, UID: cluster.UID},
+			},
+		},
+		Spec: infrav1.LXCClusterSpec{
+			SecretRef:                  infrav1.SecretRef{Name: "lxc-secret"},
+			ControlPlaneEndpoint:       clusterv1.APIEndpoint{Host: "10.100.42.1", Port: 6443},
+			LoadBalancer:               infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{}},
+			SkipDefaultKubeadmProfile:  true,
+			SkipCloudProviderNodePatch: true,
+		},
+	})).To(Succeed())
+
+	machinePool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mp-0",
+			Namespace: ns.Name,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: cluster.Name,
+			Replicas:    ptr.To(replicas),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					ClusterName: cluster.Name,
+					Version:     ptr.To("v1.32.0"),
+					Bootstrap:   clusterv1.Bootstrap{DataSecretName: ptr.To("mp-0-bootstrap")},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: infrav1.GroupVersion.String(),
+						Kind:       "LXCMachinePool",
+						Name:       "mp-0",
+						Namespace:  ns.Name,
+					},
+				},
+			},
+		},
+	}
+	g.Expect(testClient.Create(ctx, machinePool)).To(Succeed())
+
+	lxcMachinePool := &infrav1.LXCMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mp-0",
+			Namespace: ns.Name,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: expv1.GroupVersion.String(), Kind: "MachinePool", Name: machinePool.Name, UID: machinePool.UID},
+			},
+		},
+		Spec: infrav1.LXCMachinePoolSpec{
+			Template: infrav1.LXCMachineSpec{
+				Image: infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://images.example.com", Protocol: "simplestreams"},
+			},
+		},
+	}
+	g.Expect(testClient.Create(ctx, lxcMachinePool)).To(Succeed())
+
+	return machinePool, lxcMachinePool
+}
+
+// reconcileUntil reconciles the LXCMachinePool until the check succeeds.
+func reconcileUntil(g *WithT, r *lxcmachinepool.LXCMachinePoolReconciler, lxcMachinePool *infrav1.LXCMachinePool, check func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool)) {
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachinePool)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(lxcMachinePool), lxcMachinePool)).To(Succeed())
+		check(g, lxcMachinePool)
+	}).Should(Succeed())
+}
+
+func TestLXCMachinePoolReconciler(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	r := &lxcmachinepool.LXCMachinePoolReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	machinePool, lxcMachinePool := setupMachinePool(g, 2)
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+			g.Expect(lxcMachinePool.Status.Replicas).To(BeEquivalentTo(2))
+		})
+
+		// instances are not ready until they are bootstrapped
+		g.Expect(server.InstanceNames()).To(HaveLen(2))
+		g.Expect(lxcMachinePool.Status.Ready).To(BeFalse())
+		g.Expect(lxcMachinePool.Status.Instances).To(HaveEach(HaveField("BootstrapStatus", "Running")))
+		g.Expect(lxcMachinePool.Spec.ProviderIDList).To(BeEmpty())
+		g.Expect(conditions.GetReason(lxcMachinePool, infrav1.InstancesReadyCondition)).To(Equal(infrav1.WaitingForInstancesBootstrapReason))
+	})
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, name := range server.InstanceNames() {
+			g.Expect(server.FinishCloudInit(name)).To(Succeed())
+		}
+
+		reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+			g.Expect(conditions.IsTrue(lxcMachinePool, infrav1.InstancesReadyCondition)).To(BeTrue())
+		})
+
+		names := server.InstanceNames()
+		g.Expect(lxcMachinePool.Status.Ready).To(BeTrue())
+		g.Expect(lxcMachinePool.Spec.ProviderIDList).To(ConsistOf("lxc:///"+names[0], "lxc:///"+names[1]))
+		g.Expect(lxcMachinePool.Status.Instances).To(HaveEach(HaveField("Ready", true)))
+		g.Expect(lxcMachinePool.Status.Instances).To(HaveEach(HaveField("BootstrapDataScrubbed", true)))
+		for _, name := range names {
+			instance, _, err := server.GetInstance(name)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(instance.Config).ToNot(HaveKey("cloud-init.user-data"))
+		}
+	})
+
+	t.Run("ScaleUp", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machinePool.Spec.Replicas = ptr.To[int32](3)
+		g.Expect(testClient.Update(ctx, machinePool)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+			g.Expect(lxcMachinePool.Status.Replicas).To(BeEquivalentTo(3))
+		})
+		g.Expect(server.InstanceNames()).To(HaveLen(3))
+		g.Expect(lxcMachinePool.Spec.ProviderIDList).To(HaveLen(2))
+	})
+
+	t.Run("ScaleDown", func(t *testing.T) {
+		g := NewWithT(t)
+
+		notReady := lxcMachinePool.Status.Instances[0].InstanceName
+		for _, instance := range lxcMachinePool.Status.Instances {
+			if !instance.Ready {
+				notReady = instance.InstanceName
+			}
+		}
+
+		machinePool.Spec.Replicas = ptr.To[int32](2)
+		g.Expect(testClient.Update(ctx, machinePool)).To(Succeed())
+
+		reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+			g.Expect(lxcMachinePool.Status.Replicas).To(BeEquivalentTo(2))
+		})
+
+		// the instance that is not ready is deleted first
+		g.Expect(server.InstanceNames()).To(HaveLen(2))
+		g.Expect(server.InstanceNames()).ToNot(ContainElement(notReady))
+		g.Expect(lxcMachinePool.Spec.ProviderIDList).To(HaveLen(2))
+		g.Expect(conditions.IsTrue(lxcMachinePool, infrav1.InstancesReadyCondition)).To(BeTrue())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(testClient.Delete(ctx, lxcMachinePool)).To(Succeed())
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lxcMachinePool)})
+			g.Expect(err).ToNot(HaveOccurred())
+			err = testClient.Get(ctx, client.ObjectKeyFromObject(lxcMachinePool), &infrav1.LXCMachinePool{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}).Should(Succeed())
+
+		g.Expect(server.InstanceNames()).To(BeEmpty())
+	})
+}
+
+func TestLXCMachinePoolReconciler_BootstrapFailed(t *testing.T) {
+	if testClient == nil {
+		t.Skip("envtest is not available")
+	}
+	g := NewWithT(t)
+
+	server := fake.NewServer()
+	r := &lxcmachinepool.LXCMachinePoolReconciler{
+		Client:        testClient,
+		CachingClient: testClient,
+		NewIncusClient: func(context.Context, incus.Options) (*incus.Client, error) {
+			return &incus.Client{Client: server}, nil
+		},
+	}
+
+	_, lxcMachinePool := setupMachinePool(g, 1)
+
+	reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+		g.Expect(lxcMachinePool.Status.Replicas).To(BeEquivalentTo(1))
+	})
+
+	g.Expect(server.FinishCloudInit(lxcMachinePool.Status.Instances[0].InstanceName, "failed to run kubeadm join")).To(Succeed())
+	reconcileUntil(g, r, lxcMachinePool, func(g Gomega, lxcMachinePool *infrav1.LXCMachinePool) {
+		g.Expect(conditions.GetReason(lxcMachinePool, infrav1.InstancesReadyCondition)).To(Equal(infrav1.BootstrapFailedReason))
+	})
+	g.Expect(lxcMachinePool.Status.Instances).To(ConsistOf(HaveField("BootstrapStatus", "Error")))
+	g.Expect(lxcMachinePool.Spec.ProviderIDList).To(BeEmpty())
+	g.Expect(lxcMachinePool.Status.Ready).To(BeFalse())
+}
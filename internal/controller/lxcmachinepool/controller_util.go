@@ -0,0 +1,102 @@
+package lxcmachinepool
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	lxcutil "github.com/neoaggelos/cluster-api-provider-lxc/internal/util"
+)
+
+func patchLXCMachinePool(ctx context.Context, patchHelper *patch.Helper, lxcMachinePool *infrav1.LXCMachinePool) error {
+	// Always update the readyCondition by summarizing the state of other conditions.
+	conditions.SetSummary(lxcMachinePool,
+		conditions.WithConditions(infrav1.InstancesReadyCondition),
+	)
+
+	// Patch the object, ignoring conflicts on the conditions owned by this controller.
+	return patchHelper.Patch(
+		ctx,
+		lxcMachinePool,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{infrav1.InstancesReadyCondition, clusterv1.ReadyCondition}},
+	)
+}
+
+// getBootstrapData returns the bootstrap data and its format from the bootstrap data secret. The format defaults to cloud-config.
+func (r *LXCMachinePoolReconciler) getBootstrapData(ctx context.Context, namespace string, dataSecretName string) (string, string, error) {
+	s := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: namespace, Name: dataSecretName}
+	if err := r.Client.Get(ctx, key, s); err != nil {
+		return "", "", fmt.Errorf("failed to retrieve bootstrap data secret %q: %w", dataSecretName, err)
+	}
+
+	value, ok := s.Data["value"]
+	if !ok {
+		return "", "", fmt.Errorf("secret %q is missing value key", dataSecretName)
+	}
+
+	format := string(s.Data["format"])
+	if format == "" {
+		format = incus.BootstrapFormatCloudConfig
+	}
+
+	return string(value), format, nil
+}
+
+// reconfigureLoadBalancer updates the cluster load balancer configuration, using the custom config template of the cluster (if any).
+func (r *LXCMachinePoolReconciler) reconfigureLoadBalancer(ctx context.Context, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, lxcClient *incus.Client) error {
+	opts, err := lxcutil.GetLoadBalancerOptions(ctx, r.Client, lxcCluster)
+	if err != nil {
+		return err
+	}
+	return lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster, opts...).Reconfigure(ctx)
+}
+
+// newInstanceName generates a random name for a new instance of the machine pool.
+func newInstanceName(lxcMachinePool *infrav1.LXCMachinePool) string {
+	return fmt.Sprintf("%s-%s", lxcMachinePool.Name, util.RandomString(5))
+}
+
+// instanceAddresses returns the machine addresses of an instance of the machine pool. InternalIP addresses are
+// filtered and ordered based on the address family preference of the cluster.
+func instanceAddresses(instanceName string, addrs []string, addressFamily string) []clusterv1.MachineAddress {
+	internalAddrs := incus.FilterAddressesForFamily(addrs, addressFamily)
+
+	addresses := make([]clusterv1.MachineAddress, 0, 1+len(internalAddrs)+len(addrs))
+	addresses = append(addresses, clusterv1.MachineAddress{
+		Type:    clusterv1.MachineHostName,
+		Address: instanceName,
+	})
+	for _, address := range internalAddrs {
+		addresses = append(addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineInternalIP,
+			Address: address,
+		})
+	}
+	for _, address := range addrs {
+		addresses = append(addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineExternalIP,
+			Address: address,
+		})
+	}
+	return addresses
+}
+
+// instanceAsLXCMachine returns an LXCMachine object that represents an instance of the machine pool.
+// It is used to share logic that expects an LXCMachine, e.g. patching the workload cluster node.
+func instanceAsLXCMachine(lxcMachinePool *infrav1.LXCMachinePool, instance infrav1.LXCMachinePoolInstanceStatus) *infrav1.LXCMachine {
+	return &infrav1.LXCMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: instance.InstanceName, Namespace: lxcMachinePool.Namespace},
+		Spec:       *lxcMachinePool.Spec.Template.DeepCopy(),
+		Status:     infrav1.LXCMachineStatus{Addresses: instance.Addresses},
+	}
+}
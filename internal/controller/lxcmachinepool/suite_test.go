@@ -0,0 +1,68 @@
+package lxcmachinepool_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+var (
+	testScheme = runtime.NewScheme()
+
+	// testClient is a client for the envtest API server. It is nil if envtest is not available.
+	testClient client.Client
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(testScheme)
+	_ = clusterv1.AddToScheme(testScheme)
+	_ = expv1.AddToScheme(testScheme)
+	_ = infrav1.AddToScheme(testScheme)
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+// runTests starts an envtest API server with the provider and Cluster API CRDs, then runs the tests.
+// If KUBEBUILDER_ASSETS is not set, tests that require envtest are skipped. See "make test".
+func runTests(m *testing.M) int {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		fmt.Println("KUBEBUILDER_ASSETS is not set, envtest suites will be skipped")
+		return m.Run()
+	}
+
+	testEnv := &envtest.Environment{
+		Scheme:                testScheme,
+		ErrorIfCRDPathMissing: true,
+		CRDDirectoryPaths: append(
+			[]string{filepath.Join("..", "..", "..", "config", "crd", "bases")},
+			filepath.SplitList(os.Getenv("CLUSTERAPI_CRD_PATHS"))...,
+		),
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		panic(fmt.Sprintf("failed to start envtest: %v", err))
+	}
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			panic(fmt.Sprintf("failed to stop envtest: %v", err))
+		}
+	}()
+
+	if testClient, err = client.New(cfg, client.Options{Scheme: testScheme}); err != nil {
+		panic(fmt.Sprintf("failed to create client: %v", err))
+	}
+
+	return m.Run()
+}
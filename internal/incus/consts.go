@@ -15,6 +15,9 @@ const (
 	// instanceCreateTimeout is the timeout for creating and starting an instance.
 	instanceCreateTimeout = 180 * time.Second
 
+	// instanceStartTimeout is the timeout for starting a stopped (or unfreezing a frozen) instance.
+	instanceStartTimeout = 60 * time.Second
+
 	// instanceDeleteTimeout is the timeout for stopping and deleting an instance.
 	instanceDeleteTimeout = 30 * time.Second
 
@@ -27,9 +30,45 @@ const (
 	// configInstanceRoleKey is the user config key that tracks the instance role.
 	configInstanceRoleKey = "user.cluster-role"
 
+	// configMachinePoolKey is the user config key that tracks the LXCMachinePool of machine pool instances.
+	configMachinePoolKey = "user.cluster-machine-pool"
+
+	// configImageFingerprintKey is the user config key that tracks the fingerprint of the image of the instance.
+	configImageFingerprintKey = "user.cluster-image-fingerprint"
+
+	// configBaseImageKey is the config key where the server records the fingerprint of the image of the instance.
+	configBaseImageKey = "volatile.base_image"
+
+	// configLoadBalancerWeightKey is the user config key that tracks the load balancer backend weight of control plane instances.
+	configLoadBalancerWeightKey = "user.cluster-lb-weight"
+
 	// configCloudInitKey is the config key that seeds cloud-init configuration into the instance.
 	configCloudInitKey = "cloud-init.user-data"
 
+	// configCloudInitNetworkConfigKey is the config key that seeds the cloud-init network configuration into the instance.
+	configCloudInitNetworkConfigKey = "cloud-init.network-config"
+
+	// configCloudInitVendorDataKey is the config key that seeds the cloud-init vendor-data into the instance.
+	configCloudInitVendorDataKey = "cloud-init.vendor-data"
+
+	// configCloudInitInstanceIDKey is the config key with the cloud-init instance-id of the instance.
+	configCloudInitInstanceIDKey = "volatile.cloud-init.instance-id"
+
+	// configRawQEMUKey is the config key with extra arguments for the QEMU process of virtual machines.
+	configRawQEMUKey = "raw.qemu"
+
+	// cloudInitOutputLogPath is the path of the cloud-init output log on instances.
+	cloudInitOutputLogPath = "/var/log/cloud-init-output.log"
+
+	// cloudInitOutputTailLines is the maximum number of cloud-init output log lines reported on bootstrap failures.
+	cloudInitOutputTailLines = 20
+
+	// cloudInitOutputTailBytes is the maximum size of the cloud-init output log tail reported on bootstrap failures.
+	cloudInitOutputTailBytes = 2048
+
+	// keepalivedConfigPath is the path of the keepalived configuration on keepalived load balancer instances.
+	keepalivedConfigPath = "/etc/keepalived/keepalived.conf"
+
 	// defaultSimplestreamsServer is the default simplestreams server for fetching images.
 	defaultSimplestreamsServer = "https://d14dnvi2l3tc5t.cloudfront.net"
 )
@@ -0,0 +1,55 @@
+package fake
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+)
+
+// operation is an incus.Operation that has already completed successfully, or is running in the background (e.g.
+// image downloads). Waiting for operations returns immediately.
+type operation struct {
+	op api.Operation
+}
+
+func newOperation(metadata map[string]any) *operation {
+	return &operation{op: api.Operation{
+		Class:      api.OperationClassTask,
+		Status:     api.Success.String(),
+		StatusCode: api.Success,
+		Metadata:   metadata,
+	}}
+}
+
+// AddHandler implements incus.Operation. Since the operation has already completed, the handler is called immediately.
+func (o *operation) AddHandler(function func(api.Operation)) (*incus.EventTarget, error) {
+	function(o.op)
+	return &incus.EventTarget{}, nil
+}
+
+// Cancel implements incus.Operation.
+func (o *operation) Cancel() error { return nil }
+
+// Get implements incus.Operation.
+func (o *operation) Get() api.Operation { return o.op }
+
+// GetWebsocket implements incus.Operation.
+func (o *operation) GetWebsocket(secret string) (*websocket.Conn, error) {
+	return nil, api.StatusErrorf(400, "websockets are not supported")
+}
+
+// RemoveHandler implements incus.Operation.
+func (o *operation) RemoveHandler(target *incus.EventTarget) error { return nil }
+
+// Refresh implements incus.Operation.
+func (o *operation) Refresh() error { return nil }
+
+// Wait implements incus.Operation.
+func (o *operation) Wait() error { return nil }
+
+// WaitContext implements incus.Operation.
+func (o *operation) WaitContext(ctx context.Context) error { return nil }
+
+var _ incus.Operation = &operation{}
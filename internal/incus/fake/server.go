@@ -0,0 +1,1073 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+)
+
+// Server is an in-memory implementation of the subset of incus.InstanceServer that is used by the provider.
+// Calling any other method of incus.InstanceServer will panic.
+//
+// Instances are assigned an IPv4 address from 10.0.0.0/16 when created, which is reported while the instance is running.
+// Commands executed on instances succeed without output, unless ExecHandler is set.
+// Instances with cloud-init user data report a running cloud-init status after they are started, until FinishCloudInit is called.
+// Images are copied from remote servers in the background, until FinishImageDownloads is called.
+// Like the server, a new cloud-init instance-id is generated when the cloud-init configuration of an instance changes,
+// and only keys that support live updates can be changed on running virtual machines.
+type Server struct {
+	incus.InstanceServer
+
+	// ExecHandler is called for commands executed on instances. It returns the exit code of the command.
+	ExecHandler func(instanceName string, command []string, stdout io.Writer, stderr io.Writer) int
+
+	mu sync.Mutex
+
+	server         api.Server
+	clusterMembers []api.ClusterMember
+	clusterGroups  []api.ClusterGroup
+
+	instances     map[string]*instance
+	profiles      map[string]api.Profile
+	networks      map[string]api.Network
+	loadBalancers map[string]map[string]api.NetworkLoadBalancer
+	storagePools  map[string]map[string]api.StorageVolume
+	images        map[string]api.Image
+	remoteImages  map[string]map[string]string
+	operations    map[string]*api.Operation
+	downloads     map[string]imageDownload
+
+	nextAddress    int
+	nextOperation  int
+	nextInstanceID int
+
+	forbidPrivileged bool
+}
+
+const (
+	// cloudInitStatusPath is the path of the cloud-init status file in instances.
+	cloudInitStatusPath = "/var/lib/cloud/data/status.json"
+
+	// configCloudInitInstanceIDKey is the config key with the cloud-init instance-id of instances.
+	configCloudInitInstanceIDKey = "volatile.cloud-init.instance-id"
+)
+
+// cloudInitConfigKeys are the config keys that generate a new cloud-init instance-id when changed.
+var cloudInitConfigKeys = []string{"cloud-init.user-data", "cloud-init.vendor-data", "cloud-init.network-config", "user.user-data", "user.vendor-data", "user.network-config"}
+
+// imageDownload is a pending image download, by the cluster member target (if any).
+type imageDownload struct {
+	image  api.Image
+	target string
+}
+
+type instance struct {
+	api.Instance
+
+	address string
+	files   map[string][]byte
+}
+
+// Option configures a fake Server.
+type Option func(*Server)
+
+// WithServerName sets the server implementation reported by GetServer ("incus" or "lxd"). Defaults to "incus".
+func WithServerName(name string) Option {
+	return func(s *Server) {
+		s.server.Environment.Server = name
+	}
+}
+
+// WithAPIExtensions sets the list of API extensions reported by GetServer.
+func WithAPIExtensions(extensions ...string) Option {
+	return func(s *Server) {
+		s.server.APIExtensions = extensions
+	}
+}
+
+// WithClusterMembers makes the server clustered, with the specified cluster members.
+func WithClusterMembers(members ...api.ClusterMember) Option {
+	return func(s *Server) {
+		s.server.Environment.ServerClustered = true
+		s.clusterMembers = members
+	}
+}
+
+// WithClusterGroups sets the cluster groups of the server.
+func WithClusterGroups(groups ...api.ClusterGroup) Option {
+	return func(s *Server) {
+		s.clusterGroups = groups
+	}
+}
+
+// WithNetworks adds networks to the server.
+func WithNetworks(networks ...api.Network) Option {
+	return func(s *Server) {
+		for _, network := range networks {
+			s.networks[network.Name] = network
+		}
+	}
+}
+
+// WithStoragePools adds storage pools to the server. Custom storage volumes can only be created in existing storage pools.
+func WithStoragePools(names ...string) Option {
+	return func(s *Server) {
+		for _, name := range names {
+			s.storagePools[name] = map[string]api.StorageVolume{}
+		}
+	}
+}
+
+// WithImages adds images to the server. Images can be looked up by fingerprint, or by the name of their aliases.
+func WithImages(images ...api.Image) Option {
+	return func(s *Server) {
+		for _, image := range images {
+			s.images[image.Fingerprint] = image
+		}
+	}
+}
+
+// WithRemoteImage adds an image with the specified alias and fingerprint to a remote image server. Images can only be
+// copied into the server from remote image servers with CreateImage.
+func WithRemoteImage(server string, alias string, fingerprint string) Option {
+	return func(s *Server) {
+		if s.remoteImages[server] == nil {
+			s.remoteImages[server] = map[string]string{}
+		}
+		s.remoteImages[server][alias] = fingerprint
+	}
+}
+
+// WithPrivilegedContainersForbidden simulates a restricted project, where profiles with privileged containers are rejected.
+func WithPrivilegedContainersForbidden() Option {
+	return func(s *Server) {
+		s.forbidPrivileged = true
+	}
+}
+
+// NewServer returns a new fake Server with empty state.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		server: api.Server{
+			ServerUntrusted: api.ServerUntrusted{
+				APIExtensions: []string{"instance_oci", "network_load_balancer", "network_load_balancer_health_check", "clustering", "clustering_groups", "network_allocations"},
+			},
+			Environment: api.ServerEnvironment{
+				Server:     "incus",
+				ServerName: "none",
+			},
+		},
+		instances:     map[string]*instance{},
+		profiles:      map[string]api.Profile{},
+		networks:      map[string]api.Network{},
+		loadBalancers: map[string]map[string]api.NetworkLoadBalancer{},
+		storagePools:  map[string]map[string]api.StorageVolume{},
+		images:        map[string]api.Image{},
+		remoteImages:  map[string]map[string]string{},
+		operations:    map[string]*api.Operation{},
+		downloads:     map[string]imageDownload{},
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// InstanceNames returns the sorted names of all instances.
+func (s *Server) InstanceNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.instances))
+	for name := range s.instances {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// GetServer implements incus.InstanceServer.
+func (s *Server) GetServer() (*api.Server, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	server := s.server
+	return &server, "", nil
+}
+
+// UseProject implements incus.InstanceServer. Projects are ignored.
+func (s *Server) UseProject(name string) incus.InstanceServer {
+	return s
+}
+
+// UseTarget implements incus.InstanceServer. The target is recorded as the location of created instances, and of
+// image download operations.
+func (s *Server) UseTarget(name string) incus.InstanceServer {
+	return &targetServer{Server: s, target: name}
+}
+
+// GetClusterMembers implements incus.InstanceServer.
+func (s *Server) GetClusterMembers() ([]api.ClusterMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.server.Environment.ServerClustered {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Server isn't part of a cluster")
+	}
+	return slices.Clone(s.clusterMembers), nil
+}
+
+// GetClusterGroups implements incus.InstanceServer.
+func (s *Server) GetClusterGroups() ([]api.ClusterGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.server.Environment.ServerClustered {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Server isn't part of a cluster")
+	}
+	return slices.Clone(s.clusterGroups), nil
+}
+
+// GetOperations implements incus.InstanceServer. Instance operations complete immediately, so there are never any pending instance operations.
+func (s *Server) GetOperations() ([]api.Operation, error) {
+	return nil, nil
+}
+
+// CreateInstance implements incus.InstanceServer.
+func (s *Server) CreateInstance(req api.InstancesPost) (incus.Operation, error) {
+	return s.createInstance(req, "")
+}
+
+func (s *Server) createInstance(req api.InstancesPost, target string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.instances[req.Name]; ok {
+		return nil, api.StatusErrorf(http.StatusConflict, "Instance %q already exists", req.Name)
+	}
+	for _, profile := range req.Profiles {
+		if _, ok := s.profiles[profile]; !ok && profile != "default" {
+			return nil, api.StatusErrorf(http.StatusNotFound, "Profile not found")
+		}
+	}
+
+	instanceType := req.Type
+	if instanceType == "" {
+		instanceType = api.InstanceTypeContainer
+	}
+	location := target
+	if location == "" {
+		location = s.server.Environment.ServerName
+	}
+
+	// Instances record the fingerprint of their image, like the server does. Image aliases are resolved from the
+	// remote image server (see WithRemoteImage), or from the local images (see WithImages).
+	var baseImage string
+	if fingerprint := s.resolveImageSource(req.Source, string(instanceType)); fingerprint != "" {
+		baseImage = fingerprint
+	}
+
+	req.Config = maps.Clone(req.Config)
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+	s.nextInstanceID++
+	req.Config[configCloudInitInstanceIDKey] = fmt.Sprintf("instance-id-%d", s.nextInstanceID)
+	if baseImage != "" {
+		req.Config["volatile.base_image"] = baseImage
+	}
+
+	s.nextAddress++
+	s.instances[req.Name] = &instance{
+		Instance: api.Instance{
+			Name:        req.Name,
+			Type:        string(instanceType),
+			Status:      "Stopped",
+			StatusCode:  api.Stopped,
+			Location:    location,
+			CreatedAt:   time.Now(),
+			InstancePut: req.InstancePut,
+		},
+		address: fmt.Sprintf("10.0.%d.%d", s.nextAddress/250, s.nextAddress%250+2),
+		files:   map[string][]byte{},
+	}
+	return newOperation(nil), nil
+}
+
+// resolveImageSource returns the fingerprint of the image of an instance source. An empty fingerprint is returned if
+// the image is not known to the fake server.
+func (s *Server) resolveImageSource(source api.InstanceSource, imageType string) string {
+	switch {
+	case source.Fingerprint != "":
+		return source.Fingerprint
+	case source.Server != "":
+		return s.remoteImages[source.Server][source.Alias]
+	}
+	for _, image := range s.images {
+		if image.Type != imageType {
+			continue
+		}
+		for _, alias := range image.Aliases {
+			if alias.Name == source.Alias {
+				return image.Fingerprint
+			}
+		}
+	}
+	return ""
+}
+
+// GetInstance implements incus.InstanceServer.
+func (s *Server) GetInstance(name string) (*api.Instance, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+	result := inst.Instance
+	result.Config = maps.Clone(inst.Config)
+	result.ExpandedConfig = s.expandedConfig(inst)
+	return &result, "", nil
+}
+
+// expandedConfig returns the instance configuration, including the configuration of its profiles.
+func (s *Server) expandedConfig(inst *instance) map[string]string {
+	config := map[string]string{}
+	for _, name := range inst.Profiles {
+		maps.Copy(config, s.profiles[name].Config)
+	}
+	maps.Copy(config, inst.Config)
+	return config
+}
+
+// GetInstancesFull implements incus.InstanceServer.
+func (s *Server) GetInstancesFull(instanceType api.InstanceType) ([]api.InstanceFull, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instances := make([]api.InstanceFull, 0, len(s.instances))
+	for _, inst := range s.instances {
+		if instanceType != api.InstanceTypeAny && inst.Type != string(instanceType) {
+			continue
+		}
+		result := api.InstanceFull{Instance: inst.Instance, State: inst.state()}
+		result.Config = maps.Clone(inst.Config)
+		result.ExpandedConfig = s.expandedConfig(inst)
+		instances = append(instances, result)
+	}
+	slices.SortFunc(instances, func(a, b api.InstanceFull) int { return strings.Compare(a.Name, b.Name) })
+	return instances, nil
+}
+
+// UpdateInstance implements incus.InstanceServer.
+func (s *Server) UpdateInstance(name string, req api.InstancePut, etag string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+
+	var changed []string
+	for key := range req.Config {
+		if req.Config[key] != inst.Config[key] {
+			changed = append(changed, key)
+		}
+	}
+	for key := range inst.Config {
+		if _, ok := req.Config[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	if inst.Type == string(api.InstanceTypeVM) && inst.StatusCode != api.Stopped {
+		for _, key := range changed {
+			if !isLiveUpdatableKey(key) {
+				return nil, api.StatusErrorf(http.StatusBadRequest, "Key %q cannot be updated when VM is running", key)
+			}
+		}
+	}
+
+	inst.InstancePut = req
+	inst.Config = maps.Clone(req.Config)
+	for _, key := range changed {
+		if slices.Contains(cloudInitConfigKeys, key) {
+			s.nextInstanceID++
+			inst.Config[configCloudInitInstanceIDKey] = fmt.Sprintf("instance-id-%d", s.nextInstanceID)
+			break
+		}
+	}
+	return newOperation(nil), nil
+}
+
+// isLiveUpdatableKey returns true for config keys that can be changed on running virtual machines.
+func isLiveUpdatableKey(key string) bool {
+	for _, prefix := range []string{"boot.", "cloud-init.", "environment.", "image.", "snapshots.", "user.", "volatile."} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return slices.Contains([]string{"cluster.evacuate", "limits.memory", "security.protection.delete"}, key)
+}
+
+// DeleteInstance implements incus.InstanceServer.
+func (s *Server) DeleteInstance(name string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+	if inst.StatusCode != api.Stopped {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Instance is running")
+	}
+	delete(s.instances, name)
+	return newOperation(nil), nil
+}
+
+// GetInstanceState implements incus.InstanceServer.
+func (s *Server) GetInstanceState(name string) (*api.InstanceState, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+	return inst.state(), "", nil
+}
+
+// UpdateInstanceState implements incus.InstanceServer.
+func (s *Server) UpdateInstanceState(name string, req api.InstanceStatePut, etag string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+
+	switch req.Action {
+	case "start", "restart", "unfreeze":
+		inst.StatusCode = api.Running
+
+		// simulate cloud-init running on first boot
+		if _, ok := inst.files[cloudInitStatusPath]; !ok && inst.Config["cloud-init.user-data"] != "" {
+			inst.files[cloudInitStatusPath] = []byte(`{"v1":{"stage":"modules-final"}}`)
+		}
+	case "stop":
+		inst.StatusCode = api.Stopped
+	case "freeze":
+		inst.StatusCode = api.Frozen
+	default:
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Unknown action %q", req.Action)
+	}
+	inst.Status = inst.StatusCode.String()
+	return newOperation(nil), nil
+}
+
+// SetInstanceStatus forcefully changes the status of an instance, e.g. to simulate an instance crash.
+func (s *Server) SetInstanceStatus(name string, status api.StatusCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+	inst.StatusCode = status
+	inst.Status = status.String()
+	return nil
+}
+
+// SetInstanceCreatedAt changes the creation time of an instance, e.g. to simulate an instance that was created long ago.
+func (s *Server) SetInstanceCreatedAt(name string, createdAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+	inst.CreatedAt = createdAt
+	return nil
+}
+
+// FinishCloudInit marks cloud-init as finished on the instance. If any errors are specified, cloud-init is marked as failed.
+func (s *Server) FinishCloudInit(name string, errs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[name]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"v1": map[string]any{
+			"stage":         nil,
+			"modules-final": map[string]any{"errors": append([]string{}, errs...)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cloud-init status: %w", err)
+	}
+	inst.files[cloudInitStatusPath] = b
+	return nil
+}
+
+// GetInstanceFile implements incus.InstanceServer.
+func (s *Server) GetInstanceFile(instanceName string, filePath string) (io.ReadCloser, *incus.InstanceFileResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[instanceName]
+	if !ok {
+		return nil, nil, api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+
+	if b, ok := inst.files[filePath]; ok {
+		return io.NopCloser(bytes.NewReader(b)), &incus.InstanceFileResponse{Type: "file", Mode: 0644}, nil
+	}
+
+	// processes are not simulated, report a single process for running instances
+	if filePath == "/proc" && inst.StatusCode == api.Running {
+		return nil, &incus.InstanceFileResponse{Type: "directory", Mode: 0555, Entries: []string{"1"}}, nil
+	}
+
+	// list directory entries
+	var entries []string
+	for name := range inst.files {
+		if rel, ok := strings.CutPrefix(name, strings.TrimSuffix(filePath, "/")+"/"); ok {
+			entry, _, _ := strings.Cut(rel, "/")
+			if !slices.Contains(entries, entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil, api.StatusErrorf(http.StatusNotFound, "Not Found")
+	}
+	slices.Sort(entries)
+	return nil, &incus.InstanceFileResponse{Type: "directory", Mode: 0755, Entries: entries}, nil
+}
+
+// CreateInstanceFile implements incus.InstanceServer.
+func (s *Server) CreateInstanceFile(instanceName string, filePath string, args incus.InstanceFileArgs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst, ok := s.instances[instanceName]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	}
+
+	var b []byte
+	if args.Content != nil {
+		var err error
+		if b, err = io.ReadAll(args.Content); err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+	inst.files[path.Clean(filePath)] = b
+	return nil
+}
+
+// ExecInstance implements incus.InstanceServer.
+func (s *Server) ExecInstance(instanceName string, req api.InstanceExecPost, args *incus.InstanceExecArgs) (incus.Operation, error) {
+	s.mu.Lock()
+	inst, ok := s.instances[instanceName]
+	running := ok && inst.StatusCode == api.Running
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, api.StatusErrorf(http.StatusNotFound, "Instance not found")
+	case !running:
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Instance is not running")
+	}
+
+	stdout, stderr := io.Discard, io.Discard
+	if args != nil {
+		if args.Stdout != nil {
+			stdout = args.Stdout
+		}
+		if args.Stderr != nil {
+			stderr = args.Stderr
+		}
+	}
+
+	var rc int
+	if s.ExecHandler != nil {
+		rc = s.ExecHandler(instanceName, req.Command, stdout, stderr)
+	}
+	if args != nil && args.DataDone != nil {
+		close(args.DataDone)
+	}
+
+	return newOperation(map[string]any{"return": float64(rc)}), nil
+}
+
+// CreateProfile implements incus.InstanceServer.
+func (s *Server) CreateProfile(req api.ProfilesPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.profiles[req.Name]; ok {
+		return api.StatusErrorf(http.StatusConflict, "Error inserting %q into database: The profile already exists", req.Name)
+	}
+	if s.forbidPrivileged && req.Config["security.privileged"] == "true" {
+		return api.StatusErrorf(http.StatusForbidden, "Invalid value \"true\" for config \"security.privileged\" on %q: Privileged containers are forbidden", req.Name)
+	}
+	s.profiles[req.Name] = api.Profile{Name: req.Name, ProfilePut: req.ProfilePut}
+	return nil
+}
+
+// GetProfile implements incus.InstanceServer.
+func (s *Server) GetProfile(name string) (*api.Profile, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, ok := s.profiles[name]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Profile not found")
+	}
+	return &profile, "", nil
+}
+
+// UpdateProfile implements incus.InstanceServer.
+func (s *Server) UpdateProfile(name string, req api.ProfilePut, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, ok := s.profiles[name]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Profile not found")
+	}
+	if s.forbidPrivileged && req.Config["security.privileged"] == "true" {
+		return api.StatusErrorf(http.StatusForbidden, "Failed checking if profile update allowed: Invalid value \"true\" for config \"security.privileged\" on %q: Privileged containers are forbidden", name)
+	}
+	profile.ProfilePut = req
+	s.profiles[name] = profile
+	return nil
+}
+
+// DeleteProfile implements incus.InstanceServer.
+func (s *Server) DeleteProfile(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.profiles[name]; !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Profile not found")
+	}
+	for _, inst := range s.instances {
+		if slices.Contains(inst.Profiles, name) {
+			return api.StatusErrorf(http.StatusBadRequest, "Profile is currently in use")
+		}
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+// GetStoragePoolVolume implements incus.InstanceServer.
+func (s *Server) GetStoragePoolVolume(pool string, volType string, name string) (*api.StorageVolume, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	volumes, ok := s.storagePools[pool]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Storage pool not found")
+	}
+	volume, ok := volumes[name]
+	if !ok || volume.Type != volType {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Storage pool volume not found")
+	}
+	return &volume, "", nil
+}
+
+// CreateStoragePoolVolume implements incus.InstanceServer.
+func (s *Server) CreateStoragePoolVolume(pool string, req api.StorageVolumesPost) error {
+	return s.createStoragePoolVolume(pool, req, "")
+}
+
+func (s *Server) createStoragePoolVolume(pool string, req api.StorageVolumesPost, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	volumes, ok := s.storagePools[pool]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Storage pool not found")
+	}
+	if _, ok := volumes[req.Name]; ok {
+		return api.StatusErrorf(http.StatusConflict, "Volume by that name already exists")
+	}
+
+	location := target
+	if location == "" {
+		location = s.server.Environment.ServerName
+	}
+	volumes[req.Name] = api.StorageVolume{
+		Name:             req.Name,
+		Type:             req.Type,
+		ContentType:      req.ContentType,
+		Location:         location,
+		StorageVolumePut: api.StorageVolumePut{Config: maps.Clone(req.Config)},
+	}
+	return nil
+}
+
+// DeleteStoragePoolVolume implements incus.InstanceServer.
+func (s *Server) DeleteStoragePoolVolume(pool string, volType string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	volumes, ok := s.storagePools[pool]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Storage pool not found")
+	}
+	volume, ok := volumes[name]
+	if !ok || volume.Type != volType {
+		return api.StatusErrorf(http.StatusNotFound, "Storage pool volume not found")
+	}
+	for _, inst := range s.instances {
+		for _, device := range inst.Devices {
+			if device["type"] == "disk" && device["pool"] == pool && device["source"] == name {
+				return api.StatusErrorf(http.StatusBadRequest, "Storage volume is still in use")
+			}
+		}
+	}
+	delete(volumes, name)
+	return nil
+}
+
+// GetImage implements incus.InstanceServer.
+func (s *Server) GetImage(fingerprint string) (*api.Image, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image, ok := s.images[fingerprint]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Image not found")
+	}
+	return &image, "", nil
+}
+
+// GetImageAliasType implements incus.InstanceServer.
+func (s *Server) GetImageAliasType(imageType string, name string) (*api.ImageAliasesEntry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, image := range s.images {
+		if imageType != "" && image.Type != imageType {
+			continue
+		}
+		for _, alias := range image.Aliases {
+			if alias.Name == name {
+				entry := &api.ImageAliasesEntry{Name: name, Type: image.Type}
+				entry.Target = image.Fingerprint
+				return entry, "", nil
+			}
+		}
+	}
+	return nil, "", api.StatusErrorf(http.StatusNotFound, "Image alias not found")
+}
+
+// CreateImage implements incus.InstanceServer. Only copying images from remote image servers (see WithRemoteImage) is
+// supported. The image is downloaded in the background, until FinishImageDownloads is called.
+func (s *Server) CreateImage(req api.ImagesPost, args *incus.ImageCreateArgs) (incus.Operation, error) {
+	return s.createImage(req, "")
+}
+
+func (s *Server) createImage(req api.ImagesPost, target string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Source == nil || req.Source.Type != "image" || req.Source.Mode != "pull" {
+		return nil, api.StatusErrorf(http.StatusBadRequest, "Only pulling images from remote servers is supported")
+	}
+
+	s.nextOperation++
+	op := &api.Operation{
+		ID:          fmt.Sprintf("operation-%d", s.nextOperation),
+		Class:       api.OperationClassTask,
+		Description: "Downloading image",
+		Status:      api.Running.String(),
+		StatusCode:  api.Running,
+		Metadata:    map[string]any{"download_progress": "rootfs: 0% (0B/s)"},
+		Location:    target,
+	}
+	s.operations[op.ID] = op
+
+	fingerprint := req.Source.Fingerprint
+	if fingerprint == "" {
+		fingerprint = s.remoteImages[req.Source.Server][req.Source.Alias]
+	}
+	if fingerprint == "" || !slices.Contains(slices.Collect(maps.Values(s.remoteImages[req.Source.Server])), fingerprint) {
+		op.Status, op.StatusCode, op.Metadata = api.Failure.String(), api.Failure, nil
+		op.Err = fmt.Sprintf("Image %q not found on server %q", req.Source.Alias+req.Source.Fingerprint, req.Source.Server)
+	} else {
+		s.downloads[op.ID] = imageDownload{image: api.Image{Fingerprint: fingerprint, Type: req.Source.ImageType}, target: target}
+	}
+
+	return &operation{op: *op}, nil
+}
+
+// DeleteImage implements incus.InstanceServer.
+func (s *Server) DeleteImage(fingerprint string) (incus.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.images[fingerprint]; !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Image not found")
+	}
+	delete(s.images, fingerprint)
+	return newOperation(nil), nil
+}
+
+// GetOperation implements incus.InstanceServer.
+func (s *Server) GetOperation(uuid string) (*api.Operation, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[uuid]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Operation not found")
+	}
+	result := *op
+	result.Metadata = maps.Clone(op.Metadata)
+	return &result, "", nil
+}
+
+// SetImageDownloadProgress sets the download progress that is reported by pending image download operations.
+func (s *Server) SetImageDownloadProgress(progress string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.downloads {
+		s.operations[id].Metadata = map[string]any{"download_progress": progress}
+	}
+}
+
+// FinishImageDownloads completes the pending image download operations, and adds the images to the server. If targets
+// are specified, only the downloads on these cluster members are completed.
+func (s *Server) FinishImageDownloads(targets ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, download := range s.downloads {
+		if len(targets) > 0 && !slices.Contains(targets, download.target) {
+			continue
+		}
+		op := s.operations[id]
+		op.Status, op.StatusCode = api.Success.String(), api.Success
+		op.Metadata = map[string]any{"fingerprint": download.image.Fingerprint, "size": int64(0)}
+		s.images[download.image.Fingerprint] = download.image
+		delete(s.downloads, id)
+	}
+}
+
+// ImageDownloadTargets returns the sorted cluster member targets of the pending image downloads. Downloads without a
+// target are reported with an empty target.
+func (s *Server) ImageDownloadTargets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := make([]string, 0, len(s.downloads))
+	for _, download := range s.downloads {
+		targets = append(targets, download.target)
+	}
+	slices.Sort(targets)
+	return targets
+}
+
+// ForgetOperations removes all operations from the server, like a server restart would.
+func (s *Server) ForgetOperations() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.operations)
+	clear(s.downloads)
+}
+
+// GetNetwork implements incus.InstanceServer.
+func (s *Server) GetNetwork(name string) (*api.Network, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	network, ok := s.networks[name]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Network not found")
+	}
+	return &network, "", nil
+}
+
+// GetNetworkLoadBalancer implements incus.InstanceServer.
+func (s *Server) GetNetworkLoadBalancer(networkName string, listenAddress string) (*api.NetworkLoadBalancer, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lb, ok := s.loadBalancers[networkName][listenAddress]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "Network load balancer not found")
+	}
+	return &lb, "", nil
+}
+
+// CreateNetworkLoadBalancer implements incus.InstanceServer.
+func (s *Server) CreateNetworkLoadBalancer(networkName string, req api.NetworkLoadBalancersPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.networks[networkName]; !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Network not found")
+	}
+	if _, ok := s.loadBalancers[networkName][req.ListenAddress]; ok {
+		return api.StatusErrorf(http.StatusConflict, "A load balancer for that listen address already exists")
+	}
+	if s.loadBalancers[networkName] == nil {
+		s.loadBalancers[networkName] = map[string]api.NetworkLoadBalancer{}
+	}
+	s.loadBalancers[networkName][req.ListenAddress] = api.NetworkLoadBalancer{
+		ListenAddress:          req.ListenAddress,
+		NetworkLoadBalancerPut: req.NetworkLoadBalancerPut,
+	}
+	return nil
+}
+
+// UpdateNetworkLoadBalancer implements incus.InstanceServer.
+func (s *Server) UpdateNetworkLoadBalancer(networkName string, listenAddress string, req api.NetworkLoadBalancerPut, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lb, ok := s.loadBalancers[networkName][listenAddress]
+	if !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Network load balancer not found")
+	}
+	lb.NetworkLoadBalancerPut = req
+	s.loadBalancers[networkName][listenAddress] = lb
+	return nil
+}
+
+// DeleteNetworkLoadBalancer implements incus.InstanceServer.
+func (s *Server) DeleteNetworkLoadBalancer(networkName string, listenAddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.loadBalancers[networkName][listenAddress]; !ok {
+		return api.StatusErrorf(http.StatusNotFound, "Network load balancer not found")
+	}
+	delete(s.loadBalancers[networkName], listenAddress)
+	return nil
+}
+
+// GetNetworkLoadBalancerState implements incus.InstanceServer.
+func (s *Server) GetNetworkLoadBalancerState(networkName string, listenAddress string) (*api.NetworkLoadBalancerState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.loadBalancers[networkName][listenAddress]; !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Network load balancer not found")
+	}
+	return &api.NetworkLoadBalancerState{}, nil
+}
+
+// GetNetworkAllocations implements incus.InstanceServer. It reports the addresses of instances and the listen
+// addresses of network load balancers.
+func (s *Server) GetNetworkAllocations() ([]api.NetworkAllocations, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var allocations []api.NetworkAllocations
+	for _, name := range slices.Sorted(maps.Keys(s.instances)) {
+		allocations = append(allocations, api.NetworkAllocations{
+			Address: fmt.Sprintf("%s/32", s.instances[name].address),
+			Type:    "instance",
+			UsedBy:  fmt.Sprintf("/1.0/instances/%s", name),
+		})
+	}
+	for networkName, loadBalancers := range s.loadBalancers {
+		for listenAddress := range loadBalancers {
+			bits := 32
+			if strings.Contains(listenAddress, ":") {
+				bits = 128
+			}
+			allocations = append(allocations, api.NetworkAllocations{
+				Address: fmt.Sprintf("%s/%d", listenAddress, bits),
+				Type:    "network-load-balancer",
+				UsedBy:  fmt.Sprintf("/1.0/networks/%s/load-balancers/%s", networkName, listenAddress),
+			})
+		}
+	}
+	return allocations, nil
+}
+
+// GetNetworkLeases implements incus.InstanceServer. Networks do not have any DHCP leases.
+func (s *Server) GetNetworkLeases(name string) ([]api.NetworkLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.networks[name]; !ok {
+		return nil, api.StatusErrorf(http.StatusNotFound, "Network not found")
+	}
+	return nil, nil
+}
+
+// state returns the api.InstanceState of the instance. Network addresses are only reported while the instance is running.
+func (i *instance) state() *api.InstanceState {
+	state := &api.InstanceState{
+		Status:     i.Status,
+		StatusCode: i.StatusCode,
+	}
+	if i.StatusCode == api.Running {
+		state.Pid = 1
+		state.Network = map[string]api.InstanceStateNetwork{
+			"lo": {
+				Type:      "loopback",
+				Addresses: []api.InstanceStateNetworkAddress{{Family: "inet", Address: "127.0.0.1", Netmask: "8", Scope: "local"}},
+			},
+			"eth0": {
+				Type:      "broadcast",
+				HostName:  fmt.Sprintf("veth%s", strings.ReplaceAll(i.address, ".", "")),
+				Addresses: []api.InstanceStateNetworkAddress{{Family: "inet", Address: i.address, Netmask: "16", Scope: "global"}},
+			},
+		}
+	}
+	return state
+}
+
+// targetServer is returned by UseTarget, and records the target as the location of created instances and volumes.
+type targetServer struct {
+	*Server
+
+	target string
+}
+
+// CreateInstance implements incus.InstanceServer.
+func (s *targetServer) CreateInstance(req api.InstancesPost) (incus.Operation, error) {
+	return s.createInstance(req, s.target)
+}
+
+// CreateImage implements incus.InstanceServer.
+func (s *targetServer) CreateImage(req api.ImagesPost, args *incus.ImageCreateArgs) (incus.Operation, error) {
+	return s.createImage(req, s.target)
+}
+
+// CreateStoragePoolVolume implements incus.InstanceServer.
+func (s *targetServer) CreateStoragePoolVolume(pool string, req api.StorageVolumesPost) error {
+	return s.createStoragePoolVolume(pool, req, s.target)
+}
+
+var _ incus.InstanceServer = &Server{}
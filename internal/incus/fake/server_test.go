@@ -0,0 +1,193 @@
+package fake_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/lxc/incus/v6/shared/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+
+	. "github.com/onsi/gomega"
+)
+
+func newObjects(loadBalancer infrav1.LXCClusterLoadBalancer) (*clusterv1.Cluster, *infrav1.LXCCluster, *clusterv1.Machine, *infrav1.LXCMachine) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"}}
+	lxcCluster := &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"},
+		Spec: infrav1.LXCClusterSpec{
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.100.42.1", Port: 6443},
+			LoadBalancer:         loadBalancer,
+		},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-cp-1", Namespace: "default", Labels: map[string]string{clusterv1.MachineControlPlaneLabel: ""}},
+		Spec:       clusterv1.MachineSpec{Version: ptr.To("v1.32.0")},
+	}
+	lxcMachine := &infrav1.LXCMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-cp-1", Namespace: "default"},
+		Spec:       infrav1.LXCMachineSpec{Image: infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://example.com", Protocol: "simplestreams"}},
+	}
+	return cluster, lxcCluster, machine, lxcMachine
+}
+
+func readFile(g *WithT, server *fake.Server, instanceName string, path string) string {
+	reader, _, err := server.GetInstanceFile(instanceName, path)
+	g.Expect(err).ToNot(HaveOccurred())
+	b, err := io.ReadAll(reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	return string(b)
+}
+
+func TestServer_Instance(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	lxcClient := &incus.Client{Client: server}
+	cluster, lxcCluster, machine, lxcMachine := newObjects(infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{}})
+
+	g.Expect(lxcClient.InitProfile(ctx, api.ProfilesPost{Name: lxcCluster.GetProfileName()})).To(Succeed())
+	g.Expect(lxcClient.InitProfile(ctx, api.ProfilesPost{Name: lxcCluster.GetProfileName()})).To(Succeed())
+
+	addresses, _, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, incus.CreateInstanceOptions{Image: lxcMachine.Spec.Image, Bootstrap: incus.BootstrapData{Data: "#cloud-config"}})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(addresses).To(HaveLen(1))
+	g.Expect(server.InstanceNames()).To(ConsistOf(lxcMachine.GetInstanceName()))
+
+	status, err := lxcClient.CheckCloudInitStatus(ctx, lxcMachine.GetInstanceName())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(status).To(Equal(cloudinit.StatusRunning))
+
+	g.Expect(server.FinishCloudInit(lxcMachine.GetInstanceName())).To(Succeed())
+	status, err = lxcClient.CheckCloudInitStatus(ctx, lxcMachine.GetInstanceName())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(status).To(Equal(cloudinit.StatusDone))
+
+	g.Expect(lxcClient.DeleteInstance(ctx, lxcMachine)).To(Succeed())
+	g.Expect(lxcClient.DeleteInstance(ctx, lxcMachine)).To(Succeed())
+	g.Expect(server.InstanceNames()).To(BeEmpty())
+
+	g.Expect(lxcClient.DeleteProfile(ctx, lxcCluster.GetProfileName())).To(Succeed())
+	g.Expect(lxcClient.DeleteProfile(ctx, lxcCluster.GetProfileName())).To(Succeed())
+}
+
+func TestServer_LoadBalancerLXC(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	lxcClient := &incus.Client{Client: server}
+	cluster, lxcCluster, machine, lxcMachine := newObjects(infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}})
+	lxcCluster.Spec.SkipDefaultKubeadmProfile = true
+
+	lbAddresses, err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lbAddresses).To(HaveLen(1))
+
+	addresses, _, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, incus.CreateInstanceOptions{Image: lxcMachine.Spec.Image})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var commands [][]string
+	server.ExecHandler = func(instanceName string, command []string, stdout io.Writer, stderr io.Writer) int {
+		commands = append(commands, command)
+		return 0
+	}
+
+	g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx)).To(Succeed())
+	haproxyCfg := readFile(g, server, lxcCluster.GetLoadBalancerInstanceName(), "/etc/haproxy/haproxy.cfg")
+	g.Expect(haproxyCfg).To(ContainSubstring("%s:6443 weight 100", addresses[0]))
+	g.Expect(commands).To(Equal([][]string{
+		{"haproxy", "-c", "-f", "/etc/haproxy/haproxy.cfg.new"},
+		{"systemctl", "reload", "haproxy.service"},
+	}))
+
+	// invalid configurations are not applied
+	commands = nil
+	server.ExecHandler = func(instanceName string, command []string, stdout io.Writer, stderr io.Writer) int {
+		commands = append(commands, command)
+		if command[0] == "haproxy" {
+			_, _ = io.WriteString(stdout, "[ALERT] config : parsing [/etc/haproxy/haproxy.cfg.new:1] : unknown keyword 'invalid'")
+			return 1
+		}
+		return 0
+	}
+	err = lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster, incus.WithLoadBalancerConfigTemplate("invalid")).Reconfigure(ctx)
+	g.Expect(err).To(MatchError(ContainSubstring("unknown keyword 'invalid'")))
+	g.Expect(readFile(g, server, lxcCluster.GetLoadBalancerInstanceName(), "/etc/haproxy/haproxy.cfg")).To(Equal(haproxyCfg))
+	g.Expect(commands).To(Equal([][]string{{"haproxy", "-c", "-f", "/etc/haproxy/haproxy.cfg.new"}}))
+
+	g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Delete(ctx)).To(Succeed())
+	g.Expect(server.InstanceNames()).To(ConsistOf(lxcMachine.GetInstanceName()))
+}
+
+func TestServer_LoadBalancerOVN(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer(fake.WithNetworks(api.Network{Name: "ovn0", Type: "ovn"}))
+	lxcClient := &incus.Client{Client: server}
+	cluster, lxcCluster, machine, lxcMachine := newObjects(infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn0"}})
+	lxcCluster.Spec.SkipDefaultKubeadmProfile = true
+
+	lbAddresses, err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lbAddresses).To(ConsistOf("10.100.42.1"))
+
+	addresses, _, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, incus.CreateInstanceOptions{Image: lxcMachine.Spec.Image})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx)).To(Succeed())
+	lb, _, err := server.GetNetworkLoadBalancer("ovn0", "10.100.42.1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lb.Backends).To(ConsistOf(api.NetworkLoadBalancerBackend{Name: lxcMachine.GetInstanceName(), TargetAddress: addresses[0], TargetPort: "6443"}))
+
+	g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Delete(ctx)).To(Succeed())
+	_, _, err = server.GetNetworkLoadBalancer("ovn0", "10.100.42.1")
+	g.Expect(err).To(MatchError(ContainSubstring("not found")))
+}
+
+func TestServer_MachinePool(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	server := fake.NewServer()
+	lxcClient := &incus.Client{Client: server}
+	cluster, lxcCluster, machine, lxcMachine := newObjects(infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{}})
+	lxcCluster.Spec.SkipDefaultKubeadmProfile = true
+
+	machinePool := &expv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-mp-0", Namespace: "default"},
+		Spec:       expv1.MachinePoolSpec{Template: clusterv1.MachineTemplateSpec{Spec: clusterv1.MachineSpec{Version: ptr.To("v1.32.0")}}},
+	}
+	lxcMachinePool := &infrav1.LXCMachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-mp-0", Namespace: "default"},
+		Spec:       infrav1.LXCMachinePoolSpec{Template: lxcMachine.Spec},
+	}
+
+	_, _, err := lxcClient.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, incus.CreateInstanceOptions{Image: lxcMachine.Spec.Image})
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, name := range []string{"c1-mp-0-a", "c1-mp-0-b"} {
+		addresses, err := lxcClient.CreateMachinePoolInstance(ctx, name, machinePool, lxcMachinePool, cluster, lxcCluster, nil, incus.CreateInstanceOptions{Image: lxcMachinePool.Spec.Template.Image, Bootstrap: incus.BootstrapData{Data: "#cloud-config"}})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(addresses).To(HaveLen(1))
+	}
+
+	instances, err := lxcClient.GetMachinePoolInstances(ctx, cluster, lxcMachinePool)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instances).To(ConsistOf(HaveField("Name", "c1-mp-0-a"), HaveField("Name", "c1-mp-0-b")))
+	g.Expect(instances[0].Config).To(HaveKeyWithValue("user.cluster-role", "worker"))
+	g.Expect(instances[0].Config).To(HaveKeyWithValue("cloud-init.user-data", "#cloud-config"))
+
+	g.Expect(lxcClient.DeleteMachinePoolInstance(ctx, "c1-mp-0-a")).To(Succeed())
+	g.Expect(lxcClient.DeleteMachinePoolInstance(ctx, "c1-mp-0-a")).To(Succeed())
+	g.Expect(server.InstanceNames()).To(ConsistOf(lxcMachine.GetInstanceName(), "c1-mp-0-b"))
+}
@@ -0,0 +1,80 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AllocateAddress returns the first address of the cidr range that is not in use. Addresses are considered in use
+// if they are allocated on the Incus server (e.g. instance addresses, network forwards and network load balancers),
+// leased by the DHCP server of networkName (if set), or included in the exclude list.
+//
+// The network and broadcast addresses of IPv4 ranges, and the first address of IPv6 ranges are never allocated.
+//
+// If the server does not support the required extensions, or the cidr is invalid, a terminalError is returned.
+func (c *Client) AllocateAddress(ctx context.Context, cidr string, networkName string, exclude []string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", terminalError{fmt.Errorf("invalid address range %q: %w", cidr, err)}
+	}
+	prefix = prefix.Masked()
+
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("cidr", prefix, "networkName", networkName))
+
+	if unsupported, err := c.serverSupportsExtensions("network_allocations"); err != nil {
+		return "", fmt.Errorf("failed to check if server supports 'network_allocations' extension: %w", err)
+	} else if len(unsupported) > 0 {
+		return "", terminalError{fmt.Errorf("server cannot report addresses in use, required extensions are missing: %v", unsupported)}
+	}
+
+	inUse := sets.New(exclude...)
+	allocations, err := c.Client.GetNetworkAllocations()
+	if err != nil {
+		return "", fmt.Errorf("failed to GetNetworkAllocations: %w", err)
+	}
+	for _, allocation := range allocations {
+		// allocation addresses are in CIDR format, e.g. "10.0.0.10/32"
+		address, _, _ := strings.Cut(allocation.Address, "/")
+		inUse.Insert(address)
+	}
+	if networkName != "" {
+		leases, err := c.Client.GetNetworkLeases(networkName)
+		if err != nil {
+			return "", fmt.Errorf("failed to GetNetworkLeases: %w", err)
+		}
+		for _, lease := range leases {
+			inUse.Insert(lease.Address)
+		}
+	}
+
+	first, last := prefix.Addr(), lastAddress(prefix)
+	if prefix.Addr().Is4() && prefix.Bits() < 31 || prefix.Addr().Is6() && prefix.Bits() < 127 {
+		first = first.Next()
+	}
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		last = last.Prev()
+	}
+	for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+		if !inUse.Has(addr.String()) {
+			log.FromContext(ctx).V(2).WithValues("address", addr).Info("Allocated address")
+			return addr.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no free address in range %s", prefix)
+}
+
+// lastAddress returns the last address of a prefix.
+func lastAddress(prefix netip.Prefix) netip.Addr {
+	b := prefix.Masked().Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,86 @@
+package incus
+
+import (
+	"context"
+	"testing"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+
+	. "github.com/onsi/gomega"
+)
+
+type mockClient_allocateAddress struct {
+	incus.InstanceServer
+
+	extensions  []string
+	allocations []api.NetworkAllocations
+	leases      []api.NetworkLease
+}
+
+func (c *mockClient_allocateAddress) GetServer() (*api.Server, string, error) {
+	return &api.Server{ServerUntrusted: api.ServerUntrusted{APIExtensions: c.extensions}}, "", nil
+}
+
+func (c *mockClient_allocateAddress) GetNetworkAllocations() ([]api.NetworkAllocations, error) {
+	return c.allocations, nil
+}
+
+func (c *mockClient_allocateAddress) GetNetworkLeases(name string) ([]api.NetworkLease, error) {
+	return c.leases, nil
+}
+
+func TestAllocateAddress(t *testing.T) {
+	mock := &mockClient_allocateAddress{
+		extensions: []string{"network_allocations"},
+		allocations: []api.NetworkAllocations{
+			{Address: "10.0.0.1/32", Type: "instance"},
+			{Address: "fd42::1/128", Type: "network-load-balancer"},
+		},
+		leases: []api.NetworkLease{
+			{Address: "10.0.0.2"},
+		},
+	}
+	c := &Client{Client: mock}
+
+	for _, tc := range []struct {
+		name           string
+		cidr           string
+		networkName    string
+		exclude        []string
+		expectAddress  string
+		expectErr      bool
+		expectTerminal bool
+	}{
+		{name: "IPv4", cidr: "10.0.0.0/29", expectAddress: "10.0.0.2"},
+		{name: "IPv4WithLeases", cidr: "10.0.0.0/29", networkName: "br0", expectAddress: "10.0.0.3"},
+		{name: "IPv4WithExclude", cidr: "10.0.0.0/29", networkName: "br0", exclude: []string{"10.0.0.3"}, expectAddress: "10.0.0.4"},
+		{name: "IPv4NotMasked", cidr: "10.0.0.5/29", expectAddress: "10.0.0.2"},
+		{name: "IPv4SingleAddress", cidr: "10.0.0.10/32", expectAddress: "10.0.0.10"},
+		{name: "IPv4Full", cidr: "10.0.0.0/30", networkName: "br0", expectErr: true},
+		{name: "IPv6", cidr: "fd42::/126", expectAddress: "fd42::2"},
+		{name: "Invalid", cidr: "10.0.0.0", expectErr: true, expectTerminal: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			address, err := c.AllocateAddress(context.TODO(), tc.cidr, tc.networkName, tc.exclude)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(IsTerminalError(err)).To(Equal(tc.expectTerminal))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(address).To(Equal(tc.expectAddress))
+			}
+		})
+	}
+
+	t.Run("MissingExtension", func(t *testing.T) {
+		g := NewWithT(t)
+
+		c := &Client{Client: &mockClient_allocateAddress{}}
+		_, err := c.AllocateAddress(context.TODO(), "10.0.0.0/29", "", nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(IsTerminalError(err)).To(BeTrue())
+	})
+}
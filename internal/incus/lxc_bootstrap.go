@@ -0,0 +1,71 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lxc/incus/v6/shared/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+)
+
+const (
+	// BootstrapFormatCloudConfig is the format of cloud-init bootstrap data. It matches the "format" key of Cluster API bootstrap data secrets.
+	BootstrapFormatCloudConfig = "cloud-config"
+
+	// BootstrapFormatIgnition is the format of Ignition bootstrap data. It matches the "format" key of Cluster API bootstrap data secrets.
+	BootstrapFormatIgnition = "ignition"
+
+	// bootstrapSuccessPath is the sentinel file that bootstrap providers write once bootstrap has completed successfully.
+	// See https://cluster-api.sigs.k8s.io/developer/providers/contracts/bootstrap-config#sentinel-file
+	bootstrapSuccessPath = "/run/cluster-api/bootstrap-success.complete"
+)
+
+// ignitionFirmwareConfigNames are the QEMU firmware configuration entries that Ignition reads the config from.
+// Flatcar Container Linux uses "opt/org.flatcar-linux/config", Fedora CoreOS uses "opt/com.coreos/config".
+var ignitionFirmwareConfigNames = []string{"opt/org.flatcar-linux/config", "opt/com.coreos/config"}
+
+// ignitionQEMUArgs returns the raw.qemu arguments that pass an Ignition config to a virtual machine.
+func ignitionQEMUArgs(config string) string {
+	// commas are escaped by doubling them in QEMU option values
+	value := strings.ReplaceAll(config, ",", ",,")
+
+	args := make([]string, 0, 2*len(ignitionFirmwareConfigNames))
+	for _, name := range ignitionFirmwareConfigNames {
+		// raw.qemu is split using shell quoting rules
+		args = append(args, "-fw_cfg", "'"+strings.ReplaceAll(fmt.Sprintf("name=%s,string=%s", name, value), "'", `'\''`)+"'")
+	}
+	return strings.Join(args, " ")
+}
+
+// CheckBootstrapStatus checks the bootstrap status of an instance, based on the format of the bootstrap data.
+//
+// For cloud-config, this is the cloud-init status of the instance, see CheckCloudInitStatus.
+//
+// For Ignition, bootstrap is done once the bootstrap success sentinel file exists on the instance. Ignition failures
+// cannot be observed, so the result is one of the following:
+//
+// - (cloudinit.StatusDone, nil)
+// - (cloudinit.StatusRunning, nil)
+// - (cloudinit.StatusUnknown, <error describing why status is unknown>)
+func (c *Client) CheckBootstrapStatus(ctx context.Context, name string, format string) (cloudinit.Status, error) {
+	if format != BootstrapFormatIgnition {
+		return c.CheckCloudInitStatus(ctx, name)
+	}
+
+	reader, _, err := c.Client.GetInstanceFile(name, bootstrapSuccessPath)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			log.FromContext(ctx).V(2).WithValues("instance", name, "path", bootstrapSuccessPath).Info("Bootstrap sentinel file does not exist yet")
+			return cloudinit.StatusRunning, nil
+		}
+		return cloudinit.StatusUnknown, fmt.Errorf("failed to read bootstrap sentinel file: failed to GetInstanceFile: %w", err)
+	}
+	if reader != nil {
+		_ = reader.Close()
+	}
+	return cloudinit.StatusDone, nil
+}
@@ -0,0 +1,90 @@
+package incus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestIgnitionQEMUArgs(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ignitionQEMUArgs(`{"a":"b,c","d":"it's"}`)).To(Equal(
+		`-fw_cfg 'name=opt/org.flatcar-linux/config,string={"a":"b,,c",,"d":"it'\''s"}' ` +
+			`-fw_cfg 'name=opt/com.coreos/config,string={"a":"b,,c",,"d":"it'\''s"}'`,
+	))
+}
+
+type mockClient_bootstrapStatus struct {
+	incus.InstanceServer
+
+	files map[string]string
+	err   error
+}
+
+func (c *mockClient_bootstrapStatus) GetInstanceFile(instanceName string, filePath string) (io.ReadCloser, *incus.InstanceFileResponse, error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	if content, ok := c.files[filePath]; ok {
+		return io.NopCloser(strings.NewReader(content)), &incus.InstanceFileResponse{Type: "file"}, nil
+	}
+	return nil, nil, api.StatusErrorf(http.StatusNotFound, "Not Found")
+}
+
+func TestCheckBootstrapStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		format       string
+		mock         *mockClient_bootstrapStatus
+		expectStatus cloudinit.Status
+		expectErr    bool
+	}{
+		{
+			name:         "CloudConfig",
+			format:       BootstrapFormatCloudConfig,
+			mock:         &mockClient_bootstrapStatus{files: map[string]string{"/var/lib/cloud/data/status.json": `{"v1":{"stage":"modules-final"}}`}},
+			expectStatus: cloudinit.StatusRunning,
+		},
+		{
+			name:         "IgnitionDone",
+			format:       BootstrapFormatIgnition,
+			mock:         &mockClient_bootstrapStatus{files: map[string]string{bootstrapSuccessPath: "success"}},
+			expectStatus: cloudinit.StatusDone,
+		},
+		{
+			name:         "IgnitionRunning",
+			format:       BootstrapFormatIgnition,
+			mock:         &mockClient_bootstrapStatus{},
+			expectStatus: cloudinit.StatusRunning,
+		},
+		{
+			name:         "IgnitionUnknown",
+			format:       BootstrapFormatIgnition,
+			mock:         &mockClient_bootstrapStatus{err: api.StatusErrorf(http.StatusInternalServerError, "VM agent isn't currently running")},
+			expectStatus: cloudinit.StatusUnknown,
+			expectErr:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			status, err := (&Client{Client: tc.mock}).CheckBootstrapStatus(context.TODO(), "instance", tc.format)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+			g.Expect(status).To(Equal(tc.expectStatus))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// GetFailureDomains returns the list of failure domains based on the Incus cluster members or cluster groups.
+// It returns nil if failure domains are not configured.
+//
+// If the server does not support the required extensions, a terminalError is returned.
+func (c *Client) GetFailureDomains(ctx context.Context, spec *infrav1.LXCClusterFailureDomains) (clusterv1.FailureDomains, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("failureDomains.type", spec.Type, "failureDomains.names", spec.Names))
+
+	var names []string
+	switch spec.Type {
+	case infrav1.FailureDomainTypeClusterMember:
+		if unsupported, err := c.serverSupportsExtensions("clustering"); err != nil {
+			return nil, fmt.Errorf("failed to check if server supports 'clustering' extension: %w", err)
+		} else if len(unsupported) > 0 {
+			return nil, terminalError{fmt.Errorf("server cannot use cluster members as failure domains, required extensions are missing: %v", unsupported)}
+		}
+
+		members, err := c.Client.GetClusterMembers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to GetClusterMembers: %w", err)
+		}
+		for _, member := range members {
+			if member.Status != "Online" {
+				log.FromContext(ctx).V(2).WithValues("member", member.ServerName, "status", member.Status).Info("Ignoring cluster member that is not online")
+				continue
+			}
+			names = append(names, member.ServerName)
+		}
+	case infrav1.FailureDomainTypeClusterGroup:
+		if unsupported, err := c.serverSupportsExtensions("clustering", "clustering_groups"); err != nil {
+			return nil, fmt.Errorf("failed to check if server supports 'clustering_groups' extension: %w", err)
+		} else if len(unsupported) > 0 {
+			return nil, terminalError{fmt.Errorf("server cannot use cluster groups as failure domains, required extensions are missing: %v", unsupported)}
+		}
+
+		groups, err := c.Client.GetClusterGroups()
+		if err != nil {
+			return nil, fmt.Errorf("failed to GetClusterGroups: %w", err)
+		}
+		for _, group := range groups {
+			if len(group.Members) == 0 {
+				log.FromContext(ctx).V(2).WithValues("group", group.Name).Info("Ignoring cluster group without members")
+				continue
+			}
+			names = append(names, group.Name)
+		}
+	default:
+		return nil, terminalError{fmt.Errorf("unknown failure domain type %q", spec.Type)}
+	}
+
+	failureDomains := make(clusterv1.FailureDomains, len(names))
+	for _, name := range names {
+		if len(spec.Names) > 0 && !slices.Contains(spec.Names, name) {
+			continue
+		}
+		failureDomains[name] = clusterv1.FailureDomainSpec{ControlPlane: true}
+	}
+
+	log.FromContext(ctx).V(2).WithValues("result", failureDomains).Info("Discovered failure domains")
+	return failureDomains, nil
+}
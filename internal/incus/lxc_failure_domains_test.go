@@ -0,0 +1,110 @@
+package incus
+
+import (
+	"context"
+	"testing"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+
+	. "github.com/onsi/gomega"
+)
+
+type mockClient_getFailureDomains struct {
+	incus.InstanceServer
+
+	members []api.ClusterMember
+	groups  []api.ClusterGroup
+}
+
+func (c *mockClient_getFailureDomains) GetServer() (*api.Server, string, error) {
+	return &api.Server{ServerUntrusted: api.ServerUntrusted{
+		APIExtensions: []string{"clustering", "clustering_groups"},
+	}}, "", nil
+}
+
+func (c *mockClient_getFailureDomains) GetClusterMembers() ([]api.ClusterMember, error) {
+	return c.members, nil
+}
+
+func (c *mockClient_getFailureDomains) GetClusterGroups() ([]api.ClusterGroup, error) {
+	return c.groups, nil
+}
+
+func TestGetFailureDomains(t *testing.T) {
+	mock := &mockClient_getFailureDomains{
+		members: []api.ClusterMember{
+			{ServerName: "w01", Status: "Online"},
+			{ServerName: "w02", Status: "Online"},
+			{ServerName: "w03", Status: "Offline"},
+		},
+		groups: []api.ClusterGroup{
+			{ClusterGroupPost: api.ClusterGroupPost{Name: "default"}, ClusterGroupPut: api.ClusterGroupPut{Members: []string{"w01", "w02", "w03"}}},
+			{ClusterGroupPost: api.ClusterGroupPost{Name: "gpu"}, ClusterGroupPut: api.ClusterGroupPut{Members: []string{"w03"}}},
+			{ClusterGroupPost: api.ClusterGroupPost{Name: "empty"}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		spec   *infrav1.LXCClusterFailureDomains
+		expect clusterv1.FailureDomains
+	}{
+		{
+			name: "Nil",
+		},
+		{
+			name: "ClusterMember",
+			spec: &infrav1.LXCClusterFailureDomains{Type: infrav1.FailureDomainTypeClusterMember},
+			expect: clusterv1.FailureDomains{
+				"w01": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"w02": clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		},
+		{
+			name: "ClusterMemberWithNames",
+			spec: &infrav1.LXCClusterFailureDomains{Type: infrav1.FailureDomainTypeClusterMember, Names: []string{"w02", "w03"}},
+			expect: clusterv1.FailureDomains{
+				"w02": clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		},
+		{
+			name: "ClusterGroup",
+			spec: &infrav1.LXCClusterFailureDomains{Type: infrav1.FailureDomainTypeClusterGroup},
+			expect: clusterv1.FailureDomains{
+				"default": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"gpu":     clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		},
+		{
+			name: "ClusterGroupWithNames",
+			spec: &infrav1.LXCClusterFailureDomains{Type: infrav1.FailureDomainTypeClusterGroup, Names: []string{"gpu"}},
+			expect: clusterv1.FailureDomains{
+				"gpu": clusterv1.FailureDomainSpec{ControlPlane: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &Client{Client: mock}
+			failureDomains, err := c.GetFailureDomains(context.TODO(), tc.spec)
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(failureDomains).To(Equal(tc.expect))
+		})
+	}
+
+	t.Run("UnknownType", func(t *testing.T) {
+		g := NewWithT(t)
+
+		c := &Client{Client: mock}
+		_, err := c.GetFailureDomains(context.TODO(), &infrav1.LXCClusterFailureDomains{Type: "Unknown"})
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(IsTerminalError(err)).To(BeTrue())
+	})
+}
@@ -1,8 +1,10 @@
 package incus
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -21,8 +23,10 @@ func (c *Client) CheckCloudInitStatus(ctx context.Context, name string) (result
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name, "path", "/var/lib/cloud/data/status.json"))
 
 	reader, _, err := c.Client.GetInstanceFile(name, "/var/lib/cloud/data/status.json")
-	if err != nil || reader == nil {
+	if err != nil {
 		return cloudinit.StatusUnknown, fmt.Errorf("failed to read cloud-init status file: failed to GetInstanceFile: %w", err)
+	} else if reader == nil {
+		return cloudinit.StatusUnknown, fmt.Errorf("failed to read cloud-init status file: GetInstanceFile returned no content")
 	}
 	defer func() { _ = reader.Close() }()
 
@@ -31,3 +35,84 @@ func (c *Client) CheckCloudInitStatus(ctx context.Context, name string) (result
 	}()
 	return cloudinit.ParseStatus(reader)
 }
+
+// GetCloudInitFailure returns a human readable description of a failed cloud-init run on an instance. It includes the
+// errors of the failed cloud-init stages and the last lines of the cloud-init output log.
+//
+// A missing or unreadable output log is not an error, the description then only includes the stage errors.
+func (c *Client) GetCloudInitFailure(ctx context.Context, name string) (string, error) {
+	reader, _, err := c.Client.GetInstanceFile(name, "/var/lib/cloud/data/status.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to read cloud-init status file: failed to GetInstanceFile: %w", err)
+	} else if reader == nil {
+		return "", fmt.Errorf("failed to read cloud-init status file: GetInstanceFile returned no content")
+	}
+	defer func() { _ = reader.Close() }()
+
+	stages, err := cloudinit.ParseErrors(reader)
+	if err != nil {
+		return "", err
+	}
+
+	var outputTail string
+	if logReader, _, err := c.Client.GetInstanceFile(name, cloudInitOutputLogPath); err != nil || logReader == nil {
+		log.FromContext(ctx).V(2).WithValues("instance", name, "path", cloudInitOutputLogPath, "error", err).Info("Could not read cloud-init output log")
+	} else {
+		defer func() { _ = logReader.Close() }()
+		if b, err := readTail(logReader, cloudInitOutputTailBytes); err != nil {
+			log.FromContext(ctx).V(2).WithValues("instance", name, "path", cloudInitOutputLogPath, "error", err).Info("Could not read cloud-init output log")
+		} else {
+			outputTail = tailLines(b, cloudInitOutputTailLines, cloudInitOutputTailBytes)
+		}
+	}
+
+	return cloudinit.FailureMessage(stages, outputTail), nil
+}
+
+// tailLines returns the last lines of b, limited to at most maxLines lines and maxBytes bytes.
+func tailLines(b []byte, maxLines int, maxBytes int) string {
+	b = bytes.TrimRight(b, "\n")
+	if len(b) > maxBytes {
+		b = b[len(b)-maxBytes:]
+		// drop the partial first line
+		if idx := bytes.IndexByte(b, '\n'); idx >= 0 {
+			b = b[idx+1:]
+		}
+	}
+	lines := bytes.Split(b, []byte("\n"))
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return string(bytes.Join(lines, []byte("\n")))
+}
+
+// readTail reads r until EOF and returns the complete lines within its last maxBytes bytes. At most maxBytes bytes (plus
+// the size of a read) are kept in memory, regardless of the size of r.
+func readTail(r io.Reader, maxBytes int) ([]byte, error) {
+	var truncated bool
+	b := make([]byte, 0, maxBytes+1+4096)
+	chunk := make([]byte, 4096)
+	for {
+		n, err := r.Read(chunk)
+		b = append(b, chunk[:n]...)
+		// keep one more byte, to tell whether the first line is complete
+		if len(b) > maxBytes+1 {
+			b = append(b[:0], b[len(b)-maxBytes-1:]...)
+			truncated = true
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if truncated {
+		// drop the partial first line
+		if idx := bytes.IndexByte(b, '\n'); idx >= 0 {
+			b = b[idx+1:]
+		} else {
+			b = nil
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,53 @@
+package incus
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_tailLines(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		maxLines int
+		maxBytes int
+		want     string
+	}{
+		{name: "Empty", input: "", maxLines: 2, maxBytes: 100, want: ""},
+		{name: "Short", input: "line1\nline2\n", maxLines: 5, maxBytes: 100, want: "line1\nline2"},
+		{name: "MaxLines", input: "line1\nline2\nline3\nline4\n", maxLines: 2, maxBytes: 100, want: "line3\nline4"},
+		{name: "MaxBytes", input: "line1\nline2\nline3\nline4\n", maxLines: 10, maxBytes: 14, want: "line3\nline4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(tailLines([]byte(tc.input), tc.maxLines, tc.maxBytes)).To(Equal(tc.want))
+		})
+	}
+}
+
+func Test_readTail(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		maxBytes int
+		want     string
+	}{
+		{name: "Empty", input: "", maxBytes: 100, want: ""},
+		{name: "Short", input: "line1\nline2\n", maxBytes: 100, want: "line1\nline2\n"},
+		{name: "Truncated", input: "line1\nline2\nline3\nline4\n", maxBytes: 14, want: "line3\nline4\n"},
+		{name: "TruncatedNoNewline", input: "line1\nline2\nline3\nline4", maxBytes: 8, want: "line4"},
+		{name: "SingleLongLine", input: "line1line2line3", maxBytes: 8, want: ""},
+		{name: "Large", input: strings.Repeat("line\n", 10000) + "last\n", maxBytes: 10, want: "line\nlast\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			b, err := readTail(strings.NewReader(tc.input), tc.maxBytes)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(b)).To(Equal(tc.want))
+		})
+	}
+}
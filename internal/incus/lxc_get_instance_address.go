@@ -1,9 +1,12 @@
 package incus
 
 import (
+	"net/netip"
 	"slices"
 
 	"github.com/lxc/incus/v6/shared/api"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 )
 
 // ParseActiveMachineAddresses returns the main IP addresses of the instance.
@@ -45,3 +48,33 @@ func (c *Client) ParseActiveMachineAddresses(state *api.InstanceState) []string
 	slices.Sort(addresses)
 	return addresses
 }
+
+// FilterAddressesForFamily returns the addresses that match the address family preference of the cluster.
+//
+// For "IPv4" and "IPv6", only addresses of the respective family are returned. For "DualStack", all addresses are
+// returned, with IPv4 addresses first. If the address family is empty, the addresses are returned unchanged.
+func FilterAddressesForFamily(addresses []string, addressFamily string) []string {
+	var ipv4, ipv6 []string
+	for _, address := range addresses {
+		addr, err := netip.ParseAddr(address)
+		switch {
+		case err != nil:
+			continue
+		case addr.Is4():
+			ipv4 = append(ipv4, address)
+		default:
+			ipv6 = append(ipv6, address)
+		}
+	}
+
+	switch addressFamily {
+	case infrav1.AddressFamilyIPv4:
+		return ipv4
+	case infrav1.AddressFamilyIPv6:
+		return ipv6
+	case infrav1.AddressFamilyDualStack:
+		return append(ipv4, ipv6...)
+	default:
+		return addresses
+	}
+}
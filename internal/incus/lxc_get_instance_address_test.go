@@ -0,0 +1,34 @@
+package incus
+
+import (
+	"testing"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFilterAddressesForFamily(t *testing.T) {
+	addresses := []string{"10.0.0.10", "2001:db8::10", "192.168.1.10", "fd42::10"}
+
+	for _, tc := range []struct {
+		name          string
+		addressFamily string
+		expect        []string
+	}{
+		{name: "Default", addressFamily: "", expect: addresses},
+		{name: "IPv4", addressFamily: infrav1.AddressFamilyIPv4, expect: []string{"10.0.0.10", "192.168.1.10"}},
+		{name: "IPv6", addressFamily: infrav1.AddressFamilyIPv6, expect: []string{"2001:db8::10", "fd42::10"}},
+		{name: "DualStack", addressFamily: infrav1.AddressFamilyDualStack, expect: []string{"10.0.0.10", "192.168.1.10", "2001:db8::10", "fd42::10"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(FilterAddressesForFamily(addresses, tc.addressFamily)).To(Equal(tc.expect))
+		})
+	}
+
+	t.Run("NoMatch", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(FilterAddressesForFamily([]string{"10.0.0.10"}, infrav1.AddressFamilyIPv6)).To(BeEmpty())
+	})
+}
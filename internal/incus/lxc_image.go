@@ -0,0 +1,198 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// ImageDownload is the status of an operation that copies an image into the server.
+type ImageDownload struct {
+	// Done is true when the image has been copied into the server.
+	Done bool
+	// Fingerprint is the fingerprint of the image. It is set once the download is done.
+	Fingerprint string
+	// Progress is the download progress reported by the server (e.g. "rootfs: 45% (12.50MB/s)").
+	Progress string
+}
+
+// resolveUbuntuImage returns the image source for image names with the special `ubuntu:VERSION` prefix, depending
+// on the server type (Incus or LXD). Other image sources are returned unchanged.
+//
+// If the server is neither Incus nor LXD, a terminalError is returned.
+func (c *Client) resolveUbuntuImage(ctx context.Context, image infrav1.LXCMachineImageSource) (infrav1.LXCMachineImageSource, error) {
+	if !strings.HasPrefix(image.Name, "ubuntu:") {
+		return image, nil
+	}
+
+	server, _, err := c.Client.GetServer()
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to GetServer")
+		return image, nil
+	}
+
+	switch server.Environment.Server {
+	case "incus":
+		image = infrav1.LXCMachineImageSource{
+			Name:     fmt.Sprintf("ubuntu/%s/cloud", strings.TrimPrefix(image.Name, "ubuntu:")),
+			Server:   "https://images.linuxcontainers.org",
+			Protocol: "simplestreams",
+		}
+		log.FromContext(ctx).V(2).WithValues("image", image).Info("Using Ubuntu image from https://images.linuxcontainers.org")
+	case "lxd":
+		image = infrav1.LXCMachineImageSource{
+			Name:     strings.TrimPrefix(image.Name, "ubuntu:"),
+			Server:   "https://cloud-images.ubuntu.com/releases/",
+			Protocol: "simplestreams",
+		}
+		log.FromContext(ctx).V(2).WithValues("image", image).Info("Using Ubuntu image from https://cloud-images.ubuntu.com/releases/")
+	default:
+		return image, terminalError{fmt.Errorf("image name is %q, but server is %q. Images with 'ubuntu:' prefix are only allowed for Incus and LXD", image.Name, server.Environment.Server)}
+	}
+	return image, nil
+}
+
+// GetImageFingerprint returns the fingerprint of an image that exists on the server. The image is looked up by
+// fingerprint, or by alias for the specified instance type. The remote server of the image source is ignored.
+//
+// An empty fingerprint is returned if the image does not exist on the server.
+func (c *Client) GetImageFingerprint(ctx context.Context, image infrav1.LXCMachineImageSource, instanceType string) (string, error) {
+	if image.Fingerprint != "" {
+		result, _, err := c.Client.GetImage(image.Fingerprint)
+		if err != nil {
+			if api.StatusErrorCheck(err, http.StatusNotFound) {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to GetImage: %w", err)
+		}
+		return result.Fingerprint, nil
+	}
+
+	alias, _, err := c.Client.GetImageAliasType(string(c.instanceTypeFromAPI(instanceType)), image.Name)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to GetImageAliasType: %w", err)
+	}
+	return alias.Target, nil
+}
+
+// StartImageDownload starts copying an image from its remote server into the server, and returns the ID of the
+// server operation. The progress of the operation can be retrieved with GetImageDownload.
+//
+// On clustered servers, target is the cluster member that pulls the image. If target is empty, the image is pulled
+// by the cluster member that handles the request.
+//
+// Images with the special `ubuntu:VERSION` name are copied from the appropriate image server, see resolveUbuntuImage.
+func (c *Client) StartImageDownload(ctx context.Context, image infrav1.LXCMachineImageSource, instanceType string, target string) (string, error) {
+	image, err := c.resolveUbuntuImage(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	if image.Server == "" {
+		return "", terminalError{fmt.Errorf("image %q does not have a remote server", image.Name)}
+	}
+
+	client := c.Client
+	if target != "" {
+		client = client.UseTarget(target)
+	}
+	op, err := client.CreateImage(api.ImagesPost{
+		Source: &api.ImagesPostSource{
+			ImageSource: api.ImageSource{
+				Alias:     image.Name,
+				Server:    image.Server,
+				Protocol:  image.Protocol,
+				ImageType: string(c.instanceTypeFromAPI(instanceType)),
+			},
+			Fingerprint: image.Fingerprint,
+			Mode:        "pull",
+			Type:        "image",
+		},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to CreateImage: %w", err)
+	}
+
+	operation := op.Get()
+	log.FromContext(ctx).V(2).WithValues("image", image, "target", target, "operation", operation.ID).Info("Started image download")
+	return operation.ID, nil
+}
+
+// GetImageDownload returns the status of an image download that was started with StartImageDownload. target must be
+// the cluster member that the download was started on.
+//
+// If the operation does not exist anymore (e.g. because the server was restarted), nil is returned.
+// If the operation has failed, an error is returned.
+func (c *Client) GetImageDownload(ctx context.Context, operationID string, target string) (*ImageDownload, error) {
+	client := c.Client
+	if target != "" {
+		client = client.UseTarget(target)
+	}
+	op, _, err := client.GetOperation(operationID)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to GetOperation: %w", err)
+	}
+
+	switch op.StatusCode {
+	case api.Success:
+		fingerprint, _ := op.Metadata["fingerprint"].(string)
+		if fingerprint == "" {
+			return nil, fmt.Errorf("image download operation %q did not report the image fingerprint", operationID)
+		}
+		return &ImageDownload{Done: true, Fingerprint: fingerprint}, nil
+	case api.Failure, api.Cancelled:
+		return nil, fmt.Errorf("image download operation %q failed: %s", operationID, op.Err)
+	default:
+		progress, _ := op.Metadata["download_progress"].(string)
+		return &ImageDownload{Progress: progress}, nil
+	}
+}
+
+// pinInstanceImage records the fingerprint of the image that an instance was created from in the instance
+// configuration, and returns it. The server resolves image names (aliases) to a fingerprint when the instance is
+// created, and records it in the "volatile.base_image" key. The fingerprint is copied to the
+// "user.cluster-image-fingerprint" key, next to the other keys that track the instances of a cluster.
+//
+// An empty fingerprint is returned for instances that were not created from an image.
+func (c *Client) pinInstanceImage(ctx context.Context, name string) (string, error) {
+	instance, etag, err := c.Client.GetInstance(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to GetInstance: %w", err)
+	}
+
+	fingerprint := instance.Config[configBaseImageKey]
+	if fingerprint == "" {
+		return "", nil
+	}
+	if instance.Config[configImageFingerprintKey] == fingerprint {
+		return fingerprint, nil
+	}
+
+	log.FromContext(ctx).V(2).WithValues("fingerprint", fingerprint).Info("Recording instance image fingerprint")
+	put := instance.Writable()
+	put.Config[configImageFingerprintKey] = fingerprint
+	if err := c.wait(ctx, "UpdateInstance", func() (incus.Operation, error) {
+		return c.Client.UpdateInstance(name, put, etag)
+	}); err != nil {
+		return "", err
+	}
+	return fingerprint, nil
+}
+
+// IsLocalImage returns true for image sources without a remote server. These images are not copied, and must already
+// exist on the server.
+func IsLocalImage(image infrav1.LXCMachineImageSource) bool {
+	return image.Server == "" && !strings.HasPrefix(image.Name, "ubuntu:")
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"slices"
-	"strings"
 
 	incus "github.com/lxc/incus/v6/client"
 	"github.com/lxc/incus/v6/shared/api"
@@ -15,8 +14,41 @@ import (
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 )
 
+// BootstrapData is the bootstrap configuration of an instance.
+type BootstrapData struct {
+	// Format is the format of the bootstrap data, BootstrapFormatCloudConfig (default) or BootstrapFormatIgnition.
+	Format string
+	// Data is the bootstrap data of the machine. Cloud-config data is passed to the instance as cloud-init user-data.
+	// Ignition data is passed to virtual machines through the QEMU firmware configuration.
+	Data string
+	// NetworkConfig is the cloud-init network configuration. If set, it replaces the network configuration that
+	// is generated for static addresses. It is only supported with cloud-config bootstrap data.
+	NetworkConfig string
+	// VendorData is the cloud-init vendor-data. It is only supported with cloud-config bootstrap data.
+	VendorData string
+}
+
+// CreateInstanceOptions is the configuration of an instance that is not part of the Machine and LXCMachine objects.
+type CreateInstanceOptions struct {
+	// Image is the image source of the instance, which is the image of the machine spec, or the local copy of the
+	// LXCImage that is referenced by the machine.
+	Image infrav1.LXCMachineImageSource
+	// Bootstrap is the bootstrap configuration of the instance.
+	Bootstrap BootstrapData
+	// StaticAddresses are configured on the network devices of the instance, see StaticAddress.
+	StaticAddresses []StaticAddress
+
+	// extraConfig is added to the instance configuration, and is used to track instances that are not backed by a
+	// LXCMachine.
+	extraConfig map[string]string
+}
+
 // CreateInstance creates the LXC instance based on configuration from the machine.
-func (c *Client) CreateInstance(ctx context.Context, machine *clusterv1.Machine, lxcMachine *infrav1.LXCMachine, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, cloudInit string) ([]string, error) {
+//
+// If the image fingerprint is already set in the LXCMachine status, the instance is created from that fingerprint
+// instead of resolving the image name again. CreateInstance returns the addresses of the instance, and the fingerprint
+// of the image that the instance was created from.
+func (c *Client) CreateInstance(ctx context.Context, machine *clusterv1.Machine, lxcMachine *infrav1.LXCMachine, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, opts CreateInstanceOptions) ([]string, string, error) {
 	ctx, cancel := context.WithTimeout(ctx, instanceCreateTimeout)
 	defer cancel()
 
@@ -37,73 +69,44 @@ func (c *Client) CreateInstance(ctx context.Context, machine *clusterv1.Machine,
 	}
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("profiles", profiles))
 
-	image := lxcMachine.Spec.Image
-
 	// Parse device configurations
-	var devices map[string]map[string]string
-	for _, deviceSpec := range lxcMachine.Spec.Devices {
-		deviceName, deviceArgs, hasSeparator := strings.Cut(deviceSpec, ",")
-		if !hasSeparator {
-			return nil, terminalError{fmt.Errorf("device spec %q is not using the expected %q format", deviceSpec, "<device>,<key>=<value>,<key2>=<value2>")}
-		}
-
-		if devices == nil {
-			devices = map[string]map[string]string{}
-		}
-
-		if _, ok := devices[deviceName]; !ok {
-			devices[deviceName] = map[string]string{}
-		}
-
-		for _, deviceArg := range strings.Split(deviceArgs, ",") {
-			key, value, hasEqual := strings.Cut(deviceArg, "=")
-			if !hasEqual {
-				return nil, terminalError{fmt.Errorf("device argument %q of device spec %q is not using the expected %q format", deviceArg, deviceSpec, "<key>=<value>")}
-			}
-
-			devices[deviceName][key] = value
-		}
+	devices, err := ParseDevices(lxcMachine.Spec.Devices)
+	if err != nil {
+		return nil, "", terminalError{err}
 	}
+	devices = overrideDevices(devices, lxcMachine.Spec.DeviceOverrides)
 
 	// Incus and LXD have diverged image servers for Ubuntu images, making it easy to confuse users.
-	// To address the issue, we allow a special prefix `ubuntu:VERSION` for image names:
-	if strings.HasPrefix(image.Name, "ubuntu:") {
-		server, _, err := c.Client.GetServer()
-		if err != nil {
-			log.FromContext(ctx).Error(err, "Failed to GetServer")
-		} else {
-			switch server.Environment.Server {
-			case "incus":
-				image = infrav1.LXCMachineImageSource{
-					Name:     fmt.Sprintf("ubuntu/%s/cloud", strings.TrimPrefix(image.Name, "ubuntu:")),
-					Server:   "https://images.linuxcontainers.org",
-					Protocol: "simplestreams",
-				}
-				log.FromContext(ctx).V(2).WithValues("image", image).Info("Using Ubuntu image from https://images.linuxcontainers.org")
-			case "lxd":
-				image = infrav1.LXCMachineImageSource{
-					Name:     strings.TrimPrefix(image.Name, "ubuntu:"),
-					Server:   "https://cloud-images.ubuntu.com/releases/",
-					Protocol: "simplestreams",
-				}
-				log.FromContext(ctx).V(2).WithValues("image", image).Info("Using Ubuntu image from https://cloud-images.ubuntu.com/releases/")
-			default:
-				return nil, terminalError{fmt.Errorf("image name is %q, but server is %q. Images with 'ubuntu:' prefix are only allowed for Incus and LXD", image.Name, server.Environment.Server)}
-			}
-		}
+	// To address the issue, we allow a special prefix `ubuntu:VERSION` for image names.
+	image, err := c.resolveUbuntuImage(ctx, opts.Image)
+	if err != nil {
+		return nil, "", err
 	}
-	if image.IsZero() {
+	switch {
+	case lxcMachine.Status.ImageFingerprint != "":
+		// Create the instance from the pinned image fingerprint, so that it uses the exact same image build. The
+		// default image is not looked up again, as the alias may since have been moved or removed upstream.
+		server, protocol := image.Server, image.Protocol
+		if image.IsZero() {
+			server, protocol = defaultSimplestreamsServer, "simplestreams"
+		}
+		image = infrav1.LXCMachineImageSource{
+			Fingerprint: lxcMachine.Status.ImageFingerprint,
+			Server:      server,
+			Protocol:    protocol,
+		}
+	case image.IsZero():
 		if machine.Spec.Version == nil {
-			return nil, terminalError{fmt.Errorf("no image source specified on LXCMachineTemplate and Machine %q does not have a Kubernetes version", machine.Name)}
+			return nil, "", terminalError{fmt.Errorf("no image source specified on LXCMachineTemplate and Machine %q does not have a Kubernetes version", machine.Name)}
 		}
 
 		version := *machine.Spec.Version
 
 		// test if image for version exists on the default simplestreams server, fail otherwise.
 		if ssClient, err := incus.ConnectSimpleStreams(defaultSimplestreamsServer, &incus.ConnectionArgs{}); err != nil {
-			return nil, fmt.Errorf("no image source specified and failed to connect to simplestreams server %q: %w", defaultSimplestreamsServer, err)
+			return nil, "", fmt.Errorf("no image source specified and failed to connect to simplestreams server %q: %w", defaultSimplestreamsServer, err)
 		} else if _, _, err := ssClient.GetImageAliasType(string(instanceType), fmt.Sprintf("kubeadm/%s", version)); err != nil {
-			return nil, terminalError{fmt.Errorf("no image source specified and simplestreams server %q does not provide images for Kubernetes version %q: %w. Please consider using a different Kubernetes version, or build your own base image and set the image source on the LXCMachineTemplate resource", defaultSimplestreamsServer, version, err)}
+			return nil, "", terminalError{fmt.Errorf("no image source specified and simplestreams server %q does not provide images for Kubernetes version %q: %w. Please consider using a different Kubernetes version, or build your own base image and set the image source on the LXCMachineTemplate resource", defaultSimplestreamsServer, version, err)}
 		}
 
 		image = infrav1.LXCMachineImageSource{
@@ -114,6 +117,62 @@ func (c *Client) CreateInstance(ctx context.Context, machine *clusterv1.Machine,
 	}
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("image", image))
 
+	// Place the instance on the cluster member or cluster group that matches the machine failure domain.
+	var target string
+	if machine.Spec.FailureDomain != nil {
+		target = lxcCluster.GetFailureDomainTarget(*machine.Spec.FailureDomain)
+		ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("failureDomain", *machine.Spec.FailureDomain, "target", target))
+	}
+
+	config := map[string]string{
+		configClusterNameKey:      cluster.Name,
+		configClusterNamespaceKey: cluster.Namespace,
+		configInstanceRoleKey:     role,
+	}
+	switch opts.Bootstrap.Format {
+	case "", BootstrapFormatCloudConfig:
+		config[configCloudInitKey] = opts.Bootstrap.Data
+		if opts.Bootstrap.VendorData != "" {
+			config[configCloudInitVendorDataKey] = opts.Bootstrap.VendorData
+		}
+	case BootstrapFormatIgnition:
+		if instanceType != api.InstanceTypeVM {
+			return nil, "", terminalError{fmt.Errorf("ignition bootstrap data is only supported for virtual machines")}
+		}
+		if opts.Bootstrap.NetworkConfig != "" || opts.Bootstrap.VendorData != "" {
+			return nil, "", terminalError{fmt.Errorf("cloud-init network-config and vendor-data are not supported with ignition bootstrap data")}
+		}
+		config[configRawQEMUKey] = ignitionQEMUArgs(opts.Bootstrap.Data)
+	default:
+		return nil, "", terminalError{fmt.Errorf("unsupported bootstrap data format %q", opts.Bootstrap.Format)}
+	}
+	for k, v := range opts.extraConfig {
+		config[k] = v
+	}
+	config, err = overrideConfig(config, lxcMachine.Spec.Config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	devices, networkConfig, err := c.configureStaticAddresses(ctx, profiles, devices, opts.StaticAddresses)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to configure static addresses: %w", err)
+	}
+	if err := validateVolumes(instanceType, lxcMachine.Spec.Volumes); err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case opts.Bootstrap.Format == BootstrapFormatIgnition:
+		if networkConfig != "" {
+			return nil, "", terminalError{fmt.Errorf("static addresses on network devices that are not attached to managed networks require cloud-init")}
+		}
+	case opts.Bootstrap.NetworkConfig != "":
+		config[configCloudInitNetworkConfigKey] = opts.Bootstrap.NetworkConfig
+	case networkConfig != "":
+		config[configCloudInitNetworkConfigKey] = networkConfig
+	}
+
 	if err := c.createInstanceIfNotExists(ctx, api.InstancesPost{
 		Name:         name,
 		Type:         c.instanceTypeFromAPI(lxcMachine.Spec.InstanceType),
@@ -122,29 +181,39 @@ func (c *Client) CreateInstance(ctx context.Context, machine *clusterv1.Machine,
 		InstancePut: api.InstancePut{
 			Profiles: profiles,
 			Devices:  devices,
-			Config: map[string]string{
-				configClusterNameKey:      cluster.Name,
-				configClusterNamespaceKey: cluster.Namespace,
-				configInstanceRoleKey:     role,
-				configCloudInitKey:        cloudInit,
-			},
+			Config:   config,
 		},
-	}); err != nil {
+	}, target); err != nil {
 		// TODO: Handle the below situations as terminalError.
 		//
 		// E1230 21:42:45.170291 1388422 controller.go:316] "Reconciler error" err="failed to create instance: failed to ensure instance exists: failed to wait for CreateInstance operation: Requested image's type \"container\" doesn't match instance type \"virtual-machine\"" controller="lxcmachine" controllerGroup="infrastructure.cluster.x-k8s.io" controllerKind="LXCMachine" LXCMachine="default/c1-control-plane-kprl9" namespace="default" name="c1-control-plane-kprl9" reconcileID="d40dfec7-ce45-4585-9a1e-5974efbeb925"
 		//
 		// E0325 19:38:50.780759       1 controller.go:316] "Reconciler error" err="failed to create instance: failed to ensure instance exists: failed to wait for CreateInstance operation: Failed creating instance from image: Source image size (5368709120) exceeds specified volume size (5000003584)" controller="lxcmachine" controllerGroup="infrastructure.cluster.x-k8s.io" controllerKind="LXCMachine" LXCMachine="quick-start-glpgz4/quick-start-kvm-vdbzv4-md-0-f448v-b5gwd-pxntp" namespace="quick-start-glpgz4" name="quick-start-kvm-vdbzv4-md-0-f448v-b5gwd-pxntp" reconcileID="12fe1bde-889b-412f-abd3-4990f27cbf15"
-		return nil, fmt.Errorf("failed to ensure instance exists: %w", err)
+		return nil, "", fmt.Errorf("failed to ensure instance exists: %w", err)
+	}
+
+	fingerprint, err := c.pinInstanceImage(ctx, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to pin instance image: %w", err)
+	}
+
+	if err := c.ensureVolumes(ctx, name, config, lxcMachine.Spec.Volumes); err != nil {
+		return nil, "", fmt.Errorf("failed to ensure volumes: %w", err)
+	}
+
+	if instanceType == api.InstanceTypeContainer {
+		if err := c.prepareUnprivilegedContainer(ctx, name, lxcCluster.Status.KubeadmProfileMode); err != nil {
+			return nil, "", fmt.Errorf("failed to prepare unprivileged container: %w", err)
+		}
 	}
 
 	if err := c.ensureInstanceRunning(ctx, name); err != nil {
-		return nil, fmt.Errorf("failed to ensure instance is running: %w", err)
+		return nil, "", fmt.Errorf("failed to ensure instance is running: %w", err)
 	}
 
-	addrs, err := c.waitForInstanceAddress(ctx, name)
+	addrs, err := c.waitForInstanceAddress(ctx, name, lxcCluster.Spec.AddressFamily)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get instance address: %w", err)
+		return nil, "", fmt.Errorf("failed to get instance address: %w", err)
 	}
-	return addrs, nil
+	return addrs, fingerprint, nil
 }
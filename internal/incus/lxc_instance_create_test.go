@@ -0,0 +1,45 @@
+package incus
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/ptr"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClient_CreateInstance_PinnedDefaultImage(t *testing.T) {
+	g := NewWithT(t)
+
+	// the default image server is not reachable, so the instance must be created without looking up the alias
+	server := fake.NewServer(fake.WithRemoteImage(defaultSimplestreamsServer, "kubeadm/v1.32.0", "f1"))
+	c := &Client{Client: server}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"}}
+	lxcCluster := &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"},
+		Spec:       infrav1.LXCClusterSpec{SkipDefaultKubeadmProfile: true},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-md-0", Namespace: "default"},
+		Spec:       clusterv1.MachineSpec{Version: ptr.To("v1.32.0")},
+	}
+	lxcMachine := &infrav1.LXCMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-md-0", Namespace: "default"},
+		Status:     infrav1.LXCMachineStatus{ImageFingerprint: "f1"},
+	}
+
+	_, fingerprint, err := c.CreateInstance(context.TODO(), machine, lxcMachine, cluster, lxcCluster, CreateInstanceOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fingerprint).To(Equal("f1"))
+
+	instance, _, err := server.GetInstance(lxcMachine.GetInstanceName())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instance.Config).To(HaveKeyWithValue("volatile.base_image", "f1"))
+}
@@ -2,13 +2,15 @@ package incus
 
 import (
 	"context"
+	"fmt"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
 )
 
-// DeleteInstance deletes the matching LXC instance, if any.
+// DeleteInstance deletes the matching LXC instance, if any. The custom storage volumes of the instance are deleted
+// as well, unless they use the Retain deletion policy.
 func (c *Client) DeleteInstance(ctx context.Context, lxcMachine *infrav1.LXCMachine) error {
 	ctx, cancel := context.WithTimeout(ctx, instanceDeleteTimeout)
 	defer cancel()
@@ -16,5 +18,11 @@ func (c *Client) DeleteInstance(ctx context.Context, lxcMachine *infrav1.LXCMach
 	name := lxcMachine.GetInstanceName()
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name))
 
-	return c.forceRemoveInstanceIfExists(ctx, name)
+	if err := c.forceRemoveInstanceIfExists(ctx, name); err != nil {
+		return err
+	}
+	if err := c.deleteVolumes(ctx, name, lxcMachine.Spec.Volumes); err != nil {
+		return fmt.Errorf("failed to delete volumes: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,90 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ScrubBootstrapData removes the bootstrap data from the configuration of an instance, after bootstrap has completed.
+// The bootstrap data contains secrets (e.g. the kubeadm join token and certificates), which would otherwise be readable
+// from the instance configuration for the lifetime of the instance.
+//
+// For cloud-config bootstrap data, the cloud-init user-data and vendor-data are removed. The cloud-init network-config
+// is kept, as it is still needed to configure the network of the instance. For Ignition bootstrap data, the QEMU
+// arguments that pass the Ignition config to the instance are removed. The QEMU arguments cannot be changed while a
+// virtual machine is running, so they are only removed once the instance is stopped.
+//
+// Removing the cloud-init user-data makes the server generate a new cloud-init instance-id, which would run cloud-init
+// as on first boot (e.g. regenerate the SSH host keys) the next time the instance starts. The instance-id is kept.
+//
+// ScrubBootstrapData returns true once all bootstrap data has been removed. It is a no-op if the bootstrap data has
+// already been removed.
+func (c *Client) ScrubBootstrapData(ctx context.Context, name string) (bool, error) {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name))
+
+	instance, etag, err := c.Client.GetInstance(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to GetInstance: %w", err)
+	}
+
+	put := instance.Writable()
+	var changed, pending bool
+	for _, key := range []string{configCloudInitKey, configCloudInitVendorDataKey, configRawQEMUKey} {
+		if _, ok := put.Config[key]; !ok {
+			continue
+		}
+		if key == configRawQEMUKey && instance.Type == string(api.InstanceTypeVM) && instance.StatusCode != api.Stopped {
+			log.FromContext(ctx).V(2).Info("Instance is running, Ignition config will be removed once it is stopped")
+			pending = true
+			continue
+		}
+		delete(put.Config, key)
+		changed = true
+	}
+	if !changed {
+		log.FromContext(ctx).V(2).Info("Instance has no bootstrap data")
+		return !pending, nil
+	}
+
+	instanceID := instance.Config[configCloudInitInstanceIDKey]
+	if instanceID != "" {
+		put.Config[configCloudInitInstanceIDKey] = instanceID
+	}
+
+	log.FromContext(ctx).V(2).Info("Removing bootstrap data from instance")
+	if err := c.wait(ctx, "UpdateInstance", func() (incus.Operation, error) {
+		return c.Client.UpdateInstance(name, put, etag)
+	}); err != nil {
+		return false, err
+	}
+
+	if instanceID != "" {
+		if err := c.restoreCloudInitInstanceID(ctx, name, instanceID); err != nil {
+			return false, fmt.Errorf("failed to restore cloud-init instance-id: %w", err)
+		}
+	}
+	return !pending, nil
+}
+
+// restoreCloudInitInstanceID sets the cloud-init instance-id of an instance, if the server has generated a new one
+// after a change to the cloud-init configuration. Changing only the instance-id does not generate a new one.
+func (c *Client) restoreCloudInitInstanceID(ctx context.Context, name string, instanceID string) error {
+	instance, etag, err := c.Client.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("failed to GetInstance: %w", err)
+	}
+	if instance.Config[configCloudInitInstanceIDKey] == instanceID {
+		return nil
+	}
+
+	log.FromContext(ctx).V(2).WithValues("instanceID", instanceID).Info("Restoring cloud-init instance-id")
+	put := instance.Writable()
+	put.Config[configCloudInitInstanceIDKey] = instanceID
+	return c.wait(ctx, "UpdateInstance", func() (incus.Operation, error) {
+		return c.Client.UpdateInstance(name, put, etag)
+	})
+}
@@ -0,0 +1,20 @@
+package incus
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// StartInstance starts the matching LXC instance if it is stopped, or unfreezes it if it is frozen.
+func (c *Client) StartInstance(ctx context.Context, lxcMachine *infrav1.LXCMachine) error {
+	ctx, cancel := context.WithTimeout(ctx, instanceStartTimeout)
+	defer cancel()
+
+	name := lxcMachine.GetInstanceName()
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name))
+
+	return c.ensureInstanceRunning(ctx, name)
+}
@@ -0,0 +1,60 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	incus "github.com/lxc/incus/v6/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+var (
+	// unprivilegedKmsgTmpfile links /dev/kmsg to /dev/console, as /dev/kmsg is not available in unprivileged containers
+	// and kubelet fails to start without it.
+	unprivilegedKmsgTmpfile = "L /dev/kmsg - - - - /dev/console\n"
+
+	// unprivilegedKubeletDropIn enables the KubeletInUserNamespace feature gate, so that kubelet ignores errors
+	// when configuring sysctls and resource limits that are not allowed in unprivileged containers.
+	//
+	// KUBELET_EXTRA_ARGS is reserved for users and may be set by an EnvironmentFile of the kubeadm drop-in, so the
+	// feature gate is passed with a separate variable. The command line matches the ExecStart of the kubeadm drop-in
+	// (10-kubeadm.conf), which this drop-in overrides. Multiple --feature-gates flags are merged by kubelet.
+	unprivilegedKubeletDropIn = `[Service]
+Environment="KUBELET_USERNS_ARGS=--feature-gates=KubeletInUserNamespace=true"
+ExecStart=
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS $KUBELET_USERNS_ARGS
+`
+)
+
+// prepareUnprivilegedContainer applies the adjustments required for Kubernetes nodes on unprivileged containers.
+// mode is the kubeadm profile mode of the cluster, and it is a no-op unless it is KubeadmProfileModeUnprivileged.
+// Files are pushed before the instance is started for the first time.
+func (c *Client) prepareUnprivilegedContainer(ctx context.Context, name string, mode string) error {
+	if mode != infrav1.KubeadmProfileModeUnprivileged {
+		return nil
+	}
+
+	log.FromContext(ctx).V(2).Info("Configuring unprivileged container")
+	if err := c.Client.CreateInstanceFile(name, "/etc/systemd/system/kubelet.service.d", incus.InstanceFileArgs{
+		Type: "directory",
+		Mode: 0755,
+	}); err != nil && !strings.Contains(err.Error(), "exists") {
+		return fmt.Errorf("failed to create kubelet drop-in directory: %w", err)
+	}
+	for path, content := range map[string]string{
+		"/etc/tmpfiles.d/cluster-api-lxc-kmsg.conf":                                  unprivilegedKmsgTmpfile,
+		"/etc/systemd/system/kubelet.service.d/20-cluster-api-lxc-unprivileged.conf": unprivilegedKubeletDropIn,
+	} {
+		if err := c.Client.CreateInstanceFile(name, path, incus.InstanceFileArgs{
+			Content:   strings.NewReader(content),
+			Mode:      0644,
+			WriteMode: "overwrite",
+		}); err != nil {
+			return fmt.Errorf("failed to write %q: %w", path, err)
+		}
+	}
+	return nil
+}
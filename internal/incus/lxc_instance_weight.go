@@ -0,0 +1,48 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	incus "github.com/lxc/incus/v6/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// SetLoadBalancerWeight updates the load balancer backend weight of the matching LXC instance.
+// The new weight is used the next time the load balancer is reconfigured. A weight of 0 means
+// that the instance does not receive any new connections.
+//
+// It is a no-op if the instance does not exist.
+func (c *Client) SetLoadBalancerWeight(ctx context.Context, lxcMachine *infrav1.LXCMachine, weight int) error {
+	name := lxcMachine.GetInstanceName()
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name, "weight", weight))
+
+	instance, etag, err := c.Client.GetInstance(name)
+	if err != nil {
+		if strings.Contains(err.Error(), "Instance not found") {
+			log.FromContext(ctx).V(2).Info("Instance does not exist")
+			return nil
+		}
+		return fmt.Errorf("failed to GetInstance: %w", err)
+	}
+
+	if instance.Config[configLoadBalancerWeightKey] == strconv.Itoa(weight) {
+		log.FromContext(ctx).V(2).Info("Instance load balancer weight is up to date")
+		return nil
+	}
+
+	put := instance.Writable()
+	if put.Config == nil {
+		put.Config = map[string]string{}
+	}
+	put.Config[configLoadBalancerWeightKey] = strconv.Itoa(weight)
+
+	log.FromContext(ctx).V(2).Info("Updating instance load balancer weight")
+	return c.wait(ctx, "UpdateInstance", func() (incus.Operation, error) {
+		return c.Client.UpdateInstance(name, put, etag)
+	})
+}
@@ -1,11 +1,17 @@
 package incus
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/netip"
 
+	incus "github.com/lxc/incus/v6/client"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/loadbalancer"
 )
 
 // LoadBalancerManager can be used to interact with the cluster load balancer.
@@ -23,8 +29,30 @@ type LoadBalancerManager interface {
 	Inspect(context.Context) map[string]string
 }
 
+// LoadBalancerOption is an option for LoadBalancerManagerForCluster.
+type LoadBalancerOption func(*loadBalancerOptions)
+
+type loadBalancerOptions struct {
+	configTemplate string
+}
+
+// WithLoadBalancerConfigTemplate sets a custom haproxy configuration template for the "lxc", "oci" and "keepalived"
+// load balancer types. The template must be validated with loadbalancer.ValidateTemplate() by the caller.
+func WithLoadBalancerConfigTemplate(configTemplate string) LoadBalancerOption {
+	return func(o *loadBalancerOptions) {
+		o.configTemplate = configTemplate
+	}
+}
+
 // LoadBalancerManagerForCluster returns the proper LoadBalancerManager based on the lxcCluster spec.
-func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster) LoadBalancerManager {
+func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, opts ...LoadBalancerOption) LoadBalancerManager {
+	var o loadBalancerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	settings := loadBalancerSettingsForCluster(cluster, lxcCluster)
+
 	switch {
 	case lxcCluster.Spec.LoadBalancer.LXC != nil:
 		return &loadBalancerLXC{
@@ -34,6 +62,9 @@ func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCl
 
 			name: lxcCluster.GetLoadBalancerInstanceName(),
 			spec: lxcCluster.Spec.LoadBalancer.LXC.InstanceSpec,
+
+			configTemplate: o.configTemplate,
+			settings:       settings,
 		}
 	case lxcCluster.Spec.LoadBalancer.OCI != nil:
 		return &loadBalancerOCI{
@@ -43,8 +74,32 @@ func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCl
 
 			name: lxcCluster.GetLoadBalancerInstanceName(),
 			spec: lxcCluster.Spec.LoadBalancer.OCI.InstanceSpec,
+
+			configTemplate: o.configTemplate,
+			settings:       settings,
+		}
+	case lxcCluster.Spec.LoadBalancer.Keepalived != nil:
+		return &loadBalancerKeepalived{
+			lxcClient:        c,
+			clusterName:      cluster.Name,
+			clusterNamespace: cluster.Namespace,
+
+			name: lxcCluster.GetLoadBalancerInstanceName(),
+			spec: *lxcCluster.Spec.LoadBalancer.Keepalived,
+
+			virtualIP:      lxcCluster.Spec.ControlPlaneEndpoint.Host,
+			configTemplate: o.configTemplate,
+			settings:       settings,
 		}
 	case lxcCluster.Spec.LoadBalancer.OVN != nil:
+		// network load balancer backends must have the same address family as the listen address
+		if settings.addressFamily == infrav1.AddressFamilyDualStack {
+			if addr, err := netip.ParseAddr(lxcCluster.Spec.ControlPlaneEndpoint.Host); err == nil && addr.Is6() {
+				settings.addressFamily = infrav1.AddressFamilyIPv6
+			} else {
+				settings.addressFamily = infrav1.AddressFamilyIPv4
+			}
+		}
 		return &loadBalancerNetwork{
 			lxcClient:        c,
 			clusterName:      cluster.Name,
@@ -52,6 +107,7 @@ func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCl
 
 			networkName:   lxcCluster.Spec.LoadBalancer.OVN.NetworkName,
 			listenAddress: lxcCluster.Spec.ControlPlaneEndpoint.Host,
+			settings:      settings,
 		}
 	case lxcCluster.Spec.LoadBalancer.External != nil:
 		return &loadBalancerExternal{
@@ -73,3 +129,73 @@ func (c *Client) LoadBalancerManagerForCluster(cluster *clusterv1.Cluster, lxcCl
 		}
 	}
 }
+
+// haproxyConfigTemplate returns the haproxy configuration template to use. If empty, the default template is used.
+func haproxyConfigTemplate(configTemplate string) string {
+	if configTemplate == "" {
+		return loadbalancer.DefaultTemplate
+	}
+	return configTemplate
+}
+
+// writeHaproxyConfig writes the haproxy configuration to configPath on a load balancer instance. The configuration is
+// first written next to configPath and validated with "haproxy -c" on the instance. If validation fails, the existing
+// configuration is kept and an error with the haproxy output is returned.
+func (c *Client) writeHaproxyConfig(ctx context.Context, name string, configPath string, haproxyCfg []byte) error {
+	writeFile := func(path string) error {
+		return c.Client.CreateInstanceFile(name, path, incus.InstanceFileArgs{
+			Content:   bytes.NewReader(haproxyCfg),
+			WriteMode: "overwrite",
+			Type:      "file",
+			Mode:      0440,
+			UID:       0,
+			GID:       0,
+		})
+	}
+
+	candidatePath := configPath + ".new"
+	if err := writeFile(candidatePath); err != nil {
+		return fmt.Errorf("failed to write load balancer config to container: %w", err)
+	}
+
+	log.FromContext(ctx).V(2).WithValues("path", candidatePath).Info("Validate haproxy config")
+	var output bytes.Buffer
+	if err := c.RunCommand(ctx, name, []string{"haproxy", "-c", "-f", candidatePath}, &output, &output); err != nil {
+		return fmt.Errorf("load balancer config is not valid, keeping the previous config: %w (output: %s)", err, output.String())
+	}
+
+	if err := writeFile(configPath); err != nil {
+		return fmt.Errorf("failed to write load balancer config to container: %w", err)
+	}
+	return nil
+}
+
+// loadBalancerSettings is the port and address family configuration of the cluster load balancer.
+type loadBalancerSettings struct {
+	// frontend is the port of the control plane endpoint.
+	frontend int32
+	// backend is the port the kube-apiserver binds to on the control plane instances.
+	backend int32
+	// additional are additional ports forwarded by the load balancer.
+	additional []infrav1.LXCLoadBalancerPort
+	// addressFamily is the address family preference of the cluster, see LXCClusterSpec.AddressFamily.
+	addressFamily string
+}
+
+// loadBalancerSettingsForCluster returns the configuration of the cluster load balancer. The control plane endpoint
+// port defaults to 6443. The kube-apiserver port is taken from the cluster network configuration, and defaults to 6443.
+func loadBalancerSettingsForCluster(cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster) loadBalancerSettings {
+	settings := loadBalancerSettings{
+		frontend:      lxcCluster.Spec.ControlPlaneEndpoint.Port,
+		backend:       6443,
+		additional:    lxcCluster.Spec.AdditionalLoadBalancerPorts,
+		addressFamily: lxcCluster.Spec.AddressFamily,
+	}
+	if settings.frontend == 0 {
+		settings.frontend = 6443
+	}
+	if cluster.Spec.ClusterNetwork != nil && cluster.Spec.ClusterNetwork.APIServerPort != nil {
+		settings.backend = *cluster.Spec.ClusterNetwork.APIServerPort
+	}
+	return settings
+}
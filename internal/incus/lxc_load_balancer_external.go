@@ -9,7 +9,7 @@ import (
 
 // loadBalancerExternal is a no-op LoadBalancerManager when using an external LoadBalancer mechanism for the cluster (e.g. kube-vip).
 //
-// TODO: extend to support automatically finding an available VIP from an address range (so that we don't have to statically assign kube-vips).
+// The address is either configured statically, or allocated from the address pool of the cluster by the LXCCluster controller.
 type loadBalancerExternal struct {
 	lxcClient *Client
 
@@ -0,0 +1,285 @@
+package incus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"slices"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/loadbalancer"
+)
+
+// loadBalancerKeepalived is a LoadBalancerManager that spins up multiple haproxy LXC containers, and uses keepalived to share a virtual IP address between them.
+type loadBalancerKeepalived struct {
+	lxcClient *Client
+
+	clusterName      string
+	clusterNamespace string
+
+	name string
+	spec infrav1.LXCLoadBalancerKeepalived
+
+	virtualIP string
+
+	// configTemplate is an optional custom haproxy configuration template.
+	configTemplate string
+	// settings is the port and address family configuration of the load balancer.
+	settings loadBalancerSettings
+}
+
+// replicas returns the loadBalancerLXC instances that host haproxy and keepalived.
+// targets is an optional list of cluster members that the instances are spread across.
+func (l *loadBalancerKeepalived) replicas(targets []string) []*loadBalancerLXC {
+	count := int(l.spec.Replicas)
+	if count < 2 {
+		count = 2
+	}
+
+	replicas := make([]*loadBalancerLXC, 0, count)
+	for i := range count {
+		replica := &loadBalancerLXC{
+			lxcClient:        l.lxcClient,
+			clusterName:      l.clusterName,
+			clusterNamespace: l.clusterNamespace,
+
+			name: fmt.Sprintf("%s-%d", l.name, i),
+			spec: l.spec.InstanceSpec,
+
+			configTemplate: l.configTemplate,
+			settings:       l.settings,
+		}
+		if len(targets) > 0 {
+			replica.target = targets[i%len(targets)]
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
+
+// virtualRouterID returns the VRRP virtual router ID. If not set, it is derived from the cluster name.
+func (l *loadBalancerKeepalived) virtualRouterID() int32 {
+	if l.spec.VirtualRouterID != 0 {
+		return l.spec.VirtualRouterID
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s/%s", l.clusterNamespace, l.clusterName)))
+	return int32(h.Sum32()%255) + 1
+}
+
+// Create implements loadBalancerManager.
+func (l *loadBalancerKeepalived) Create(ctx context.Context) ([]string, error) {
+	// spread the replicas across the online cluster members, if the server is clustered
+	targets, err := l.lxcClient.GetOnlineClusterMembers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster members: %w", err)
+	}
+
+	replicas := l.replicas(targets)
+	replicaNames := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		if _, err := replica.Create(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create load balancer instance %q: %w", replica.name, err)
+		}
+		replicaNames = append(replicaNames, replica.name)
+	}
+
+	// remove load balancer instances that are no longer needed (e.g. after reducing the number of replicas)
+	instances, err := l.lxcClient.getInstancesWithFilter(ctx, api.InstanceTypeAny, map[string]string{
+		configClusterNameKey:      l.clusterName,
+		configClusterNamespaceKey: l.clusterNamespace,
+		configInstanceRoleKey:     "loadbalancer",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list load balancer instances: %w", err)
+	}
+	for _, instance := range instances {
+		if slices.Contains(replicaNames, instance.Name) {
+			continue
+		}
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", instance.Name))
+		log.FromContext(ctx).Info("Removing extra load balancer instance")
+		if err := l.lxcClient.forceRemoveInstanceIfExists(ctx, instance.Name); err != nil {
+			return nil, fmt.Errorf("failed to remove load balancer instance %q: %w", instance.Name, err)
+		}
+	}
+
+	if err := l.reconfigureKeepalived(ctx, replicas); err != nil {
+		return nil, fmt.Errorf("failed to configure keepalived: %w", err)
+	}
+
+	return []string{l.virtualIP}, nil
+}
+
+// Delete implements loadBalancerManager.
+func (l *loadBalancerKeepalived) Delete(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, loadBalancerDeleteTimeout)
+	defer cancel()
+
+	instances, err := l.lxcClient.getInstancesWithFilter(ctx, api.InstanceTypeAny, map[string]string{
+		configClusterNameKey:      l.clusterName,
+		configClusterNamespaceKey: l.clusterNamespace,
+		configInstanceRoleKey:     "loadbalancer",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list load balancer instances: %w", err)
+	}
+
+	for _, instance := range instances {
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", instance.Name))
+		if err := l.lxcClient.forceRemoveInstanceIfExists(ctx, instance.Name); err != nil {
+			return fmt.Errorf("failed to remove load balancer instance %q: %w", instance.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Reconfigure implements loadBalancerManager.
+func (l *loadBalancerKeepalived) Reconfigure(ctx context.Context) error {
+	replicas := l.replicas(nil)
+	for _, replica := range replicas {
+		if err := replica.Reconfigure(ctx); err != nil {
+			return fmt.Errorf("failed to reconfigure load balancer instance %q: %w", replica.name, err)
+		}
+	}
+
+	if err := l.reconfigureKeepalived(ctx, replicas); err != nil {
+		return fmt.Errorf("failed to configure keepalived: %w", err)
+	}
+
+	return nil
+}
+
+// reconfigureKeepalived writes the keepalived configuration on all load balancer instances and reloads the service.
+// Instances that already run with the expected configuration are not changed.
+func (l *loadBalancerKeepalived) reconfigureKeepalived(ctx context.Context, replicas []*loadBalancerLXC) error {
+	ctx, cancel := context.WithTimeout(ctx, loadBalancerReconfigureTimeout)
+	defer cancel()
+
+	// use addresses from the same family as the virtual IP for unicast VRRP advertisements
+	isIPv4 := net.ParseIP(l.virtualIP).To4() != nil
+	addresses := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		state, _, err := l.lxcClient.Client.GetInstanceState(replica.name)
+		if err != nil {
+			return fmt.Errorf("failed to GetInstanceState of %q: %w", replica.name, err)
+		}
+		var address string
+		for _, addr := range l.lxcClient.ParseActiveMachineAddresses(state) {
+			if ip := net.ParseIP(addr); ip != nil && (ip.To4() != nil) == isIPv4 {
+				address = addr
+				break
+			}
+		}
+		if address == "" {
+			return fmt.Errorf("load balancer instance %q has no address in the same family as %q", replica.name, l.virtualIP)
+		}
+		addresses = append(addresses, address)
+	}
+
+	iface := l.spec.Interface
+	if iface == "" {
+		iface = "eth0"
+	}
+
+	for idx, replica := range replicas {
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", replica.name))
+
+		config := &loadbalancer.KeepalivedConfigData{
+			Interface:       iface,
+			VirtualRouterID: l.virtualRouterID(),
+			Priority:        150 - idx,
+			VirtualIP:       l.virtualIP,
+			UnicastSrcIP:    addresses[idx],
+			UnicastPeers:    slices.Delete(slices.Clone(addresses), idx, idx+1),
+		}
+
+		keepalivedCfg, err := loadbalancer.KeepalivedConfig(config, loadbalancer.DefaultKeepalivedTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to render keepalived config: %w", err)
+		}
+		if l.keepalivedConfigured(ctx, replica.name, keepalivedCfg) {
+			log.FromContext(ctx).V(2).Info("Keepalived config is up to date")
+			continue
+		}
+		log.FromContext(ctx).V(2).WithValues("path", keepalivedConfigPath, "priority", config.Priority, "peers", config.UnicastPeers).Info("Write keepalived config")
+		if err := l.lxcClient.Client.CreateInstanceFile(replica.name, keepalivedConfigPath, incus.InstanceFileArgs{
+			Content:   bytes.NewReader(keepalivedCfg),
+			WriteMode: "overwrite",
+			Type:      "file",
+			Mode:      0440,
+			UID:       0,
+			GID:       0,
+		}); err != nil {
+			return fmt.Errorf("failed to write keepalived config to %q: %w", replica.name, err)
+		}
+
+		log.FromContext(ctx).V(2).Info("Reloading keepalived service")
+		for _, command := range [][]string{
+			{"systemctl", "enable", "keepalived.service"},
+			{"systemctl", "reload-or-restart", "keepalived.service"},
+		} {
+			var stderr bytes.Buffer
+			if err := l.lxcClient.RunCommand(ctx, replica.name, command, io.Discard, &stderr); err != nil {
+				return fmt.Errorf("failed to run %v on %q: %w (stderr: %s)", command, replica.name, err, stderr.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// keepalivedConfigured checks whether the keepalived config on a load balancer instance matches the expected config,
+// and the keepalived service is active. Any error while checking is treated as the instance not being configured.
+func (l *loadBalancerKeepalived) keepalivedConfigured(ctx context.Context, name string, keepalivedCfg []byte) bool {
+	reader, _, err := l.lxcClient.Client.GetInstanceFile(name, keepalivedConfigPath)
+	if err != nil || reader == nil {
+		return false
+	}
+	defer func() { _ = reader.Close() }()
+	if b, err := io.ReadAll(reader); err != nil || !bytes.Equal(b, keepalivedCfg) {
+		return false
+	}
+
+	// the service may have failed to start after a previous write of the same config
+	return l.lxcClient.RunCommand(ctx, name, []string{"systemctl", "is-active", "--quiet", "keepalived.service"}, io.Discard, io.Discard) == nil
+}
+
+// Inspect implements loadBalancerManager.
+func (l *loadBalancerKeepalived) Inspect(ctx context.Context) map[string]string {
+	result := map[string]string{}
+
+	for _, replica := range l.replicas(nil) {
+		for k, v := range replica.Inspect(ctx) {
+			result[fmt.Sprintf("%s-%s", replica.name, k)] = v
+		}
+
+		for _, item := range []struct {
+			name    string
+			command []string
+		}{
+			{name: "keepalived.service", command: []string{"systemctl", "status", "--no-pager", "-l", "keepalived.service"}},
+			{name: "keepalived.log", command: []string{"journalctl", "--no-pager", "-u", "keepalived.service"}},
+			{name: "keepalived.conf", command: []string{"cat", keepalivedConfigPath}},
+		} {
+			var stdout, stderr bytes.Buffer
+			if err := l.lxcClient.RunCommand(ctx, replica.name, item.command, &stdout, &stderr); err != nil {
+				result[fmt.Sprintf("%s-%s.error", replica.name, item.name)] = fmt.Errorf("failed to RunCommand %v on %s: %w", item.command, replica.name, err).Error()
+			}
+			result[fmt.Sprintf("%s-%s", replica.name, item.name)] = fmt.Sprintf("%s\n%s\n", stdout.String(), stderr.String())
+		}
+	}
+
+	return result
+}
+
+var _ LoadBalancerManager = &loadBalancerKeepalived{}
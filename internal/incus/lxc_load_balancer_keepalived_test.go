@@ -0,0 +1,161 @@
+package incus
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lxc/incus/v6/shared/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus/fake"
+
+	. "github.com/onsi/gomega"
+)
+
+func readInstanceFile(g *WithT, server *fake.Server, instanceName string, path string) string {
+	reader, _, err := server.GetInstanceFile(instanceName, path)
+	g.Expect(err).ToNot(HaveOccurred())
+	b, err := io.ReadAll(reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	return string(b)
+}
+
+func TestLoadBalancerKeepalived(t *testing.T) {
+	ctx := context.TODO()
+
+	server := fake.NewServer(fake.WithClusterMembers(
+		api.ClusterMember{ServerName: "w02", Status: "Online"},
+		api.ClusterMember{ServerName: "w01", Status: "Online"},
+		api.ClusterMember{ServerName: "w03", Status: "Offline"},
+	))
+	lxcClient := &Client{Client: server}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"}}
+	lxcCluster := &infrav1.LXCCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "default"},
+		Spec: infrav1.LXCClusterSpec{
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.100.42.1", Port: 6443},
+			LoadBalancer:         infrav1.LXCClusterLoadBalancer{Keepalived: &infrav1.LXCLoadBalancerKeepalived{Replicas: 3}},
+		},
+	}
+	lbName := lxcCluster.GetLoadBalancerInstanceName()
+	replicaNames := []string{lbName + "-0", lbName + "-1", lbName + "-2"}
+
+	var commands [][]string
+	server.ExecHandler = func(instanceName string, command []string, stdout io.Writer, stderr io.Writer) int {
+		commands = append(commands, append([]string{instanceName}, command...))
+		return 0
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lbAddresses, err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lbAddresses).To(Equal([]string{"10.100.42.1"}))
+		g.Expect(server.InstanceNames()).To(Equal(replicaNames))
+
+		// replicas are spread across the online cluster members
+		var locations []string
+		for _, name := range replicaNames {
+			instance, _, err := server.GetInstance(name)
+			g.Expect(err).ToNot(HaveOccurred())
+			locations = append(locations, instance.Location)
+		}
+		g.Expect(locations).To(Equal([]string{"w01", "w02", "w01"}))
+
+		// the virtual router ID is derived from the cluster name
+		h := fnv.New32a()
+		_, _ = h.Write([]byte("default/c1"))
+		vrid := h.Sum32()%255 + 1
+
+		addresses := make([]string, 0, len(replicaNames))
+		for _, name := range replicaNames {
+			state, _, err := server.GetInstanceState(name)
+			g.Expect(err).ToNot(HaveOccurred())
+			addresses = append(addresses, lxcClient.ParseActiveMachineAddresses(state)[0])
+		}
+		for idx, name := range replicaNames {
+			config := readInstanceFile(g, server, name, "/etc/keepalived/keepalived.conf")
+			g.Expect(config).To(ContainSubstring("virtual_router_id %d\n", vrid))
+			g.Expect(config).To(ContainSubstring("priority %d\n", 150-idx))
+			g.Expect(config).To(ContainSubstring("unicast_src_ip %s\n", addresses[idx]))
+			for peerIdx, peer := range addresses {
+				if peerIdx == idx {
+					g.Expect(config).ToNot(ContainSubstring("    %s\n", peer))
+				} else {
+					g.Expect(config).To(ContainSubstring("    %s\n", peer))
+				}
+			}
+			g.Expect(config).To(ContainSubstring("virtual_ipaddress {\n    10.100.42.1\n"))
+		}
+		for _, name := range replicaNames {
+			g.Expect(commands).To(ContainElement([]string{name, "systemctl", "reload-or-restart", "keepalived.service"}))
+		}
+	})
+
+	t.Run("ReconfigureUnchanged", func(t *testing.T) {
+		g := NewWithT(t)
+
+		commands = nil
+		g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx)).To(Succeed())
+		for _, name := range replicaNames {
+			g.Expect(commands).To(ContainElement([]string{name, "systemctl", "is-active", "--quiet", "keepalived.service"}))
+		}
+		g.Expect(commands).ToNot(ContainElement(ContainElement("reload-or-restart")))
+	})
+
+	t.Run("ReconfigureVirtualRouterID", func(t *testing.T) {
+		g := NewWithT(t)
+
+		commands = nil
+		lxcCluster.Spec.LoadBalancer.Keepalived.VirtualRouterID = 42
+		g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx)).To(Succeed())
+		for _, name := range replicaNames {
+			g.Expect(readInstanceFile(g, server, name, "/etc/keepalived/keepalived.conf")).To(ContainSubstring("virtual_router_id 42\n"))
+			g.Expect(commands).To(ContainElement([]string{name, "systemctl", "reload-or-restart", "keepalived.service"}))
+		}
+	})
+
+	t.Run("ServiceInactive", func(t *testing.T) {
+		g := NewWithT(t)
+
+		commands = nil
+		server.ExecHandler = func(instanceName string, command []string, stdout io.Writer, stderr io.Writer) int {
+			commands = append(commands, append([]string{instanceName}, command...))
+			if instanceName == replicaNames[1] && slices.Contains(command, "is-active") {
+				return 3
+			}
+			return 0
+		}
+		g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Reconfigure(ctx)).To(Succeed())
+		g.Expect(commands).To(ContainElement([]string{replicaNames[1], "systemctl", "reload-or-restart", "keepalived.service"}))
+		g.Expect(commands).ToNot(ContainElement([]string{replicaNames[0], "systemctl", "reload-or-restart", "keepalived.service"}))
+		g.Expect(commands).ToNot(ContainElement([]string{replicaNames[2], "systemctl", "reload-or-restart", "keepalived.service"}))
+	})
+
+	t.Run("RemoveExtraReplicas", func(t *testing.T) {
+		g := NewWithT(t)
+
+		lxcCluster.Spec.LoadBalancer.Keepalived.Replicas = 2
+		_, err := lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Create(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(server.InstanceNames()).To(Equal(replicaNames[:2]))
+
+		// the remaining replicas only advertise to each other
+		config := readInstanceFile(g, server, replicaNames[0], "/etc/keepalived/keepalived.conf")
+		g.Expect(strings.Count(config, "    10.0.")).To(Equal(1))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(lxcClient.LoadBalancerManagerForCluster(cluster, lxcCluster).Delete(ctx)).To(Succeed())
+		g.Expect(server.InstanceNames()).To(BeEmpty())
+	})
+}
@@ -23,6 +23,14 @@ type loadBalancerLXC struct {
 
 	name string
 	spec infrav1.LXCLoadBalancerMachineSpec
+
+	// configTemplate is an optional custom haproxy configuration template.
+	configTemplate string
+	// settings is the port and address family configuration of the load balancer.
+	settings loadBalancerSettings
+
+	// target is an optional cluster member to create the instance on.
+	target string
 }
 
 // Create implements loadBalancerManager.
@@ -44,6 +52,15 @@ func (l *loadBalancerLXC) Create(ctx context.Context) ([]string, error) {
 
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("image", image))
 
+	instanceConfig, err := overrideConfig(map[string]string{
+		configClusterNameKey:      l.clusterName,
+		configClusterNamespaceKey: l.clusterNamespace,
+		configInstanceRoleKey:     "loadbalancer",
+	}, l.spec.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := l.lxcClient.createInstanceIfNotExists(ctx, api.InstancesPost{
 		Name:         l.name,
 		Type:         api.InstanceTypeContainer,
@@ -51,13 +68,10 @@ func (l *loadBalancerLXC) Create(ctx context.Context) ([]string, error) {
 		InstanceType: l.spec.Flavor,
 		InstancePut: api.InstancePut{
 			Profiles: l.spec.Profiles,
-			Config: map[string]string{
-				configClusterNameKey:      l.clusterName,
-				configClusterNamespaceKey: l.clusterNamespace,
-				configInstanceRoleKey:     "loadbalancer",
-			},
+			Devices:  overrideDevices(nil, l.spec.DeviceOverrides),
+			Config:   instanceConfig,
 		},
-	}); err != nil {
+	}, l.target); err != nil {
 		return nil, fmt.Errorf("failed to ensure loadbalancer instance exists: %w", err)
 	}
 
@@ -65,7 +79,7 @@ func (l *loadBalancerLXC) Create(ctx context.Context) ([]string, error) {
 		return nil, fmt.Errorf("failed to ensure loadbalancer instance is running: %w", err)
 	}
 
-	addrs, err := l.lxcClient.waitForInstanceAddress(ctx, l.name)
+	addrs, err := l.lxcClient.waitForInstanceAddress(ctx, l.name, l.settings.addressFamily)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loadbalancer instance address: %w", err)
 	}
@@ -90,25 +104,18 @@ func (l *loadBalancerLXC) Reconfigure(ctx context.Context) error {
 
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", l.name))
 
-	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace)
+	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace, l.settings)
 	if err != nil {
 		return fmt.Errorf("failed to build load balancer configuration: %w", err)
 	}
 
-	haproxyCfg, err := loadbalancer.Config(config, loadbalancer.DefaultTemplate)
+	haproxyCfg, err := loadbalancer.Config(config, haproxyConfigTemplate(l.configTemplate))
 	if err != nil {
 		return fmt.Errorf("failed to render load balancer config: %w", err)
 	}
 	log.FromContext(ctx).V(2).WithValues("path", "/etc/haproxy/haproxy.cfg", "servers", config.BackendServers).Info("Write haproxy config")
-	if err := l.lxcClient.Client.CreateInstanceFile(l.name, "/etc/haproxy/haproxy.cfg", incus.InstanceFileArgs{
-		Content:   bytes.NewReader(haproxyCfg),
-		WriteMode: "overwrite",
-		Type:      "file",
-		Mode:      0440,
-		UID:       0,
-		GID:       0,
-	}); err != nil {
-		return fmt.Errorf("failed to write load balancer config to container: %w", err)
+	if err := l.lxcClient.writeHaproxyConfig(ctx, l.name, "/etc/haproxy/haproxy.cfg", haproxyCfg); err != nil {
+		return err
 	}
 
 	log.FromContext(ctx).V(2).Info("Reloading haproxy service")
@@ -3,6 +3,7 @@ package incus
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/lxc/incus/v6/shared/api"
@@ -20,6 +21,9 @@ type loadBalancerNetwork struct {
 
 	networkName   string
 	listenAddress string
+
+	// settings is the port and address family configuration of the load balancer.
+	settings loadBalancerSettings
 }
 
 // Create implements loadBalancerManager.
@@ -87,7 +91,7 @@ func (l *loadBalancerNetwork) Reconfigure(ctx context.Context) error {
 
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("networkName", l.networkName, "listenAddress", l.listenAddress))
 
-	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace)
+	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace, l.settings)
 	if err != nil {
 		return fmt.Errorf("failed to build load balancer configuration: %w", err)
 	}
@@ -114,6 +118,12 @@ func (l *loadBalancerNetwork) Reconfigure(ctx context.Context) error {
 		}},
 	}
 	for name, backend := range config.BackendServers {
+		// network load balancers do not support weights, remove backends that are being drained
+		if backend.Weight == 0 {
+			log.FromContext(ctx).V(2).WithValues("backend", name).Info("Skipping backend with zero weight")
+			continue
+		}
+
 		lbConfig.Backends = append(lbConfig.Backends, api.NetworkLoadBalancerBackend{
 			Name:          name,
 			TargetPort:    config.BackendControlPlanePort,
@@ -123,6 +133,35 @@ func (l *loadBalancerNetwork) Reconfigure(ctx context.Context) error {
 		lbConfig.Ports[0].TargetBackend = append(lbConfig.Ports[0].TargetBackend, name)
 	}
 
+	// network load balancer backends have a single target port, so separate backends are created for each additional port
+	for _, port := range config.AdditionalPorts {
+		lbPort := api.NetworkLoadBalancerPort{
+			Description:   port.Name,
+			ListenPort:    port.FrontendPort,
+			Protocol:      "tcp",
+			TargetBackend: make([]string, 0, len(port.BackendServers)),
+		}
+		for name, backend := range port.BackendServers {
+			if backend.Weight == 0 {
+				continue
+			}
+
+			backendName := fmt.Sprintf("%s-%s", name, port.Name)
+			lbConfig.Backends = append(lbConfig.Backends, api.NetworkLoadBalancerBackend{
+				Name:          backendName,
+				TargetPort:    port.BackendPort,
+				TargetAddress: backend.Address,
+			})
+			lbPort.TargetBackend = append(lbPort.TargetBackend, backendName)
+		}
+		lbConfig.Ports = append(lbConfig.Ports, lbPort)
+	}
+
+	// skip ports without any backends (e.g. no worker instances yet), as they are not accepted by the server
+	lbConfig.Ports = slices.DeleteFunc(lbConfig.Ports, func(port api.NetworkLoadBalancerPort) bool {
+		return len(port.TargetBackend) == 0
+	})
+
 	if err := l.lxcClient.Client.UpdateNetworkLoadBalancer(l.networkName, l.listenAddress, lbConfig, ""); err != nil {
 		return fmt.Errorf("failed to UpdateNetworkLoadBalancer: %w", err)
 	}
@@ -1,7 +1,6 @@
 package incus
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -25,6 +24,11 @@ type loadBalancerOCI struct {
 
 	name string
 	spec infrav1.LXCLoadBalancerMachineSpec
+
+	// configTemplate is an optional custom haproxy configuration template.
+	configTemplate string
+	// settings is the port and address family configuration of the load balancer.
+	settings loadBalancerSettings
 }
 
 // Create implements loadBalancerManager.
@@ -47,6 +51,15 @@ func (l *loadBalancerOCI) Create(ctx context.Context) ([]string, error) {
 		}
 	}
 
+	instanceConfig, err := overrideConfig(map[string]string{
+		configClusterNameKey:      l.clusterName,
+		configClusterNamespaceKey: l.clusterNamespace,
+		configInstanceRoleKey:     "loadbalancer",
+	}, l.spec.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := l.lxcClient.createInstanceIfNotExists(ctx, api.InstancesPost{
 		Name:         l.name,
 		Type:         api.InstanceTypeContainer, // instance type must be Container for OCI containers.
@@ -54,13 +67,10 @@ func (l *loadBalancerOCI) Create(ctx context.Context) ([]string, error) {
 		InstanceType: l.spec.Flavor,
 		InstancePut: api.InstancePut{
 			Profiles: l.spec.Profiles,
-			Config: map[string]string{
-				configClusterNameKey:      l.clusterName,
-				configClusterNamespaceKey: l.clusterNamespace,
-				configInstanceRoleKey:     "loadbalancer",
-			},
+			Devices:  overrideDevices(nil, l.spec.DeviceOverrides),
+			Config:   instanceConfig,
 		},
-	}); err != nil {
+	}, ""); err != nil {
 		return nil, fmt.Errorf("failed to ensure loadbalancer instance exists: %w", err)
 	}
 
@@ -68,7 +78,7 @@ func (l *loadBalancerOCI) Create(ctx context.Context) ([]string, error) {
 		return nil, fmt.Errorf("failed to ensure loadbalancer instance is running: %w", err)
 	}
 
-	addrs, err := l.lxcClient.waitForInstanceAddress(ctx, l.name)
+	addrs, err := l.lxcClient.waitForInstanceAddress(ctx, l.name, l.settings.addressFamily)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get loadbalancer instance address: %w", err)
 	}
@@ -92,31 +102,24 @@ func (l *loadBalancerOCI) Reconfigure(ctx context.Context) error {
 
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", l.name))
 
-	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace)
+	config, err := l.lxcClient.getLoadBalancerConfiguration(ctx, l.clusterName, l.clusterNamespace, l.settings)
 	if err != nil {
 		return fmt.Errorf("failed to build load balancer configuration: %w", err)
 	}
 
-	haproxyCfg, err := loadbalancer.Config(config, loadbalancer.DefaultTemplate)
+	haproxyCfg, err := loadbalancer.Config(config, haproxyConfigTemplate(l.configTemplate))
 	if err != nil {
 		return fmt.Errorf("failed to render load balancer config: %w", err)
 	}
-	log.FromContext(ctx).V(2).WithValues("path", "/usr/local/etc/haproxy/haproxy.cfg", "servers", config.BackendServers).Info("Write haproxy config")
-	if err := l.lxcClient.Client.CreateInstanceFile(l.name, "/usr/local/etc/haproxy/haproxy.cfg", incus.InstanceFileArgs{
-		Content:   bytes.NewReader(haproxyCfg),
-		WriteMode: "overwrite",
-		Type:      "file",
-		Mode:      0440,
-		UID:       0,
-		GID:       0,
-	}); err != nil {
-		return fmt.Errorf("failed to write load balancer config to container: %w", err)
-	}
-
 	if err := l.lxcClient.ensureInstanceRunning(ctx, l.name); err != nil {
 		return fmt.Errorf("failed to ensure load balancer is running: %w", err)
 	}
 
+	log.FromContext(ctx).V(2).WithValues("path", "/usr/local/etc/haproxy/haproxy.cfg", "servers", config.BackendServers).Info("Write haproxy config")
+	if err := l.lxcClient.writeHaproxyConfig(ctx, l.name, "/usr/local/etc/haproxy/haproxy.cfg", haproxyCfg); err != nil {
+		return err
+	}
+
 	// NOTE(neoaggelos): lxc will silence signals to the init process from the same namespace
 	// https://github.com/lxc/lxc/pull/4503/files#diff-bf8397458e8edecf47bdd0021167704c859cdd088fd7afdf0b56d289cf87a54fR430-R434
 	//
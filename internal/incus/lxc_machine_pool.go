@@ -0,0 +1,60 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lxc/incus/v6/shared/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// CreateMachinePoolInstance creates an LXC instance for a machine pool, based on the LXCMachinePool template.
+// Machine pool instances are not backed by a Machine or LXCMachine, and are tracked through the instance configuration instead.
+func (c *Client) CreateMachinePoolInstance(ctx context.Context, name string, machinePool *expv1.MachinePool, lxcMachinePool *infrav1.LXCMachinePool, cluster *clusterv1.Cluster, lxcCluster *infrav1.LXCCluster, failureDomain *string, opts CreateInstanceOptions) ([]string, error) {
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: machinePool.Namespace},
+		Spec: clusterv1.MachineSpec{
+			ClusterName:   cluster.Name,
+			Version:       machinePool.Spec.Template.Spec.Version,
+			FailureDomain: failureDomain,
+		},
+	}
+	lxcMachine := &infrav1.LXCMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: lxcMachinePool.Namespace},
+		Spec:       *lxcMachinePool.Spec.Template.DeepCopy(),
+	}
+
+	opts.extraConfig = map[string]string{
+		configMachinePoolKey: lxcMachinePool.Name,
+	}
+	addrs, _, err := c.CreateInstance(ctx, machine, lxcMachine, cluster, lxcCluster, opts)
+	return addrs, err
+}
+
+// GetMachinePoolInstances returns the list of LXC instances of a machine pool.
+func (c *Client) GetMachinePoolInstances(ctx context.Context, cluster *clusterv1.Cluster, lxcMachinePool *infrav1.LXCMachinePool) ([]api.InstanceFull, error) {
+	instances, err := c.getInstancesWithFilter(ctx, api.InstanceTypeAny, map[string]string{
+		configClusterNameKey:      cluster.Name,
+		configClusterNamespaceKey: cluster.Namespace,
+		configMachinePoolKey:      lxcMachinePool.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve machine pool instances: %w", err)
+	}
+	return instances, nil
+}
+
+// DeleteMachinePoolInstance deletes an LXC instance of a machine pool, if it exists.
+func (c *Client) DeleteMachinePoolInstance(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, instanceDeleteTimeout)
+	defer cancel()
+
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("instance", name))
+
+	return c.forceRemoveInstanceIfExists(ctx, name)
+}
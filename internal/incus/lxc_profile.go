@@ -4,6 +4,8 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/lxc/incus/v6/shared/api"
@@ -33,6 +35,61 @@ func (c *Client) InitProfile(ctx context.Context, profile api.ProfilesPost) erro
 	return nil
 }
 
+// DiffProfile compares an existing LXC profile against the expected configuration. It returns a sorted list of the
+// config keys and devices that differ, e.g. `config["security.nesting"]` or `devices["kmsg"]`. An empty list means
+// that the profile is in sync.
+func (c *Client) DiffProfile(ctx context.Context, profileName string, expected api.ProfilePut) ([]string, error) {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("profileName", profileName))
+
+	profile, _, err := c.Client.GetProfile(profileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetProfile: %w", err)
+	}
+
+	var diff []string
+	for key := range keysOf(profile.Config, expected.Config) {
+		if profile.Config[key] != expected.Config[key] {
+			diff = append(diff, fmt.Sprintf("config[%q]", key))
+		}
+	}
+	for name := range keysOf(profile.Devices, expected.Devices) {
+		if !maps.Equal(profile.Devices[name], expected.Devices[name]) {
+			diff = append(diff, fmt.Sprintf("devices[%q]", name))
+		}
+	}
+	slices.Sort(diff)
+
+	log.FromContext(ctx).V(2).WithValues("diff", diff).Info("Compared profile with expected configuration")
+	return diff, nil
+}
+
+// UpdateProfile replaces the configuration of an existing LXC profile.
+func (c *Client) UpdateProfile(ctx context.Context, profileName string, profile api.ProfilePut) error {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("profileName", profileName))
+
+	if err := c.Client.UpdateProfile(profileName, profile, ""); err != nil {
+		if strings.Contains(err.Error(), "Privileged containers are forbidden") {
+			return terminalError{err}
+		}
+		return fmt.Errorf("failed to UpdateProfile: %w", err)
+	}
+
+	log.FromContext(ctx).V(2).Info("Successfully updated profile")
+	return nil
+}
+
+// keysOf returns the union of the keys of two maps.
+func keysOf[V any](a, b map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
 // DeleteProfile deletes an LXC profile if it exists.
 func (c *Client) DeleteProfile(ctx context.Context, profileName string) error {
 	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("profileName", profileName))
@@ -0,0 +1,152 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StaticAddress is a static address for a network device of the instance, e.g. allocated from an IPAM pool.
+type StaticAddress struct {
+	// Device is the name of the network device of the instance.
+	Device string
+	// Address is the IPv4 or IPv6 address.
+	Address string
+	// Prefix is the prefix length of the address.
+	Prefix int
+	// Gateway is the default gateway. It is only used if the address is configured through cloud-init.
+	Gateway string
+}
+
+// networkConfig is a cloud-init network configuration (version 2).
+type networkConfig struct {
+	Version   int                               `yaml:"version"`
+	Ethernets map[string]*networkConfigEthernet `yaml:"ethernets"`
+}
+
+type networkConfigEthernet struct {
+	DHCP4     bool                 `yaml:"dhcp4,omitempty"`
+	DHCP6     bool                 `yaml:"dhcp6,omitempty"`
+	Addresses []string             `yaml:"addresses,omitempty"`
+	Routes    []networkConfigRoute `yaml:"routes,omitempty"`
+}
+
+type networkConfigRoute struct {
+	To  string `yaml:"to"`
+	Via string `yaml:"via"`
+}
+
+// configureStaticAddresses configures the static addresses on the network devices of the instance.
+//
+// For network devices on managed bridge or OVN networks, the "ipv4.address" or "ipv6.address" device option is set.
+// As instance devices replace profile devices with the same name, the profile device configuration is copied over.
+// For other network devices, a cloud-init network-config is returned. The network-config replaces the default network
+// configuration of the image, so all other network devices of the instance are configured with DHCP.
+//
+// If a network device is not defined in the profiles or the devices of the instance, a terminalError is returned.
+func (c *Client) configureStaticAddresses(ctx context.Context, profiles []string, devices map[string]map[string]string, addresses []StaticAddress) (map[string]map[string]string, string, error) {
+	if len(addresses) == 0 {
+		return devices, "", nil
+	}
+
+	// instances without profiles use the default profile
+	if len(profiles) == 0 {
+		profiles = []string{"default"}
+	}
+
+	// expand the devices of the instance, later profiles override earlier ones
+	expanded := map[string]map[string]string{}
+	for _, profileName := range profiles {
+		profile, _, err := c.Client.GetProfile(profileName)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to GetProfile %q: %w", profileName, err)
+		}
+		for name, device := range profile.Devices {
+			expanded[name] = maps.Clone(device)
+		}
+	}
+	for name, device := range devices {
+		expanded[name] = maps.Clone(device)
+	}
+
+	if devices == nil {
+		devices = map[string]map[string]string{}
+	}
+	var config *networkConfig
+	for _, address := range addresses {
+		device, ok := expanded[address.Device]
+		if !ok || device["type"] != "nic" {
+			return nil, "", terminalError{fmt.Errorf("cannot configure static address %s, network device %q is not defined", address.Address, address.Device)}
+		}
+		addr, err := netip.ParseAddr(address.Address)
+		if err != nil {
+			return nil, "", terminalError{fmt.Errorf("invalid static address %q for network device %q: %w", address.Address, address.Device, err)}
+		}
+
+		if networkName := device["network"]; networkName != "" {
+			network, _, err := c.Client.GetNetwork(networkName)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to GetNetwork %q: %w", networkName, err)
+			}
+			if network.Managed && (network.Type == "bridge" || network.Type == "ovn") {
+				key := "ipv4.address"
+				if addr.Is6() {
+					key = "ipv6.address"
+				}
+				log.FromContext(ctx).V(2).WithValues("device", address.Device, "network", networkName, "address", address.Address).Info("Configuring static address on network device")
+				device[key] = address.Address
+				devices[address.Device] = device
+				continue
+			}
+		}
+
+		ifname := interfaceName(address.Device, device)
+		if config == nil {
+			config = &networkConfig{Version: 2, Ethernets: map[string]*networkConfigEthernet{}}
+		}
+		ethernet, ok := config.Ethernets[ifname]
+		if !ok {
+			ethernet = &networkConfigEthernet{}
+			config.Ethernets[ifname] = ethernet
+		}
+		log.FromContext(ctx).V(2).WithValues("device", address.Device, "interface", ifname, "address", address.Address).Info("Configuring static address with cloud-init")
+		ethernet.Addresses = append(ethernet.Addresses, netip.PrefixFrom(addr, address.Prefix).String())
+		if address.Gateway != "" {
+			to := "0.0.0.0/0"
+			if addr.Is6() {
+				to = "::/0"
+			}
+			ethernet.Routes = append(ethernet.Routes, networkConfigRoute{To: to, Via: address.Gateway})
+		}
+	}
+
+	if config == nil {
+		return devices, "", nil
+	}
+	for name, device := range expanded {
+		if device["type"] != "nic" {
+			continue
+		}
+		if ifname := interfaceName(name, device); config.Ethernets[ifname] == nil {
+			config.Ethernets[ifname] = &networkConfigEthernet{DHCP4: true, DHCP6: true}
+		}
+	}
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal network-config: %w", err)
+	}
+	return devices, string(b), nil
+}
+
+// interfaceName returns the name of the interface of a network device in the instance. This is the "name" device
+// option, or the device name.
+func interfaceName(deviceName string, device map[string]string) string {
+	if name := device["name"]; name != "" {
+		return name
+	}
+	return deviceName
+}
@@ -0,0 +1,142 @@
+package incus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+
+	. "github.com/onsi/gomega"
+)
+
+type mockClient_staticAddresses struct {
+	incus.InstanceServer
+
+	profiles map[string]api.Profile
+	networks map[string]api.Network
+}
+
+func (c *mockClient_staticAddresses) GetProfile(name string) (*api.Profile, string, error) {
+	if profile, ok := c.profiles[name]; ok {
+		return &profile, "", nil
+	}
+	return nil, "", api.StatusErrorf(http.StatusNotFound, "Profile not found")
+}
+
+func (c *mockClient_staticAddresses) GetNetwork(name string) (*api.Network, string, error) {
+	if network, ok := c.networks[name]; ok {
+		return &network, "", nil
+	}
+	return nil, "", api.StatusErrorf(http.StatusNotFound, "Network not found")
+}
+
+func TestConfigureStaticAddresses(t *testing.T) {
+	mock := &mockClient_staticAddresses{
+		profiles: map[string]api.Profile{
+			"default": {Name: "default", ProfilePut: api.ProfilePut{Devices: map[string]map[string]string{
+				"root": {"type": "disk", "path": "/", "pool": "default"},
+				"eth0": {"type": "nic", "network": "incusbr0"},
+			}}},
+			"macvlan": {Name: "macvlan", ProfilePut: api.ProfilePut{Devices: map[string]map[string]string{
+				"eth1": {"type": "nic", "nictype": "macvlan", "parent": "enp5s0", "name": "ens1"},
+			}}},
+		},
+		networks: map[string]api.Network{
+			"incusbr0": {Name: "incusbr0", Type: "bridge", Managed: true},
+			"uplink":   {Name: "uplink", Type: "physical", Managed: true},
+		},
+	}
+	c := &Client{Client: mock}
+
+	t.Run("None", func(t *testing.T) {
+		g := NewWithT(t)
+
+		devices, networkConfig, err := c.configureStaticAddresses(context.TODO(), nil, nil, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(devices).To(BeNil())
+		g.Expect(networkConfig).To(BeEmpty())
+	})
+
+	t.Run("ManagedBridge", func(t *testing.T) {
+		g := NewWithT(t)
+
+		devices, networkConfig, err := c.configureStaticAddresses(context.TODO(), nil, nil, []StaticAddress{
+			{Device: "eth0", Address: "10.0.0.10", Prefix: 24, Gateway: "10.0.0.1"},
+			{Device: "eth0", Address: "fd42::10", Prefix: 64},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(networkConfig).To(BeEmpty())
+		g.Expect(devices).To(Equal(map[string]map[string]string{
+			"eth0": {"type": "nic", "network": "incusbr0", "ipv4.address": "10.0.0.10", "ipv6.address": "fd42::10"},
+		}))
+	})
+
+	t.Run("InstanceDevice", func(t *testing.T) {
+		g := NewWithT(t)
+
+		devices, networkConfig, err := c.configureStaticAddresses(context.TODO(), []string{"default"}, map[string]map[string]string{
+			"eth0": {"type": "nic", "network": "incusbr0", "mtu": "1400"},
+		}, []StaticAddress{
+			{Device: "eth0", Address: "10.0.0.10", Prefix: 24},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(networkConfig).To(BeEmpty())
+		g.Expect(devices).To(Equal(map[string]map[string]string{
+			"eth0": {"type": "nic", "network": "incusbr0", "mtu": "1400", "ipv4.address": "10.0.0.10"},
+		}))
+	})
+
+	t.Run("CloudInit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		devices, networkConfig, err := c.configureStaticAddresses(context.TODO(), []string{"default", "macvlan"}, map[string]map[string]string{
+			"eth2": {"type": "nic", "network": "uplink"},
+		}, []StaticAddress{
+			{Device: "eth1", Address: "192.168.1.10", Prefix: 24, Gateway: "192.168.1.1"},
+			{Device: "eth1", Address: "2001:db8::10", Prefix: 64, Gateway: "2001:db8::1"},
+			{Device: "eth2", Address: "172.16.0.10", Prefix: 16},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(devices).To(Equal(map[string]map[string]string{
+			"eth2": {"type": "nic", "network": "uplink"},
+		}))
+		g.Expect(networkConfig).To(MatchYAML(`
+version: 2
+ethernets:
+  ens1:
+    addresses: [192.168.1.10/24, 2001:db8::10/64]
+    routes:
+    - {to: 0.0.0.0/0, via: 192.168.1.1}
+    - {to: "::/0", via: "2001:db8::1"}
+  eth0:
+    dhcp4: true
+    dhcp6: true
+  eth2:
+    addresses: [172.16.0.10/16]
+`))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			name           string
+			profiles       []string
+			addresses      []StaticAddress
+			expectTerminal bool
+		}{
+			{name: "MissingDevice", addresses: []StaticAddress{{Device: "eth1", Address: "10.0.0.10", Prefix: 24}}, expectTerminal: true},
+			{name: "NotNIC", addresses: []StaticAddress{{Device: "root", Address: "10.0.0.10", Prefix: 24}}, expectTerminal: true},
+			{name: "InvalidAddress", addresses: []StaticAddress{{Device: "eth0", Address: "invalid", Prefix: 24}}, expectTerminal: true},
+			{name: "MissingProfile", profiles: []string{"unknown"}, addresses: []StaticAddress{{Device: "eth0", Address: "10.0.0.10", Prefix: 24}}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				g := NewWithT(t)
+
+				_, _, err := c.configureStaticAddresses(context.TODO(), tc.profiles, nil, tc.addresses)
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(IsTerminalError(err)).To(Equal(tc.expectTerminal))
+			})
+		}
+	})
+}
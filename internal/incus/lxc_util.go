@@ -3,6 +3,8 @@ package incus
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -49,12 +51,14 @@ func (c *Client) wait(ctx context.Context, name string, f func() (incus.Operatio
 	return nil
 }
 
-func (c *Client) waitForInstanceAddress(ctx context.Context, name string) ([]string, error) {
+// waitForInstanceAddress waits until the instance has an address that matches the address family preference of the
+// cluster, then returns all active addresses of the instance.
+func (c *Client) waitForInstanceAddress(ctx context.Context, name string, addressFamily string) ([]string, error) {
 	for {
 		log.FromContext(ctx).V(2).Info("Waiting for instance address")
 		if state, _, err := c.Client.GetInstanceState(name); err != nil {
 			return nil, fmt.Errorf("failed to GetInstanceState: %w", err)
-		} else if addrs := c.ParseActiveMachineAddresses(state); len(addrs) > 0 {
+		} else if addrs := c.ParseActiveMachineAddresses(state); len(FilterAddressesForFamily(addrs, addressFamily)) > 0 {
 			return addrs, nil
 		}
 
@@ -97,7 +101,9 @@ func (c *Client) forceRemoveInstanceIfExists(ctx context.Context, name string) e
 	return nil
 }
 
-func (c *Client) createInstanceIfNotExists(ctx context.Context, instance api.InstancesPost) error {
+// createInstanceIfNotExists creates an instance, unless it already exists.
+// If target is not empty, the instance is created on the specified cluster member (or "@group" cluster group).
+func (c *Client) createInstanceIfNotExists(ctx context.Context, instance api.InstancesPost, target string) error {
 	state, _, err := c.Client.GetInstanceState(instance.Name)
 	if err != nil && !strings.Contains(err.Error(), "Instance not found") {
 		return fmt.Errorf("failed to GetInstanceState: %w", err)
@@ -106,7 +112,7 @@ func (c *Client) createInstanceIfNotExists(ctx context.Context, instance api.Ins
 		return nil
 	}
 
-	log.FromContext(ctx).V(2).Info("Creating instance")
+	log.FromContext(ctx).V(2).WithValues("target", target).Info("Creating instance")
 	return c.wait(ctx, "CreateInstance", func() (incus.Operation, error) {
 		op, err := c.tryFindInstanceCreateOperation(ctx, instance.Name)
 		if err != nil {
@@ -115,6 +121,9 @@ func (c *Client) createInstanceIfNotExists(ctx context.Context, instance api.Ins
 			log.FromContext(ctx).V(2).Info("Found existing create operation")
 			return op, nil
 		}
+		if target != "" {
+			return c.Client.UseTarget(target).CreateInstance(instance)
+		}
 		return c.Client.CreateInstance(instance)
 	})
 }
@@ -177,6 +186,85 @@ func (c *Client) instanceSourceFromAPI(source infrav1.LXCMachineImageSource) api
 	return result
 }
 
+// ParseDevices parses a list of device overrides using the "<device>,<key>=<value>,<key2>=<value2>" syntax.
+// It returns a map of device configurations, suitable for use in api.InstancePut.
+func ParseDevices(deviceSpecs []string) (map[string]map[string]string, error) {
+	var devices map[string]map[string]string
+	for _, deviceSpec := range deviceSpecs {
+		deviceName, deviceArgs, hasSeparator := strings.Cut(deviceSpec, ",")
+		if !hasSeparator {
+			return nil, fmt.Errorf("device spec %q is not using the expected %q format", deviceSpec, "<device>,<key>=<value>,<key2>=<value2>")
+		}
+
+		if devices == nil {
+			devices = map[string]map[string]string{}
+		}
+
+		if _, ok := devices[deviceName]; !ok {
+			devices[deviceName] = map[string]string{}
+		}
+
+		for _, deviceArg := range strings.Split(deviceArgs, ",") {
+			key, value, hasEqual := strings.Cut(deviceArg, "=")
+			if !hasEqual {
+				return nil, fmt.Errorf("device argument %q of device spec %q is not using the expected %q format", deviceArg, deviceSpec, "<key>=<value>")
+			}
+
+			devices[deviceName][key] = value
+		}
+	}
+	return devices, nil
+}
+
+// overrideDevices adds the structured device overrides to a map of device configurations, replacing any devices
+// with the same name. It returns the updated map, suitable for use in api.InstancePut.
+func overrideDevices(devices map[string]map[string]string, overrides map[string]infrav1.LXCDevice) map[string]map[string]string {
+	for name, override := range overrides {
+		if devices == nil {
+			devices = map[string]map[string]string{}
+		}
+		device := make(map[string]string, len(override.Config)+1)
+		for key, value := range override.Config {
+			device[key] = value
+		}
+		device["type"] = override.Type
+		devices[name] = device
+	}
+	return devices
+}
+
+// IsReservedConfigKey returns true if an instance config key is managed by the provider, and cannot be set in the
+// instance spec. These are the "user.cluster-*" keys that track the instances of a cluster, the "cloud-init.*" keys
+// and "raw.qemu", which are used to pass bootstrap data to the instance.
+func IsReservedConfigKey(key string) bool {
+	return strings.HasPrefix(key, "user.cluster-") || strings.HasPrefix(key, "cloud-init.") || key == configRawQEMUKey
+}
+
+// overrideConfig adds the config keys from the instance spec to the instance configuration. It returns the updated
+// map, suitable for use in api.InstancePut.
+//
+// If any of the overrides is a reserved config key (see IsReservedConfigKey), a terminalError is returned.
+func overrideConfig(config map[string]string, overrides map[string]string) (map[string]string, error) {
+	var reserved []string
+	for key := range overrides {
+		if IsReservedConfigKey(key) {
+			reserved = append(reserved, key)
+		}
+	}
+	if len(reserved) > 0 {
+		slices.Sort(reserved)
+		return nil, terminalError{fmt.Errorf("instance config keys %v are managed by the provider and cannot be overridden", reserved)}
+	}
+
+	for key, value := range overrides {
+		if config == nil {
+			config = map[string]string{}
+		}
+		config[key] = value
+	}
+	return config, nil
+}
+
 func (c *Client) instanceTypeFromAPI(instanceType string) api.InstanceType {
 	if instanceType == "" {
 		return api.InstanceTypeContainer
@@ -184,30 +272,111 @@ func (c *Client) instanceTypeFromAPI(instanceType string) api.InstanceType {
 	return api.InstanceType(instanceType)
 }
 
-func (c *Client) getLoadBalancerConfiguration(ctx context.Context, clusterName string, clusterNamespace string) (*loadbalancer.ConfigData, error) {
+// getLoadBalancerConfiguration returns the load balancer configuration, based on the currently running instances of the cluster.
+func (c *Client) getLoadBalancerConfiguration(ctx context.Context, clusterName string, clusterNamespace string, settings loadBalancerSettings) (*loadbalancer.ConfigData, error) {
+	controlPlaneServers, err := c.getLoadBalancerBackendServers(ctx, clusterName, clusterNamespace, "control-plane", settings.addressFamily)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve cluster control plane instances: %w", err)
+	}
+
+	config := &loadbalancer.ConfigData{
+		FrontendControlPlanePort: strconv.Itoa(int(settings.frontend)),
+		BackendControlPlanePort:  strconv.Itoa(int(settings.backend)),
+		BackendServers:           controlPlaneServers,
+		IPv6:                     settings.addressFamily == infrav1.AddressFamilyIPv6 || settings.addressFamily == infrav1.AddressFamilyDualStack,
+		AdditionalPorts:          make([]loadbalancer.AdditionalPort, 0, len(settings.additional)),
+	}
+
+	var workerServers map[string]loadbalancer.BackendServer
+	for _, port := range settings.additional {
+		servers := controlPlaneServers
+		if port.TargetRole == infrav1.LoadBalancerPortTargetRoleWorker {
+			if workerServers == nil {
+				if workerServers, err = c.getLoadBalancerBackendServers(ctx, clusterName, clusterNamespace, "worker", settings.addressFamily); err != nil {
+					return nil, fmt.Errorf("failed to retrieve cluster worker instances: %w", err)
+				}
+			}
+			servers = workerServers
+		}
+
+		frontendPort, backendPort := strconv.Itoa(int(port.Port)), strconv.Itoa(int(port.Port))
+		switch {
+		case port.EndPort != 0:
+			frontendPort = fmt.Sprintf("%d-%d", port.Port, port.EndPort)
+			backendPort = frontendPort
+		case port.TargetPort != 0:
+			backendPort = strconv.Itoa(int(port.TargetPort))
+		}
+
+		config.AdditionalPorts = append(config.AdditionalPorts, loadbalancer.AdditionalPort{
+			Name:           port.Name,
+			FrontendPort:   frontendPort,
+			BackendPort:    backendPort,
+			BackendServers: servers,
+		})
+	}
+
+	return config, nil
+}
+
+// getLoadBalancerBackendServers returns the load balancer backends for the running instances of the cluster with the given role.
+// The backend address of each instance is the first address that matches the address family preference of the cluster.
+func (c *Client) getLoadBalancerBackendServers(ctx context.Context, clusterName string, clusterNamespace string, role string, addressFamily string) (map[string]loadbalancer.BackendServer, error) {
 	instances, err := c.getInstancesWithFilter(ctx, api.InstanceTypeAny, map[string]string{
 		configClusterNameKey:      clusterName,
 		configClusterNamespaceKey: clusterNamespace,
-		configInstanceRoleKey:     "control-plane",
+		configInstanceRoleKey:     role,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve cluster control plane instances: %w", err)
+		return nil, err
 	}
 
-	config := &loadbalancer.ConfigData{
-		FrontendControlPlanePort: "6443",
-		BackendControlPlanePort:  "6443",
-		BackendServers:           make(map[string]loadbalancer.BackendServer, len(instances)),
-	}
+	servers := make(map[string]loadbalancer.BackendServer, len(instances))
 	for _, instance := range instances {
-		if addresses := c.ParseActiveMachineAddresses(instance.State); len(addresses) > 0 {
-			// TODO(neoaggelos): care about the instance weight (e.g. for deleted machines)
-			// TODO(neoaggelos): care about ipv4 vs ipv6 addresses
-			config.BackendServers[instance.Name] = loadbalancer.BackendServer{Address: addresses[0], Weight: 100}
+		if addresses := FilterAddressesForFamily(c.ParseActiveMachineAddresses(instance.State), addressFamily); len(addresses) > 0 {
+			weight := 100
+			if v, ok := instance.Config[configLoadBalancerWeightKey]; ok {
+				if weight, err = strconv.Atoi(v); err != nil {
+					log.FromContext(ctx).Error(err, "Ignoring invalid load balancer weight", "instance", instance.Name, "weight", v)
+					weight = 100
+				}
+			}
+
+			servers[instance.Name] = loadbalancer.BackendServer{Address: addresses[0], Weight: weight}
 		}
 	}
 
-	return config, nil
+	return servers, nil
+}
+
+// GetOnlineClusterMembers returns the sorted names of the online cluster members, if the server is clustered.
+// It returns nil if the server is not clustered.
+func (c *Client) GetOnlineClusterMembers(ctx context.Context) ([]string, error) {
+	server, _, err := c.Client.GetServer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetServer: %w", err)
+	}
+	if !server.Environment.ServerClustered {
+		return nil, nil
+	}
+
+	members, err := c.Client.GetClusterMembers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetClusterMembers: %w", err)
+	}
+
+	var names []string
+	for _, member := range members {
+		if member.Status != "Online" {
+			log.FromContext(ctx).V(2).WithValues("member", member.ServerName, "status", member.Status).Info("Ignoring cluster member that is not online")
+			continue
+		}
+		names = append(names, member.ServerName)
+	}
+
+	// sort to ensure stable results across invocations
+	slices.Sort(names)
+	return names, nil
 }
 
 // The built-in Client.HasExtension() from Incus cannot be trusted, as it returns true if we skip the GetServer call.
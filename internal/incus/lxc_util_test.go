@@ -1,11 +1,15 @@
 package incus
 
 import (
+	"context"
 	"testing"
 
 	incus "github.com/lxc/incus/v6/client"
 	"github.com/lxc/incus/v6/shared/api"
 
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/loadbalancer"
+
 	. "github.com/onsi/gomega"
 )
 
@@ -77,3 +81,169 @@ func Test_serverSupportsExtensions(t *testing.T) {
 		})
 	}
 }
+
+type mockClient_getLoadBalancerConfiguration struct {
+	incus.InstanceServer
+
+	instances []api.InstanceFull
+}
+
+func (c *mockClient_getLoadBalancerConfiguration) GetInstancesFull(instanceType api.InstanceType) ([]api.InstanceFull, error) {
+	return c.instances, nil
+}
+
+func Test_getLoadBalancerConfiguration(t *testing.T) {
+	newInstance := func(name string, role string, address string, config map[string]string) api.InstanceFull {
+		instance := api.InstanceFull{
+			Instance: api.Instance{
+				Name: name,
+				InstancePut: api.InstancePut{Config: map[string]string{
+					configClusterNameKey:      "cluster",
+					configClusterNamespaceKey: "default",
+					configInstanceRoleKey:     role,
+				}},
+			},
+			State: &api.InstanceState{Network: map[string]api.InstanceStateNetwork{
+				"eth0": {HostName: "veth0", Addresses: []api.InstanceStateNetworkAddress{{Family: "inet", Address: address, Netmask: "24", Scope: "global"}}},
+			}},
+		}
+		for k, v := range config {
+			instance.Config[k] = v
+		}
+		return instance
+	}
+
+	g := NewWithT(t)
+
+	c := &Client{Client: &mockClient_getLoadBalancerConfiguration{instances: []api.InstanceFull{
+		newInstance("cp-1", "control-plane", "10.0.0.11", nil),
+		newInstance("cp-2", "control-plane", "10.0.0.12", map[string]string{configLoadBalancerWeightKey: "0"}),
+		newInstance("cp-3", "control-plane", "10.0.0.13", map[string]string{configLoadBalancerWeightKey: "invalid"}),
+		newInstance("worker-1", "worker", "10.0.0.21", nil),
+		newInstance("lb", "loadbalancer", "10.0.0.2", nil),
+	}}}
+
+	config, err := c.getLoadBalancerConfiguration(context.TODO(), "cluster", "default", loadBalancerSettings{
+		frontend: 443,
+		backend:  6443,
+		additional: []infrav1.LXCLoadBalancerPort{
+			{Name: "konnectivity", Port: 8132},
+			{Name: "metrics", Port: 12379, TargetPort: 2381},
+			{Name: "ingress", Port: 30000, EndPort: 30100, TargetRole: infrav1.LoadBalancerPortTargetRoleWorker},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config.FrontendControlPlanePort).To(Equal("443"))
+	g.Expect(config.BackendControlPlanePort).To(Equal("6443"))
+
+	controlPlaneServers := map[string]loadbalancer.BackendServer{
+		"cp-1": {Address: "10.0.0.11", Weight: 100},
+		"cp-2": {Address: "10.0.0.12", Weight: 0},
+		"cp-3": {Address: "10.0.0.13", Weight: 100},
+	}
+	g.Expect(config.BackendServers).To(Equal(controlPlaneServers))
+	g.Expect(config.AdditionalPorts).To(Equal([]loadbalancer.AdditionalPort{
+		{Name: "konnectivity", FrontendPort: "8132", BackendPort: "8132", BackendServers: controlPlaneServers},
+		{Name: "metrics", FrontendPort: "12379", BackendPort: "2381", BackendServers: controlPlaneServers},
+		{Name: "ingress", FrontendPort: "30000-30100", BackendPort: "30000-30100", BackendServers: map[string]loadbalancer.BackendServer{
+			"worker-1": {Address: "10.0.0.21", Weight: 100},
+		}},
+	}))
+}
+
+func Test_getLoadBalancerConfiguration_AddressFamily(t *testing.T) {
+	instance := api.InstanceFull{
+		Instance: api.Instance{
+			Name: "cp-1",
+			InstancePut: api.InstancePut{Config: map[string]string{
+				configClusterNameKey:      "cluster",
+				configClusterNamespaceKey: "default",
+				configInstanceRoleKey:     "control-plane",
+			}},
+		},
+		State: &api.InstanceState{Network: map[string]api.InstanceStateNetwork{
+			"eth0": {HostName: "veth0", Addresses: []api.InstanceStateNetworkAddress{
+				{Family: "inet", Address: "10.0.0.11", Netmask: "24", Scope: "global"},
+				{Family: "inet6", Address: "2001:db8::11", Netmask: "64", Scope: "global"},
+				{Family: "inet6", Address: "fe80::11", Netmask: "64", Scope: "link"},
+			}},
+		}},
+	}
+	c := &Client{Client: &mockClient_getLoadBalancerConfiguration{instances: []api.InstanceFull{instance}}}
+
+	for _, tc := range []struct {
+		name          string
+		addressFamily string
+		expectAddress string
+		expectIPv6    bool
+	}{
+		{name: "Default", addressFamily: "", expectAddress: "10.0.0.11"},
+		{name: "IPv4", addressFamily: infrav1.AddressFamilyIPv4, expectAddress: "10.0.0.11"},
+		{name: "IPv6", addressFamily: infrav1.AddressFamilyIPv6, expectAddress: "2001:db8::11", expectIPv6: true},
+		{name: "DualStack", addressFamily: infrav1.AddressFamilyDualStack, expectAddress: "10.0.0.11", expectIPv6: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			config, err := c.getLoadBalancerConfiguration(context.TODO(), "cluster", "default", loadBalancerSettings{
+				frontend:      6443,
+				backend:       6443,
+				addressFamily: tc.addressFamily,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(config.IPv6).To(Equal(tc.expectIPv6))
+			g.Expect(config.BackendServers).To(Equal(map[string]loadbalancer.BackendServer{
+				"cp-1": {Address: tc.expectAddress, Weight: 100},
+			}))
+		})
+	}
+}
+
+func Test_overrideDevices(t *testing.T) {
+	g := NewWithT(t)
+
+	devices, err := ParseDevices([]string{"eth0,type=nic,network=lxdbr0", "root,type=disk,pool=default,path=/"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(overrideDevices(devices, map[string]infrav1.LXCDevice{
+		"eth0": {Type: "nic", Config: map[string]string{"network": "my-network", "ipv4.routes": "10.0.0.0/24,10.0.1.0/24"}},
+		"eth1": {Type: "none"},
+	})).To(Equal(map[string]map[string]string{
+		"eth0": {"type": "nic", "network": "my-network", "ipv4.routes": "10.0.0.0/24,10.0.1.0/24"},
+		"eth1": {"type": "none"},
+		"root": {"type": "disk", "pool": "default", "path": "/"},
+	}))
+
+	g.Expect(overrideDevices(nil, nil)).To(BeNil())
+}
+
+func Test_overrideConfig(t *testing.T) {
+	t.Run("Merge", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(overrideConfig(map[string]string{
+			configClusterNameKey: "cluster",
+			configCloudInitKey:   "#cloud-config",
+		}, map[string]string{
+			"limits.cpu.allowance": "50%",
+			"raw.qemu.conf":        "[device]",
+		})).To(Equal(map[string]string{
+			configClusterNameKey:   "cluster",
+			configCloudInitKey:     "#cloud-config",
+			"limits.cpu.allowance": "50%",
+			"raw.qemu.conf":        "[device]",
+		}))
+
+		g.Expect(overrideConfig(nil, nil)).To(BeNil())
+	})
+
+	t.Run("ReservedKeys", func(t *testing.T) {
+		g := NewWithT(t)
+
+		for _, key := range []string{configClusterNameKey, configMachinePoolKey, configCloudInitNetworkConfigKey, configRawQEMUKey} {
+			_, err := overrideConfig(map[string]string{}, map[string]string{key: "value"})
+			g.Expect(err).To(HaveOccurred(), key)
+			g.Expect(IsTerminalError(err)).To(BeTrue(), key)
+		}
+	})
+}
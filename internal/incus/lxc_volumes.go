@@ -0,0 +1,129 @@
+package incus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	incus "github.com/lxc/incus/v6/client"
+	"github.com/lxc/incus/v6/shared/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// volumeName returns the name of the custom storage volume of an instance.
+func volumeName(instanceName string, volume infrav1.LXCMachineVolume) string {
+	return fmt.Sprintf("%s-%s", instanceName, volume.Name)
+}
+
+// validateVolumes checks that the volumes can be attached to an instance of the given type.
+// Block volumes can only be attached to virtual machines, otherwise a terminalError is returned.
+func validateVolumes(instanceType api.InstanceType, volumes []infrav1.LXCMachineVolume) error {
+	for _, volume := range volumes {
+		if volume.ContentType == infrav1.VolumeContentTypeBlock && instanceType != api.InstanceTypeVM {
+			return terminalError{fmt.Errorf("volume %q: block volumes are only supported for virtual machines", volume.Name)}
+		}
+	}
+	return nil
+}
+
+// ensureVolumes creates the custom storage volumes of an instance (if they do not already exist), and attaches them
+// to the instance as disk devices. On clustered servers, the volumes are created on the cluster member of the instance.
+//
+// If a storage pool does not exist, a terminalError is returned.
+func (c *Client) ensureVolumes(ctx context.Context, name string, config map[string]string, volumes []infrav1.LXCMachineVolume) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	instance, etag, err := c.Client.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("failed to GetInstance: %w", err)
+	}
+
+	client := c.Client
+	if instance.Location != "" && instance.Location != "none" {
+		client = client.UseTarget(instance.Location)
+	}
+
+	put := instance.Writable()
+	if put.Devices == nil {
+		put.Devices = map[string]map[string]string{}
+	}
+	var changed bool
+	for _, volume := range volumes {
+		volName := volumeName(name, volume)
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("volume", volName, "pool", volume.Pool))
+
+		if _, _, err := client.GetStoragePoolVolume(volume.Pool, "custom", volName); err == nil {
+			log.FromContext(ctx).V(2).Info("Volume already exists")
+		} else if !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return fmt.Errorf("failed to GetStoragePoolVolume: %w", err)
+		} else {
+			contentType := volume.ContentType
+			if contentType == "" {
+				contentType = infrav1.VolumeContentTypeFilesystem
+			}
+			volumeConfig := map[string]string{}
+			for _, key := range []string{configClusterNameKey, configClusterNamespaceKey} {
+				volumeConfig[key] = config[key]
+			}
+			if volume.Size != "" {
+				volumeConfig["size"] = volume.Size
+			}
+
+			log.FromContext(ctx).V(2).Info("Creating volume")
+			if err := client.CreateStoragePoolVolume(volume.Pool, api.StorageVolumesPost{
+				Name:             volName,
+				Type:             "custom",
+				ContentType:      contentType,
+				StorageVolumePut: api.StorageVolumePut{Config: volumeConfig},
+			}); err != nil {
+				if api.StatusErrorCheck(err, http.StatusNotFound) {
+					return terminalError{fmt.Errorf("failed to create volume %q: storage pool %q does not exist: %w", volName, volume.Pool, err)}
+				}
+				return fmt.Errorf("failed to CreateStoragePoolVolume: %w", err)
+			}
+		}
+
+		if _, ok := put.Devices[volume.Name]; ok {
+			continue
+		}
+		device := map[string]string{"type": "disk", "pool": volume.Pool, "source": volName}
+		if volume.Path != "" {
+			device["path"] = volume.Path
+		}
+		put.Devices[volume.Name] = device
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	log.FromContext(ctx).V(2).Info("Attaching volumes to instance")
+	return c.wait(ctx, "UpdateInstance", func() (incus.Operation, error) {
+		return c.Client.UpdateInstance(name, put, etag)
+	})
+}
+
+// deleteVolumes deletes the custom storage volumes of an instance, except for volumes with the Retain deletion policy.
+// Volumes that do not exist are ignored.
+func (c *Client) deleteVolumes(ctx context.Context, name string, volumes []infrav1.LXCMachineVolume) error {
+	for _, volume := range volumes {
+		volName := volumeName(name, volume)
+		ctx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("volume", volName, "pool", volume.Pool))
+
+		if volume.DeletionPolicy == infrav1.VolumeDeletionPolicyRetain {
+			log.FromContext(ctx).V(2).Info("Retaining volume")
+			continue
+		}
+
+		log.FromContext(ctx).V(2).Info("Deleting volume")
+		if err := c.Client.DeleteStoragePoolVolume(volume.Pool, "custom", volName); err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return fmt.Errorf("failed to DeleteStoragePoolVolume: %w", err)
+		}
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"strings"
 	"text/template"
 )
 
@@ -31,6 +32,23 @@ type ConfigData struct {
 	BackendControlPlanePort  string
 	BackendServers           map[string]BackendServer
 	IPv6                     bool
+	AdditionalPorts          []AdditionalPort
+}
+
+// AdditionalPort defines an additional port that is forwarded by the loadbalancer.
+type AdditionalPort struct {
+	Name string
+	// FrontendPort is a single port (e.g. "8132") or a port range (e.g. "30000-30100").
+	FrontendPort string
+	// BackendPort is the port on the backend servers. For port ranges, it is the same as FrontendPort, and
+	// connections are forwarded to the same port on the backend servers (without health checks).
+	BackendPort    string
+	BackendServers map[string]BackendServer
+}
+
+// IsRange returns true if the additional port is a port range.
+func (p AdditionalPort) IsRange() bool {
+	return strings.Contains(p.FrontendPort, "-")
 }
 
 // BackendServer defines a loadbalancer backend.
@@ -82,6 +100,23 @@ backend kube-apiservers
   {{range $server, $backend := .BackendServers}}
   server {{ $server }} {{ JoinHostPort $backend.Address $.BackendControlPlanePort }} weight {{ $backend.Weight }} check check-ssl verify none
   {{- end}}
+{{ range $port := .AdditionalPorts }}
+frontend {{ $port.Name }}
+  bind *:{{ $port.FrontendPort }}
+  {{ if $.IPv6 -}}
+  bind :::{{ $port.FrontendPort }};
+  {{- end }}
+  default_backend {{ $port.Name }}
+
+backend {{ $port.Name }}
+  {{- range $server, $backend := $port.BackendServers }}
+  {{- if $port.IsRange }}
+  server {{ $server }} {{ $backend.Address }}: weight {{ $backend.Weight }}
+  {{- else }}
+  server {{ $server }} {{ JoinHostPort $backend.Address $port.BackendPort }} weight {{ $backend.Weight }} check
+  {{- end }}
+  {{- end }}
+{{ end -}}
 `
 
 // Config generates the loadbalancer config from the ConfigTemplate and ConfigData.
@@ -100,3 +135,33 @@ func Config(data *ConfigData, configTemplate string) (config []byte, err error)
 	}
 	return buff.Bytes(), nil
 }
+
+// ValidateTemplate checks that a custom loadbalancer config template can be parsed, and renders successfully
+// for a set of sample backend servers. The rendered haproxy configuration is not validated, this is done with
+// "haproxy -c" on the load balancer instances before the configuration is applied.
+func ValidateTemplate(configTemplate string) error {
+	config, err := Config(&ConfigData{
+		FrontendControlPlanePort: "6443",
+		BackendControlPlanePort:  "6443",
+		BackendServers: map[string]BackendServer{
+			"control-plane-0": {Address: "10.0.0.10", Weight: 100},
+			"control-plane-1": {Address: "fd42::10", Weight: 0},
+		},
+		IPv6: true,
+		AdditionalPorts: []AdditionalPort{
+			{Name: "konnectivity", FrontendPort: "8132", BackendPort: "8132", BackendServers: map[string]BackendServer{
+				"control-plane-0": {Address: "10.0.0.10", Weight: 100},
+			}},
+			{Name: "ingress", FrontendPort: "30000-30100", BackendPort: "30000-30100", BackendServers: map[string]BackendServer{
+				"worker-0": {Address: "10.0.0.20", Weight: 100},
+			}},
+		},
+	}, configTemplate)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(config)) == 0 {
+		return fmt.Errorf("config template rendered an empty configuration")
+	}
+	return nil
+}
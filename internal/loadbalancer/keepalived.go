@@ -0,0 +1,70 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// KeepalivedConfigData is supplied to the keepalived config template.
+type KeepalivedConfigData struct {
+	Interface       string
+	VirtualRouterID int32
+	Priority        int
+	VirtualIP       string
+	UnicastSrcIP    string
+	UnicastPeers    []string
+}
+
+// DefaultKeepalivedTemplate is the keepalived config template.
+//
+// All instances start as BACKUP and the instance with the highest priority becomes MASTER.
+// The virtual IP moves to a different instance if haproxy is not running.
+const DefaultKeepalivedTemplate = `# generated by cluster-api-provider-lxc
+global_defs {
+  enable_script_security
+  script_user root
+}
+
+vrrp_script chk_haproxy {
+  script "/usr/bin/pgrep -x haproxy"
+  interval 2
+  fall 2
+  rise 2
+}
+
+vrrp_instance control-plane {
+  state BACKUP
+  interface {{ .Interface }}
+  virtual_router_id {{ .VirtualRouterID }}
+  priority {{ .Priority }}
+  advert_int 1
+  {{- if .UnicastSrcIP }}
+  unicast_src_ip {{ .UnicastSrcIP }}
+  unicast_peer {
+    {{- range .UnicastPeers }}
+    {{ . }}
+    {{- end }}
+  }
+  {{- end }}
+  virtual_ipaddress {
+    {{ .VirtualIP }}
+  }
+  track_script {
+    chk_haproxy
+  }
+}
+`
+
+// KeepalivedConfig generates the keepalived config from the template and KeepalivedConfigData.
+func KeepalivedConfig(data *KeepalivedConfigData, configTemplate string) ([]byte, error) {
+	t, err := template.New("keepalived-config").Parse(configTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %w", err)
+	}
+	var buff bytes.Buffer
+	if err := t.Execute(&buff, data); err != nil {
+		return nil, fmt.Errorf("error executing config template: %w", err)
+	}
+	return buff.Bytes(), nil
+}
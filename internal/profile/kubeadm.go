@@ -2,6 +2,7 @@ package profile
 
 import (
 	_ "embed"
+	"maps"
 
 	"github.com/lxc/incus/v6/shared/api"
 	"gopkg.in/yaml.v2"
@@ -11,12 +12,21 @@ var (
 	//go:embed embed/kubeadm.yaml
 	defaultKubeadmYAML []byte
 
+	//go:embed embed/kubeadm-unprivileged.yaml
+	unprivilegedKubeadmYAML []byte
+
 	// DefaultKubeadm is the default kubeadm profile to use with LXC nodes.
 	DefaultKubeadm api.ProfilePut
+
+	// UnprivilegedKubeadm is the kubeadm profile to use with LXC nodes on projects that do not allow privileged containers.
+	// Kernel modules must be loaded on the host, and the nodes require the adjustments of the kubelet configuration
+	// that are applied when the instances are created (see incus.Client.CreateInstance).
+	UnprivilegedKubeadm api.ProfilePut
 )
 
 func init() {
 	DefaultKubeadm = mustParseProfile(defaultKubeadmYAML)
+	UnprivilegedKubeadm = mustParseProfile(unprivilegedKubeadmYAML)
 }
 
 func mustParseProfile(b []byte) api.ProfilePut {
@@ -26,3 +36,26 @@ func mustParseProfile(b []byte) api.ProfilePut {
 	}
 	return profile
 }
+
+// WithOverrides returns a copy of the profile, with the specified config keys and devices applied on top.
+// Devices in the overrides replace devices with the same name in the profile.
+func WithOverrides(profile api.ProfilePut, config map[string]string, devices map[string]map[string]string) api.ProfilePut {
+	result := api.ProfilePut{
+		Description: profile.Description,
+		Config:      make(map[string]string, len(profile.Config)+len(config)),
+		Devices:     make(map[string]map[string]string, len(profile.Devices)+len(devices)),
+	}
+	for key, value := range profile.Config {
+		result.Config[key] = value
+	}
+	for key, value := range config {
+		result.Config[key] = value
+	}
+	for name, device := range profile.Devices {
+		result.Devices[name] = maps.Clone(device)
+	}
+	for name, device := range devices {
+		result.Devices[name] = maps.Clone(device)
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+)
+
+const (
+	// DefaultCloudInitNetworkConfigKey is the default Secret or ConfigMap key for the cloud-init network-config.
+	DefaultCloudInitNetworkConfigKey = "network-config"
+
+	// DefaultCloudInitVendorDataKey is the default Secret or ConfigMap key for the cloud-init vendor-data.
+	DefaultCloudInitVendorDataKey = "vendor-data"
+)
+
+// RenderCloudInitConfig retrieves the cloud-init network-config and vendor-data templates of a machine, and renders
+// them for an instance. Empty strings are returned for configuration that is not set.
+func RenderCloudInitConfig(ctx context.Context, c client.Client, namespace string, spec *infrav1.LXCMachineCloudInit, data cloudinit.TemplateData) (networkConfig string, vendorData string, err error) {
+	if spec == nil {
+		return "", "", nil
+	}
+
+	if spec.NetworkConfig != nil {
+		text, err := getCloudInitSource(ctx, c, namespace, *spec.NetworkConfig, DefaultCloudInitNetworkConfigKey)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to retrieve network-config: %w", err)
+		}
+		if networkConfig, err = cloudinit.RenderTemplate("network-config", text, data); err != nil {
+			return "", "", err
+		}
+	}
+
+	if spec.VendorData != nil {
+		text, err := getCloudInitSource(ctx, c, namespace, *spec.VendorData, DefaultCloudInitVendorDataKey)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to retrieve vendor-data: %w", err)
+		}
+		if vendorData, err = cloudinit.RenderTemplate("vendor-data", text, data); err != nil {
+			return "", "", err
+		}
+	}
+
+	return networkConfig, vendorData, nil
+}
+
+// getCloudInitSource returns the cloud-init configuration of source, reading it from a Secret or ConfigMap if needed.
+func getCloudInitSource(ctx context.Context, c client.Client, namespace string, source infrav1.LXCMachineCloudInitSource, defaultKey string) (string, error) {
+	switch {
+	case source.SecretKeyRef != nil:
+		key := source.SecretKeyRef.Key
+		if key == "" {
+			key = defaultKey
+		}
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.SecretKeyRef.Name}, secret); err != nil {
+			return "", fmt.Errorf("failed to retrieve Secret %q: %w", source.SecretKeyRef.Name, err)
+		}
+		value, ok := secret.Data[key]
+		if !ok {
+			return "", fmt.Errorf("secret %q does not have key %q", source.SecretKeyRef.Name, key)
+		}
+		return string(value), nil
+	case source.ConfigMapKeyRef != nil:
+		key := source.ConfigMapKeyRef.Key
+		if key == "" {
+			key = defaultKey
+		}
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.ConfigMapKeyRef.Name}, configMap); err != nil {
+			return "", fmt.Errorf("failed to retrieve ConfigMap %q: %w", source.ConfigMapKeyRef.Name, err)
+		}
+		value, ok := configMap.Data[key]
+		if !ok {
+			return "", fmt.Errorf("ConfigMap %q does not have key %q", source.ConfigMapKeyRef.Name, key)
+		}
+		return value, nil
+	default:
+		return source.Inline, nil
+	}
+}
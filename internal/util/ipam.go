@@ -0,0 +1,70 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// GetOrCreateIPAddressClaim returns the IPAddress allocated for the IPAddressClaim with the given name. If the claim
+// does not exist, it is created with a controller reference to owner. It returns nil if the IPAM provider has not
+// allocated an address for the claim yet.
+func GetOrCreateIPAddressClaim(ctx context.Context, c client.Client, owner client.Object, ownerGVK schema.GroupVersionKind, name string, clusterName string, poolRef corev1.TypedLocalObjectReference) (*ipamv1.IPAddress, error) {
+	claim := &ipamv1.IPAddressClaim{}
+	claimKey := client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}
+	if err := c.Get(ctx, claimKey, claim); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to retrieve IPAddressClaim %q: %w", name, err)
+		}
+
+		claim = &ipamv1.IPAddressClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            claimKey.Name,
+				Namespace:       claimKey.Namespace,
+				Labels:          map[string]string{clusterv1.ClusterNameLabel: clusterName},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(owner, ownerGVK)},
+			},
+			Spec: ipamv1.IPAddressClaimSpec{
+				ClusterName: clusterName,
+				PoolRef:     poolRef,
+			},
+		}
+		// propagate the watch label, so that changes to the claim are not filtered out
+		if v, ok := owner.GetLabels()[clusterv1.WatchLabel]; ok {
+			claim.Labels[clusterv1.WatchLabel] = v
+		}
+		log.FromContext(ctx).Info("Creating IPAddressClaim", "claim", name, "pool", poolRef.Name)
+		if err := c.Create(ctx, claim); err != nil {
+			return nil, fmt.Errorf("failed to create IPAddressClaim %q: %w", name, err)
+		}
+		return nil, nil
+	}
+
+	if claim.Status.AddressRef.Name == "" {
+		return nil, nil
+	}
+
+	address := &ipamv1.IPAddress{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: claimKey.Namespace, Name: claim.Status.AddressRef.Name}, address); err != nil {
+		return nil, fmt.Errorf("failed to retrieve IPAddress %q: %w", claim.Status.AddressRef.Name, err)
+	}
+	return address, nil
+}
+
+// DeleteIPAddressClaim deletes the IPAddressClaim with the given name, releasing the allocated address.
+// It does not return an error if the claim does not exist.
+func DeleteIPAddressClaim(ctx context.Context, c client.Client, namespace string, name string) error {
+	claim := &ipamv1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	if err := c.Delete(ctx, claim); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete IPAddressClaim %q: %w", name, err)
+	}
+	return nil
+}
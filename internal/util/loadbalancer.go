@@ -0,0 +1,44 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/loadbalancer"
+)
+
+// DefaultLoadBalancerConfigTemplateKey is the default ConfigMap key for custom load balancer config templates.
+const DefaultLoadBalancerConfigTemplateKey = "haproxy.cfg.tmpl"
+
+// GetLoadBalancerOptions returns the options for the load balancer manager of the cluster. If a custom haproxy
+// configuration template is referenced, it is retrieved from the ConfigMap and validated.
+func GetLoadBalancerOptions(ctx context.Context, c client.Client, lxcCluster *infrav1.LXCCluster) ([]incus.LoadBalancerOption, error) {
+	ref := lxcCluster.GetLoadBalancerConfigTemplateRef()
+	if ref == nil {
+		return nil, nil
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = DefaultLoadBalancerConfigTemplateKey
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: lxcCluster.Namespace, Name: ref.Name}, configMap); err != nil {
+		return nil, fmt.Errorf("failed to retrieve load balancer config template ConfigMap %q: %w", ref.Name, err)
+	}
+	configTemplate, ok := configMap.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("load balancer config template ConfigMap %q does not have key %q", ref.Name, key)
+	}
+	if err := loadbalancer.ValidateTemplate(configTemplate); err != nil {
+		return nil, fmt.Errorf("invalid load balancer config template in ConfigMap %q key %q: %w", ref.Name, key, err)
+	}
+
+	return []incus.LoadBalancerOption{incus.WithLoadBalancerConfigTemplate(configTemplate)}, nil
+}
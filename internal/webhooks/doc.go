@@ -0,0 +1,2 @@
+// Package webhooks contains the defaulting and validating admission webhooks for the infrastructure v1alpha2 API group.
+package webhooks
@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// LXCImage implements a validating webhook for LXCImage.
+type LXCImage struct{}
+
+func (webhook *LXCImage) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.LXCImage{}).
+		WithValidator(webhook).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha2-lxcimage,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=lxcimages,versions=v1alpha2,name=validation.lxcimage.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.CustomValidator = &LXCImage{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCImage) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	image, ok := obj.(*infrav1.LXCImage)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCImage but got a %T", obj))
+	}
+	if allErrs := validateLXCImageSpec(image.Spec, field.NewPath("spec")); len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(infrav1.GroupVersion.WithKind("LXCImage").GroupKind(), image.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCImage) ValidateUpdate(_ context.Context, oldRaw runtime.Object, newRaw runtime.Object) (admission.Warnings, error) {
+	newObj, ok := newRaw.(*infrav1.LXCImage)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCImage but got a %T", newRaw))
+	}
+	oldObj, ok := oldRaw.(*infrav1.LXCImage)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCImage but got a %T", oldRaw))
+	}
+
+	var allErrs field.ErrorList
+	if !reflect.DeepEqual(oldObj.Spec, newObj.Spec) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec"), newObj.Spec, "LXCImage spec field is immutable. Please create a new resource instead."))
+	}
+	allErrs = append(allErrs, validateLXCImageSpec(newObj.Spec, field.NewPath("spec"))...)
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(infrav1.GroupVersion.WithKind("LXCImage").GroupKind(), newObj.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCImage) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// LXCMachinePool implements a validating and defaulting webhook for LXCMachinePool.
+type LXCMachinePool struct{}
+
+func (webhook *LXCMachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.LXCMachinePool{}).
+		WithDefaulter(webhook).
+		WithValidator(webhook).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1alpha2-lxcmachinepool,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=lxcmachinepools,versions=v1alpha2,name=default.lxcmachinepool.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.CustomDefaulter = &LXCMachinePool{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type.
+func (webhook *LXCMachinePool) Default(_ context.Context, obj runtime.Object) error {
+	machinePool, ok := obj.(*infrav1.LXCMachinePool)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachinePool but got a %T", obj))
+	}
+	defaultLXCMachineSpec(&machinePool.Spec.Template)
+	return nil
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha2-lxcmachinepool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=lxcmachinepools,versions=v1alpha2,name=validation.lxcmachinepool.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.CustomValidator = &LXCMachinePool{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachinePool) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	machinePool, ok := obj.(*infrav1.LXCMachinePool)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachinePool but got a %T", obj))
+	}
+	allErrs := validateLXCMachineSpec(machinePool.Spec.Template, field.NewPath("spec", "template"))
+	// instances of machine pools are not backed by LXCMachine objects that could own the IPAddressClaims
+	if len(machinePool.Spec.Template.AddressesFromPools) > 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "addressesFromPools"), "static addresses are not supported for machine pools"))
+	}
+	if len(machinePool.Spec.Template.Volumes) > 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "volumes"), "volumes are not supported for machine pools"))
+	}
+	if machinePool.Spec.Template.ImageRef != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "imageRef"), "image references are not supported for machine pools"))
+	}
+	if machinePool.Spec.Template.BootstrapTimeout != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "bootstrapTimeout"), "bootstrap timeout is not supported for machine pools"))
+	}
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(infrav1.GroupVersion.WithKind("LXCMachinePool").GroupKind(), machinePool.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachinePool) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return webhook.ValidateCreate(ctx, newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachinePool) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
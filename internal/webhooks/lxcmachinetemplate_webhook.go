@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api/util/topology"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+)
+
+// LXCMachineTemplate implements a validating and defaulting webhook for LXCMachineTemplate.
+type LXCMachineTemplate struct{}
+
+func (webhook *LXCMachineTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.LXCMachineTemplate{}).
+		WithDefaulter(webhook).
+		WithValidator(webhook).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1alpha2-lxcmachinetemplate,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=lxcmachinetemplates,versions=v1alpha2,name=default.lxcmachinetemplate.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.CustomDefaulter = &LXCMachineTemplate{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type.
+func (webhook *LXCMachineTemplate) Default(_ context.Context, obj runtime.Object) error {
+	machineTemplate, ok := obj.(*infrav1.LXCMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachineTemplate but got a %T", obj))
+	}
+	defaultLXCMachineSpec(&machineTemplate.Spec.Template.Spec)
+	return nil
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha2-lxcmachinetemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=lxcmachinetemplates,versions=v1alpha2,name=validation.lxcmachinetemplate.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+var _ webhook.CustomValidator = &LXCMachineTemplate{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachineTemplate) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	machineTemplate, ok := obj.(*infrav1.LXCMachineTemplate)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachineTemplate but got a %T", obj))
+	}
+
+	allErrs := machineTemplate.Spec.Template.ObjectMeta.Validate(field.NewPath("spec", "template", "metadata"))
+	allErrs = append(allErrs, validateLXCMachineSpec(machineTemplate.Spec.Template.Spec, field.NewPath("spec", "template", "spec"))...)
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(infrav1.GroupVersion.WithKind("LXCMachineTemplate").GroupKind(), machineTemplate.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachineTemplate) ValidateUpdate(ctx context.Context, oldRaw runtime.Object, newRaw runtime.Object) (admission.Warnings, error) {
+	newObj, ok := newRaw.(*infrav1.LXCMachineTemplate)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachineTemplate but got a %T", newRaw))
+	}
+	oldObj, ok := oldRaw.(*infrav1.LXCMachineTemplate)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a LXCMachineTemplate but got a %T", oldRaw))
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a admission.Request inside context: %v", err))
+	}
+
+	var allErrs field.ErrorList
+	if !topology.ShouldSkipImmutabilityChecks(req, newObj) {
+		// NOTE(neoaggelos): compare defaulted specs, so that objects created before the defaulting webhook was in place can still be updated.
+		oldSpec, newSpec := oldObj.Spec.Template.Spec.DeepCopy(), newObj.Spec.Template.Spec.DeepCopy()
+		defaultLXCMachineSpec(oldSpec)
+		defaultLXCMachineSpec(newSpec)
+
+		if !reflect.DeepEqual(oldSpec, newSpec) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "template", "spec"), newObj.Spec.Template.Spec, "LXCMachineTemplate spec.template.spec field is immutable. Please create a new resource instead."))
+		}
+	}
+
+	allErrs = append(allErrs, newObj.Spec.Template.ObjectMeta.Validate(field.NewPath("spec", "template", "metadata"))...)
+	allErrs = append(allErrs, validateLXCMachineSpec(newObj.Spec.Template.Spec, field.NewPath("spec", "template", "spec"))...)
+	if len(allErrs) > 0 {
+		return nil, apierrors.NewInvalid(infrav1.GroupVersion.WithKind("LXCMachineTemplate").GroupKind(), newObj.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (webhook *LXCMachineTemplate) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
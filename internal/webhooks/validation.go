@@ -0,0 +1,392 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/lxc/incus/v6/shared/units"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/cloudinit"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/incus"
+)
+
+func defaultLXCClusterSpec(s *infrav1.LXCClusterSpec) {
+	if s.ControlPlaneEndpoint.Port == 0 {
+		s.ControlPlaneEndpoint.Port = 6443
+	}
+	if s.LoadBalancer.Keepalived != nil && s.LoadBalancer.Keepalived.Replicas == 0 {
+		s.LoadBalancer.Keepalived.Replicas = 2
+	}
+}
+
+func validateLXCClusterSpec(s infrav1.LXCClusterSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	lbPath := path.Child("loadBalancer")
+	switch {
+	case s.LoadBalancer.LXC != nil:
+		allErrs = append(allErrs, validateLXCLoadBalancerMachineSpec(s.LoadBalancer.LXC.InstanceSpec, lbPath.Child("lxc", "instanceSpec"))...)
+	case s.LoadBalancer.OCI != nil:
+		allErrs = append(allErrs, validateLXCLoadBalancerMachineSpec(s.LoadBalancer.OCI.InstanceSpec, lbPath.Child("oci", "instanceSpec"))...)
+	case s.LoadBalancer.Keepalived != nil:
+		allErrs = append(allErrs, validateLXCLoadBalancerMachineSpec(s.LoadBalancer.Keepalived.InstanceSpec, lbPath.Child("keepalived", "instanceSpec"))...)
+		if s.ControlPlaneEndpoint.Host == "" {
+			allErrs = append(allErrs, field.Required(path.Child("controlPlaneEndpoint", "host"), "control plane endpoint host is required when using the keepalived load balancer"))
+		} else if net.ParseIP(s.ControlPlaneEndpoint.Host) == nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("controlPlaneEndpoint", "host"), s.ControlPlaneEndpoint.Host, "must be an IP address when using the keepalived load balancer"))
+		}
+	case s.LoadBalancer.OVN != nil:
+		if s.LoadBalancer.OVN.NetworkName == "" {
+			allErrs = append(allErrs, field.Required(lbPath.Child("ovn", "networkName"), "network name is required when using the ovn load balancer"))
+		}
+		if s.LoadBalancer.OVN.AddressPool != nil {
+			allErrs = append(allErrs, validateLXCLoadBalancerAddressPool(*s.LoadBalancer.OVN.AddressPool, s.AddressFamily, lbPath.Child("ovn", "addressPool"))...)
+		} else if s.ControlPlaneEndpoint.Host == "" {
+			allErrs = append(allErrs, field.Required(path.Child("controlPlaneEndpoint", "host"), "control plane endpoint host is required when using the ovn load balancer without an address pool"))
+		}
+	case s.LoadBalancer.External != nil:
+		if s.LoadBalancer.External.AddressPool != nil {
+			allErrs = append(allErrs, validateLXCLoadBalancerAddressPool(*s.LoadBalancer.External.AddressPool, s.AddressFamily, lbPath.Child("external", "addressPool"))...)
+		} else if s.ControlPlaneEndpoint.Host == "" {
+			allErrs = append(allErrs, field.Required(path.Child("controlPlaneEndpoint", "host"), "control plane endpoint host is required when using the external load balancer without an address pool"))
+		}
+	default:
+		allErrs = append(allErrs, field.Required(lbPath, "one of lxc, oci, keepalived, ovn or external must be set"))
+	}
+
+	// the haproxy stats frontend is bound to port 8404 on the load balancer instances
+	if (s.LoadBalancer.LXC != nil || s.LoadBalancer.OCI != nil || s.LoadBalancer.Keepalived != nil) && s.ControlPlaneEndpoint.Port == 8404 {
+		allErrs = append(allErrs, field.Invalid(path.Child("controlPlaneEndpoint", "port"), s.ControlPlaneEndpoint.Port, "port 8404 is reserved for haproxy stats"))
+	}
+
+	// the control plane endpoint must match the address family of the cluster
+	if ip := net.ParseIP(s.ControlPlaneEndpoint.Host); ip != nil {
+		switch {
+		case s.AddressFamily == infrav1.AddressFamilyIPv4 && ip.To4() == nil:
+			allErrs = append(allErrs, field.Invalid(path.Child("controlPlaneEndpoint", "host"), s.ControlPlaneEndpoint.Host, "must be an IPv4 address when address family is IPv4"))
+		case s.AddressFamily == infrav1.AddressFamilyIPv6 && ip.To4() != nil:
+			allErrs = append(allErrs, field.Invalid(path.Child("controlPlaneEndpoint", "host"), s.ControlPlaneEndpoint.Host, "must be an IPv6 address when address family is IPv6"))
+		}
+	}
+
+	allErrs = append(allErrs, validateLXCLoadBalancerPorts(s.AdditionalLoadBalancerPorts, s.ControlPlaneEndpoint.Port, path.Child("additionalLoadBalancerPorts"))...)
+
+	return allErrs
+}
+
+func validateLXCLoadBalancerMachineSpec(s infrav1.LXCLoadBalancerMachineSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateLXCDeviceOverrides(s.DeviceOverrides, path.Child("deviceOverrides"))...)
+	allErrs = append(allErrs, validateLXCInstanceConfig(s.Config, path.Child("config"))...)
+	allErrs = append(allErrs, validateLXCMachineImageSource(s.Image, path.Child("image"))...)
+
+	return allErrs
+}
+
+// reservedLoadBalancerPortNames are the names of the frontends and backends of the default haproxy configuration.
+var reservedLoadBalancerPortNames = []string{"stats", "control-plane", "kube-apiservers"}
+
+func validateLXCLoadBalancerAddressPool(pool infrav1.LXCLoadBalancerAddressPool, addressFamily string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch {
+	case pool.PoolRef != nil && pool.CIDR != "":
+		allErrs = append(allErrs, field.Invalid(path, pool, "only one of poolRef or cidr may be set"))
+	case pool.PoolRef != nil:
+		if pool.PoolRef.Kind == "" {
+			allErrs = append(allErrs, field.Required(path.Child("poolRef", "kind"), "pool kind is required"))
+		}
+		if pool.PoolRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("poolRef", "name"), "pool name is required"))
+		}
+	case pool.CIDR != "":
+		if prefix, err := netip.ParsePrefix(pool.CIDR); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("cidr"), pool.CIDR, fmt.Sprintf("must be a valid CIDR: %v", err)))
+		} else if addressFamily == infrav1.AddressFamilyIPv4 && !prefix.Addr().Is4() {
+			allErrs = append(allErrs, field.Invalid(path.Child("cidr"), pool.CIDR, "must be an IPv4 range when address family is IPv4"))
+		} else if addressFamily == infrav1.AddressFamilyIPv6 && prefix.Addr().Is4() {
+			allErrs = append(allErrs, field.Invalid(path.Child("cidr"), pool.CIDR, "must be an IPv6 range when address family is IPv6"))
+		}
+	default:
+		allErrs = append(allErrs, field.Required(path, "one of poolRef or cidr must be set"))
+	}
+
+	return allErrs
+}
+
+func validateLXCLoadBalancerPorts(ports []infrav1.LXCLoadBalancerPort, controlPlanePort int32, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if controlPlanePort == 0 {
+		controlPlanePort = 6443
+	}
+
+	type portRange struct {
+		name       string
+		start, end int32
+	}
+	// the control plane port and the haproxy stats port are always used
+	used := []portRange{{name: "control-plane", start: controlPlanePort, end: controlPlanePort}, {name: "stats", start: 8404, end: 8404}}
+
+	for idx, port := range ports {
+		portPath := path.Index(idx)
+
+		if slices.Contains(reservedLoadBalancerPortNames, port.Name) {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name, fmt.Sprintf("name must not be one of %v", reservedLoadBalancerPortNames)))
+		}
+
+		end := port.Port
+		if port.EndPort != 0 {
+			if port.EndPort < port.Port {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("endPort"), port.EndPort, "must not be less than port"))
+			}
+			if port.TargetPort != 0 {
+				allErrs = append(allErrs, field.Forbidden(portPath.Child("targetPort"), "must not be set for port ranges"))
+			}
+			end = port.EndPort
+		}
+
+		for _, other := range used {
+			if port.Port <= other.end && end >= other.start {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port, fmt.Sprintf("conflicts with port %q", other.name)))
+			}
+		}
+		used = append(used, portRange{name: port.Name, start: port.Port, end: end})
+	}
+
+	return allErrs
+}
+
+func defaultLXCMachineSpec(s *infrav1.LXCMachineSpec) {
+	if s.InstanceType == "" {
+		s.InstanceType = "container"
+	}
+}
+
+func validateLXCMachineSpec(s infrav1.LXCMachineSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	deviceNames := make(map[string]struct{}, len(s.Devices)+len(s.DeviceOverrides))
+	for idx, device := range s.Devices {
+		devices, err := incus.ParseDevices([]string{device})
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("devices").Index(idx), device, err.Error()))
+		}
+		for name := range devices {
+			deviceNames[name] = struct{}{}
+		}
+	}
+	allErrs = append(allErrs, validateLXCDeviceOverrides(s.DeviceOverrides, path.Child("deviceOverrides"))...)
+	for name := range s.DeviceOverrides {
+		if _, ok := deviceNames[name]; ok {
+			allErrs = append(allErrs, field.Invalid(path.Child("deviceOverrides").Key(name), name, "device must not be set in both devices and deviceOverrides"))
+		}
+		deviceNames[name] = struct{}{}
+	}
+
+	allErrs = append(allErrs, validateLXCInstanceConfig(s.Config, path.Child("config"))...)
+	allErrs = append(allErrs, validateLXCMachineAddressesFromPools(s.AddressesFromPools, path.Child("addressesFromPools"))...)
+	allErrs = append(allErrs, validateLXCMachineVolumes(s.Volumes, deviceNames, path.Child("volumes"))...)
+	if s.CloudInit != nil {
+		if s.CloudInit.NetworkConfig != nil {
+			allErrs = append(allErrs, validateLXCMachineCloudInitSource(*s.CloudInit.NetworkConfig, path.Child("cloudInit", "networkConfig"))...)
+		}
+		if s.CloudInit.VendorData != nil {
+			allErrs = append(allErrs, validateLXCMachineCloudInitSource(*s.CloudInit.VendorData, path.Child("cloudInit", "vendorData"))...)
+		}
+	}
+	allErrs = append(allErrs, validateLXCMachineImageSource(s.Image, path.Child("image"))...)
+	if s.ImageRef != nil {
+		if s.ImageRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("imageRef", "name"), "image name is required"))
+		}
+		if !s.Image.IsZero() {
+			allErrs = append(allErrs, field.Invalid(path.Child("image"), s.Image, "must not be set when imageRef is set"))
+		}
+	}
+	if s.BootstrapTimeout != nil && s.BootstrapTimeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("bootstrapTimeout"), s.BootstrapTimeout.Duration.String(), "must not be negative"))
+	}
+
+	return allErrs
+}
+
+func validateLXCDeviceOverrides(overrides map[string]infrav1.LXCDevice, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for name, device := range overrides {
+		devicePath := path.Key(name)
+
+		if name == "" {
+			allErrs = append(allErrs, field.Invalid(devicePath, name, "device name must not be empty"))
+		}
+		if device.Type == "" {
+			allErrs = append(allErrs, field.Required(devicePath.Child("type"), "device type is required"))
+		}
+		if _, ok := device.Config["type"]; ok {
+			allErrs = append(allErrs, field.Forbidden(devicePath.Child("config").Key("type"), "device type must be set with the type field"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateLXCInstanceConfig(config map[string]string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for key := range config {
+		switch {
+		case key == "":
+			allErrs = append(allErrs, field.Invalid(path.Key(key), key, "config key must not be empty"))
+		case incus.IsReservedConfigKey(key):
+			allErrs = append(allErrs, field.Forbidden(path.Key(key), "config key is managed by the provider"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateLXCMachineVolumes validates the volumes of a machine. Volumes are attached as disk devices with the same
+// name, which must not conflict with the names of the other devices of the machine.
+func validateLXCMachineVolumes(volumes []infrav1.LXCMachineVolume, deviceNames map[string]struct{}, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	seen := make(map[string]struct{}, len(volumes))
+	for idx, volume := range volumes {
+		volumePath := path.Index(idx)
+
+		if volume.Name == "" {
+			allErrs = append(allErrs, field.Required(volumePath.Child("name"), "volume name is required"))
+		} else if _, ok := seen[volume.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(volumePath.Child("name"), volume.Name))
+		} else if _, ok := deviceNames[volume.Name]; ok {
+			allErrs = append(allErrs, field.Invalid(volumePath.Child("name"), volume.Name, "volume name conflicts with a device of the machine"))
+		}
+		seen[volume.Name] = struct{}{}
+
+		if volume.Pool == "" {
+			allErrs = append(allErrs, field.Required(volumePath.Child("pool"), "storage pool is required"))
+		}
+		if volume.Size != "" {
+			if _, err := units.ParseByteSizeString(volume.Size); err != nil {
+				allErrs = append(allErrs, field.Invalid(volumePath.Child("size"), volume.Size, err.Error()))
+			}
+		}
+
+		switch volume.ContentType {
+		case "", infrav1.VolumeContentTypeFilesystem:
+			if !strings.HasPrefix(volume.Path, "/") {
+				allErrs = append(allErrs, field.Invalid(volumePath.Child("path"), volume.Path, "filesystem volumes must be mounted on an absolute path"))
+			}
+		case infrav1.VolumeContentTypeBlock:
+			if volume.Path != "" {
+				allErrs = append(allErrs, field.Forbidden(volumePath.Child("path"), "block volumes cannot be mounted on a path"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(volumePath.Child("contentType"), volume.ContentType, []string{infrav1.VolumeContentTypeFilesystem, infrav1.VolumeContentTypeBlock}))
+		}
+
+		switch volume.DeletionPolicy {
+		case "", infrav1.VolumeDeletionPolicyDelete, infrav1.VolumeDeletionPolicyRetain:
+		default:
+			allErrs = append(allErrs, field.NotSupported(volumePath.Child("deletionPolicy"), volume.DeletionPolicy, []string{infrav1.VolumeDeletionPolicyDelete, infrav1.VolumeDeletionPolicyRetain}))
+		}
+	}
+
+	return allErrs
+}
+
+func validateLXCMachineAddressesFromPools(addresses []infrav1.LXCMachineAddressFromPool, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	type devicePool struct{ device, kind, name string }
+	seen := make(map[devicePool]struct{}, len(addresses))
+	for idx, address := range addresses {
+		addressPath := path.Index(idx)
+
+		if address.Device == "" {
+			allErrs = append(allErrs, field.Required(addressPath.Child("device"), "device name is required"))
+		}
+		if address.PoolRef.Kind == "" {
+			allErrs = append(allErrs, field.Required(addressPath.Child("poolRef", "kind"), "pool kind is required"))
+		}
+		if address.PoolRef.Name == "" {
+			allErrs = append(allErrs, field.Required(addressPath.Child("poolRef", "name"), "pool name is required"))
+		}
+
+		// each device may get one address from each pool, e.g. an IPv4 and an IPv6 pool
+		key := devicePool{device: address.Device, kind: address.PoolRef.Kind, name: address.PoolRef.Name}
+		if _, ok := seen[key]; ok {
+			allErrs = append(allErrs, field.Duplicate(addressPath, address))
+		}
+		seen[key] = struct{}{}
+	}
+
+	return allErrs
+}
+
+func validateLXCMachineCloudInitSource(s infrav1.LXCMachineCloudInitSource, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	var set int
+	if s.Inline != "" {
+		set++
+		// templates from a Secret or ConfigMap are checked when the instance is created
+		if _, err := cloudinit.ParseTemplate(path.String(), s.Inline); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("inline"), s.Inline, err.Error()))
+		}
+	}
+	if s.SecretKeyRef != nil {
+		set++
+		if s.SecretKeyRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("secretKeyRef", "name"), "secret name is required"))
+		}
+	}
+	if s.ConfigMapKeyRef != nil {
+		set++
+		if s.ConfigMapKeyRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("configMapKeyRef", "name"), "ConfigMap name is required"))
+		}
+	}
+	if set != 1 {
+		allErrs = append(allErrs, field.Invalid(path, s, "exactly one of inline, secretKeyRef or configMapKeyRef must be set"))
+	}
+
+	return allErrs
+}
+
+func validateLXCImageSpec(s infrav1.LXCImageSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if s.SecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("secretRef", "name"), "secret name is required"))
+	}
+	if s.Image.Name == "" && s.Image.Fingerprint == "" {
+		allErrs = append(allErrs, field.Required(path.Child("image"), "image name or fingerprint is required"))
+	}
+	allErrs = append(allErrs, validateLXCMachineImageSource(s.Image, path.Child("image"))...)
+
+	return allErrs
+}
+
+func validateLXCMachineImageSource(s infrav1.LXCMachineImageSource, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// "ubuntu:VERSION" is a shortcut that resolves both the image server and protocol, see LXCMachineImageSource.Name
+	if strings.HasPrefix(s.Name, "ubuntu:") {
+		if s.Server != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("server"), s.Server, fmt.Sprintf("must not be set when image name is %q", s.Name)))
+		}
+		if s.Protocol != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("protocol"), s.Protocol, fmt.Sprintf("must not be set when image name is %q", s.Name)))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,614 @@
+package webhooks_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/neoaggelos/cluster-api-provider-lxc/api/v1alpha2"
+	"github.com/neoaggelos/cluster-api-provider-lxc/internal/webhooks"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLXCClusterValidateCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		spec      infrav1.LXCClusterSpec
+		expectErr bool
+	}{
+		{
+			name: "LXC",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}}},
+		},
+		{
+			name:      "NoLoadBalancer",
+			spec:      infrav1.LXCClusterSpec{},
+			expectErr: true,
+		},
+		{
+			name: "OVN",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn"}},
+			},
+		},
+		{
+			name:      "OVNWithoutHost",
+			spec:      infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn"}}},
+			expectErr: true,
+		},
+		{
+			name: "Keepalived",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{Keepalived: &infrav1.LXCLoadBalancerKeepalived{}},
+			},
+		},
+		{
+			name:      "KeepalivedWithoutHost",
+			spec:      infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{Keepalived: &infrav1.LXCLoadBalancerKeepalived{}}},
+			expectErr: true,
+		},
+		{
+			name: "KeepalivedWithHostname",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "k8s.example.com"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{Keepalived: &infrav1.LXCLoadBalancerKeepalived{}},
+			},
+			expectErr: true,
+		},
+		{
+			name:      "ExternalWithoutHost",
+			spec:      infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{}}},
+			expectErr: true,
+		},
+		{
+			name: "UbuntuImageWithServer",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{
+				InstanceSpec: infrav1.LXCLoadBalancerMachineSpec{Image: infrav1.LXCMachineImageSource{Name: "ubuntu:24.04", Server: "https://images.linuxcontainers.org"}},
+			}}},
+			expectErr: true,
+		},
+		{
+			name: "LoadBalancerDeviceOverrides",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{
+				InstanceSpec: infrav1.LXCLoadBalancerMachineSpec{DeviceOverrides: map[string]infrav1.LXCDevice{"eth0": {Type: "nic", Config: map[string]string{"network": "my-network"}}}},
+			}}},
+		},
+		{
+			name: "LoadBalancerDeviceOverridesWithTypeInConfig",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{OCI: &infrav1.LXCLoadBalancerInstance{
+				InstanceSpec: infrav1.LXCLoadBalancerMachineSpec{DeviceOverrides: map[string]infrav1.LXCDevice{"eth0": {Type: "nic", Config: map[string]string{"type": "nic"}}}},
+			}}},
+			expectErr: true,
+		},
+		{
+			name: "LoadBalancerConfig",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{
+				InstanceSpec: infrav1.LXCLoadBalancerMachineSpec{Config: map[string]string{"limits.cpu.allowance": "50%"}},
+			}}},
+		},
+		{
+			name: "LoadBalancerConfigReservedKey",
+			spec: infrav1.LXCClusterSpec{LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{
+				InstanceSpec: infrav1.LXCLoadBalancerMachineSpec{Config: map[string]string{"user.cluster-role": "control-plane"}},
+			}}},
+			expectErr: true,
+		},
+		{
+			name: "ControlPlaneEndpointPort",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Port: 443},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+			},
+		},
+		{
+			name: "ControlPlaneEndpointPortReservedForStats",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Port: 8404},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AdditionalLoadBalancerPorts",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+				AdditionalLoadBalancerPorts: []infrav1.LXCLoadBalancerPort{
+					{Name: "konnectivity", Port: 8132},
+					{Name: "ingress", Port: 30000, EndPort: 30100, TargetRole: infrav1.LoadBalancerPortTargetRoleWorker},
+				},
+			},
+		},
+		{
+			name: "AdditionalLoadBalancerPortConflictsWithControlPlane",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer:                infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+				AdditionalLoadBalancerPorts: []infrav1.LXCLoadBalancerPort{{Name: "apiserver", Port: 6000, EndPort: 7000}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AdditionalLoadBalancerPortsOverlap",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+				AdditionalLoadBalancerPorts: []infrav1.LXCLoadBalancerPort{
+					{Name: "ingress", Port: 30000, EndPort: 30100},
+					{Name: "other", Port: 30050},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AdditionalLoadBalancerPortRangeWithTargetPort",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer:                infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+				AdditionalLoadBalancerPorts: []infrav1.LXCLoadBalancerPort{{Name: "ingress", Port: 30000, EndPort: 30100, TargetPort: 80}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AdditionalLoadBalancerPortReservedName",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer:                infrav1.LXCClusterLoadBalancer{LXC: &infrav1.LXCLoadBalancerInstance{}},
+				AdditionalLoadBalancerPorts: []infrav1.LXCLoadBalancerPort{{Name: "stats", Port: 9000}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "ExternalAddressPoolCIDR",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{CIDR: "10.100.42.240/28"},
+				}},
+			},
+		},
+		{
+			name: "OVNAddressPoolRef",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{
+					NetworkName: "ovn",
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{PoolRef: &corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "pool"}},
+				}},
+			},
+		},
+		{
+			name: "AddressPoolEmpty",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressPoolBothPoolRefAndCIDR",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{
+						PoolRef: &corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "pool"},
+						CIDR:    "10.100.42.240/28",
+					},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressPoolInvalidCIDR",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{CIDR: "10.100.42.240"},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressPoolCIDRAddressFamilyMismatch",
+			spec: infrav1.LXCClusterSpec{
+				LoadBalancer: infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{
+					AddressPool: &infrav1.LXCLoadBalancerAddressPool{CIDR: "10.100.42.240/28"},
+				}},
+				AddressFamily: infrav1.AddressFamilyIPv6,
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressFamilyIPv6",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "fd42::10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn"}},
+				AddressFamily:        infrav1.AddressFamilyIPv6,
+			},
+		},
+		{
+			name: "AddressFamilyIPv6WithIPv4Endpoint",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{Keepalived: &infrav1.LXCLoadBalancerKeepalived{}},
+				AddressFamily:        infrav1.AddressFamilyIPv6,
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressFamilyIPv4WithIPv6Endpoint",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "fd42::10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{External: &infrav1.LXCLoadBalancerExternal{}},
+				AddressFamily:        infrav1.AddressFamilyIPv4,
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressFamilyDualStack",
+			spec: infrav1.LXCClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "fd42::10"},
+				LoadBalancer:         infrav1.LXCClusterLoadBalancer{OVN: &infrav1.LXCLoadBalancerOVN{NetworkName: "ovn"}},
+				AddressFamily:        infrav1.AddressFamilyDualStack,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := (&webhooks.LXCCluster{}).ValidateCreate(context.TODO(), &infrav1.LXCCluster{Spec: tc.spec})
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestLXCMachineValidateCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		spec      infrav1.LXCMachineSpec
+		expectErr bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Devices",
+			spec: infrav1.LXCMachineSpec{Devices: []string{"eth0,type=nic,network=my-network", "root,size=10GiB"}},
+		},
+		{
+			name:      "DeviceWithoutArguments",
+			spec:      infrav1.LXCMachineSpec{Devices: []string{"eth0"}},
+			expectErr: true,
+		},
+		{
+			name:      "DeviceWithInvalidArgument",
+			spec:      infrav1.LXCMachineSpec{Devices: []string{"eth0,type=nic,network"}},
+			expectErr: true,
+		},
+		{
+			name: "DeviceOverrides",
+			spec: infrav1.LXCMachineSpec{DeviceOverrides: map[string]infrav1.LXCDevice{
+				"eth0": {Type: "nic", Config: map[string]string{"network": "my-network", "ipv4.routes": "10.0.0.0/24,10.0.1.0/24"}},
+				"eth1": {Type: "none"},
+			}},
+		},
+		{
+			name:      "DeviceOverridesWithoutType",
+			spec:      infrav1.LXCMachineSpec{DeviceOverrides: map[string]infrav1.LXCDevice{"eth0": {Config: map[string]string{"network": "my-network"}}}},
+			expectErr: true,
+		},
+		{
+			name: "DeviceOverridesConflictWithDevices",
+			spec: infrav1.LXCMachineSpec{
+				Devices:         []string{"eth0,type=nic,network=my-network"},
+				DeviceOverrides: map[string]infrav1.LXCDevice{"eth0": {Type: "nic", Config: map[string]string{"network": "my-network"}}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "VolumeConflictsWithDeviceOverride",
+			spec: infrav1.LXCMachineSpec{
+				DeviceOverrides: map[string]infrav1.LXCDevice{"data": {Type: "disk", Config: map[string]string{"source": "/mnt", "path": "/data"}}},
+				Volumes:         []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", Path: "/data"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Config",
+			spec: infrav1.LXCMachineSpec{Config: map[string]string{"security.secureboot": "false", "user.environment": "test", "raw.qemu.conf": "[device]"}},
+		},
+		{
+			name:      "ConfigClusterKey",
+			spec:      infrav1.LXCMachineSpec{Config: map[string]string{"user.cluster-name": "other"}},
+			expectErr: true,
+		},
+		{
+			name:      "ConfigCloudInitKey",
+			spec:      infrav1.LXCMachineSpec{Config: map[string]string{"cloud-init.user-data": "#cloud-config"}},
+			expectErr: true,
+		},
+		{
+			name:      "ConfigRawQEMU",
+			spec:      infrav1.LXCMachineSpec{Config: map[string]string{"raw.qemu": "-fw_cfg name=opt/com.coreos/config,string={}"}},
+			expectErr: true,
+		},
+		{
+			name: "UbuntuImage",
+			spec: infrav1.LXCMachineSpec{Image: infrav1.LXCMachineImageSource{Name: "ubuntu:24.04"}},
+		},
+		{
+			name:      "UbuntuImageWithProtocol",
+			spec:      infrav1.LXCMachineSpec{Image: infrav1.LXCMachineImageSource{Name: "ubuntu:24.04", Protocol: "simplestreams"}},
+			expectErr: true,
+		},
+		{
+			name: "AddressesFromPools",
+			spec: infrav1.LXCMachineSpec{AddressesFromPools: []infrav1.LXCMachineAddressFromPool{
+				{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv4"}},
+				{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv6"}},
+				{Device: "eth1", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv4"}},
+			}},
+		},
+		{
+			name: "AddressesFromPoolsDuplicate",
+			spec: infrav1.LXCMachineSpec{AddressesFromPools: []infrav1.LXCMachineAddressFromPool{
+				{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv4"}},
+				{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv4"}},
+			}},
+			expectErr: true,
+		},
+		{
+			name: "CloudInit",
+			spec: infrav1.LXCMachineSpec{CloudInit: &infrav1.LXCMachineCloudInit{
+				NetworkConfig: &infrav1.LXCMachineCloudInitSource{Inline: "version: 2\n# {{ .InstanceName }}"},
+				VendorData:    &infrav1.LXCMachineCloudInitSource{SecretKeyRef: &infrav1.SecretKeyRef{Name: "vendor-data"}},
+			}},
+		},
+		{
+			name: "CloudInitInvalidTemplate",
+			spec: infrav1.LXCMachineSpec{CloudInit: &infrav1.LXCMachineCloudInit{
+				NetworkConfig: &infrav1.LXCMachineCloudInitSource{Inline: "{{ .InstanceName "},
+			}},
+			expectErr: true,
+		},
+		{
+			name: "CloudInitMultipleSources",
+			spec: infrav1.LXCMachineSpec{CloudInit: &infrav1.LXCMachineCloudInit{
+				VendorData: &infrav1.LXCMachineCloudInitSource{Inline: "#cloud-config", ConfigMapKeyRef: &infrav1.ConfigMapKeyRef{Name: "vendor-data"}},
+			}},
+			expectErr: true,
+		},
+		{
+			name: "Volumes",
+			spec: infrav1.LXCMachineSpec{Volumes: []infrav1.LXCMachineVolume{
+				{Name: "containerd", Pool: "default", Size: "20GiB", Path: "/var/lib/containerd"},
+				{Name: "data", Pool: "default", ContentType: infrav1.VolumeContentTypeBlock, DeletionPolicy: infrav1.VolumeDeletionPolicyRetain},
+			}},
+		},
+		{
+			name:      "VolumesDuplicate",
+			spec:      infrav1.LXCMachineSpec{Volumes: []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", Path: "/data"}, {Name: "data", Pool: "fast", Path: "/data2"}}},
+			expectErr: true,
+		},
+		{
+			name: "VolumeConflictsWithDevice",
+			spec: infrav1.LXCMachineSpec{
+				Devices: []string{"data,type=disk,source=/mnt,path=/data"},
+				Volumes: []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", Path: "/data"}},
+			},
+			expectErr: true,
+		},
+		{
+			name:      "VolumeFilesystemWithoutPath",
+			spec:      infrav1.LXCMachineSpec{Volumes: []infrav1.LXCMachineVolume{{Name: "data", Pool: "default"}}},
+			expectErr: true,
+		},
+		{
+			name:      "VolumeBlockWithPath",
+			spec:      infrav1.LXCMachineSpec{Volumes: []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", ContentType: infrav1.VolumeContentTypeBlock, Path: "/data"}}},
+			expectErr: true,
+		},
+		{
+			name:      "VolumeInvalidSize",
+			spec:      infrav1.LXCMachineSpec{Volumes: []infrav1.LXCMachineVolume{{Name: "data", Pool: "default", Size: "lots", Path: "/data"}}},
+			expectErr: true,
+		},
+		{
+			name: "BootstrapTimeout",
+			spec: infrav1.LXCMachineSpec{BootstrapTimeout: &metav1.Duration{Duration: 20 * time.Minute}},
+		},
+		{
+			name:      "BootstrapTimeoutNegative",
+			spec:      infrav1.LXCMachineSpec{BootstrapTimeout: &metav1.Duration{Duration: -time.Minute}},
+			expectErr: true,
+		},
+		{
+			name: "ImageRef",
+			spec: infrav1.LXCMachineSpec{ImageRef: &corev1.LocalObjectReference{Name: "kubeadm-v1.32.0"}},
+		},
+		{
+			name:      "ImageRefWithoutName",
+			spec:      infrav1.LXCMachineSpec{ImageRef: &corev1.LocalObjectReference{}},
+			expectErr: true,
+		},
+		{
+			name: "ImageRefWithImage",
+			spec: infrav1.LXCMachineSpec{
+				Image:    infrav1.LXCMachineImageSource{Name: "ubuntu:24.04"},
+				ImageRef: &corev1.LocalObjectReference{Name: "kubeadm-v1.32.0"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "AddressesFromPoolsWithoutPoolName",
+			spec: infrav1.LXCMachineSpec{AddressesFromPools: []infrav1.LXCMachineAddressFromPool{
+				{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool"}},
+			}},
+			expectErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := (&webhooks.LXCMachine{}).ValidateCreate(context.TODO(), &infrav1.LXCMachine{Spec: tc.spec})
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestLXCMachinePoolValidateCreate(t *testing.T) {
+	t.Run("AddressesFromPools", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachinePool{}).ValidateCreate(context.TODO(), &infrav1.LXCMachinePool{Spec: infrav1.LXCMachinePoolSpec{Template: infrav1.LXCMachineSpec{
+			AddressesFromPools: []infrav1.LXCMachineAddressFromPool{{Device: "eth0", PoolRef: corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "ipv4"}}},
+		}}})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("Volumes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachinePool{}).ValidateCreate(context.TODO(), &infrav1.LXCMachinePool{Spec: infrav1.LXCMachinePoolSpec{Template: infrav1.LXCMachineSpec{
+			Volumes: []infrav1.LXCMachineVolume{{Name: "containerd", Pool: "default", Path: "/var/lib/containerd"}},
+		}}})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("BootstrapTimeout", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachinePool{}).ValidateCreate(context.TODO(), &infrav1.LXCMachinePool{Spec: infrav1.LXCMachinePoolSpec{Template: infrav1.LXCMachineSpec{
+			BootstrapTimeout: &metav1.Duration{Duration: 20 * time.Minute},
+		}}})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("ImageRef", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachinePool{}).ValidateCreate(context.TODO(), &infrav1.LXCMachinePool{Spec: infrav1.LXCMachinePoolSpec{Template: infrav1.LXCMachineSpec{
+			ImageRef: &corev1.LocalObjectReference{Name: "kubeadm-v1.32.0"},
+		}}})
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestLXCMachineTemplateValidateUpdate(t *testing.T) {
+	newTemplate := func(spec infrav1.LXCMachineSpec) *infrav1.LXCMachineTemplate {
+		return &infrav1.LXCMachineTemplate{Spec: infrav1.LXCMachineTemplateSpec{Template: infrav1.LXCMachineTemplateResource{Spec: spec}}}
+	}
+	ctx := admission.NewContextWithRequest(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{}})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachineTemplate{}).ValidateUpdate(ctx, newTemplate(infrav1.LXCMachineSpec{Flavor: "c2-m4"}), newTemplate(infrav1.LXCMachineSpec{Flavor: "c2-m4"}))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("Defaulted", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachineTemplate{}).ValidateUpdate(ctx, newTemplate(infrav1.LXCMachineSpec{}), newTemplate(infrav1.LXCMachineSpec{InstanceType: "container"}))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCMachineTemplate{}).ValidateUpdate(ctx, newTemplate(infrav1.LXCMachineSpec{Flavor: "c2-m4"}), newTemplate(infrav1.LXCMachineSpec{Flavor: "c4-m8"}))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestLXCImageValidateCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		spec      infrav1.LXCImageSpec
+		expectErr bool
+	}{
+		{
+			name: "RemoteImage",
+			spec: infrav1.LXCImageSpec{
+				SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+				Image:     infrav1.LXCMachineImageSource{Name: "kubeadm/v1.32.0", Server: "https://images.example.com", Protocol: "simplestreams"},
+			},
+		},
+		{
+			name: "UbuntuImage",
+			spec: infrav1.LXCImageSpec{
+				SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+				Image:     infrav1.LXCMachineImageSource{Name: "ubuntu:24.04"},
+			},
+		},
+		{
+			name: "Fingerprint",
+			spec: infrav1.LXCImageSpec{
+				SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+				Image:     infrav1.LXCMachineImageSource{Fingerprint: "f1"},
+			},
+		},
+		{
+			name:      "NoSecretRef",
+			spec:      infrav1.LXCImageSpec{Image: infrav1.LXCMachineImageSource{Name: "ubuntu:24.04"}},
+			expectErr: true,
+		},
+		{
+			name:      "NoImage",
+			spec:      infrav1.LXCImageSpec{SecretRef: infrav1.SecretRef{Name: "lxc-secret"}},
+			expectErr: true,
+		},
+		{
+			name: "UbuntuImageWithServer",
+			spec: infrav1.LXCImageSpec{
+				SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+				Image:     infrav1.LXCMachineImageSource{Name: "ubuntu:24.04", Server: "https://images.example.com"},
+			},
+			expectErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := (&webhooks.LXCImage{}).ValidateCreate(context.TODO(), &infrav1.LXCImage{Spec: tc.spec})
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestLXCImageValidateUpdate(t *testing.T) {
+	newImage := func(name string) *infrav1.LXCImage {
+		return &infrav1.LXCImage{Spec: infrav1.LXCImageSpec{
+			SecretRef: infrav1.SecretRef{Name: "lxc-secret"},
+			Image:     infrav1.LXCMachineImageSource{Name: name, Server: "https://images.example.com", Protocol: "simplestreams"},
+		}}
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCImage{}).ValidateUpdate(context.TODO(), newImage("kubeadm/v1.32.0"), newImage("kubeadm/v1.32.0"))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := (&webhooks.LXCImage{}).ValidateUpdate(context.TODO(), newImage("kubeadm/v1.32.0"), newImage("kubeadm/v1.33.0"))
+		g.Expect(err).To(HaveOccurred())
+	})
+}